@@ -7,12 +7,25 @@ import (
 	"strings"
 )
 
+const (
+	// ProtocolNats publishes log lines to NATS
+	ProtocolNats = "svarog"
+	// ProtocolGrpc pushes log lines to the server's gRPC ingest port, for
+	// networks where NATS is blocked
+	ProtocolGrpc = "svarog+grpc"
+)
+
 type ClientConfig struct {
 	Protocol   string
 	ServerAddr string
 	Topic      string
 	Creds      string
 	Debug      bool
+	// TLS connects to the gRPC ingest port with TLS
+	TLS bool
+	// ClientId is the client lines are published as with project-wide
+	// credentials, unless they name their own client.
+	ClientId   string
 	connString string
 }
 
@@ -39,6 +52,7 @@ func NewClientConfig(connString string) (ClientConfig, error) {
 		Topic:      strings.TrimPrefix(url.Path, "/"),
 		Creds:      creds,
 		Debug:      url.Query().Get("debug") == "true",
+		TLS:        url.Query().Get("tls") == "true",
 	}
 
 	if err := config.Validate(); err != nil {
@@ -49,7 +63,15 @@ func NewClientConfig(connString string) (ClientConfig, error) {
 }
 
 func (c *ClientConfig) buildConnString() string {
-	return fmt.Sprintf("svarog://%s/%s?token=%s", c.ServerAddr, c.Topic, c.Creds)
+	protocol := ProtocolNats
+	if c.Protocol == ProtocolGrpc {
+		protocol = ProtocolGrpc
+	}
+	connString := fmt.Sprintf("%s://%s/%s?token=%s", protocol, c.ServerAddr, c.Topic, c.Creds)
+	if c.TLS {
+		connString += "&tls=true"
+	}
+	return connString
 }
 
 func (c *ClientConfig) GetConnString() string {
@@ -60,13 +82,56 @@ func (c *ClientConfig) GetConnString() string {
 	return c.connString
 }
 
+// UsesGrpc reports whether log lines are pushed to the gRPC ingest port instead of NATS.
+func (c *ClientConfig) UsesGrpc() bool {
+	return c.Protocol == ProtocolGrpc
+}
+
+// IsWildcard reports whether the credentials allow publishing as any client of the project.
+func (c *ClientConfig) IsWildcard() bool {
+	return strings.HasSuffix(c.Topic, ".*")
+}
+
+// PublishTopic is the subject log lines are published to. Wildcard topics
+// are completed with clientId, other topics are returned as they are.
+func (c *ClientConfig) PublishTopic(clientId string) string {
+	if !c.IsWildcard() {
+		return c.Topic
+	}
+	return strings.TrimSuffix(c.Topic, "*") + SubjectToken(clientId)
+}
+
+// LineTopic is the subject a line of clientId is published to, lines without
+// a client use ClientId.
+func (c *ClientConfig) LineTopic(clientId string) string {
+	if clientId == "" {
+		clientId = c.ClientId
+	}
+	return c.PublishTopic(clientId)
+}
+
+// SubjectToken replaces the characters NATS doesn't allow in a subject token.
+func SubjectToken(value string) string {
+	token := strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '-'
+		}
+		return r
+	}, value)
+	if token == "" {
+		return "unknown"
+	}
+	return token
+}
+
 func (c *ClientConfig) GetNatsUrl() string {
 	return fmt.Sprintf("nats://%s", c.ServerAddr)
 }
 
 func (c ClientConfig) Validate() error {
-	if c.Protocol != "svarog" {
-		return fmt.Errorf("invalid protocol: %s, should be 'svarog'", c.Protocol)
+	if c.Protocol != ProtocolNats && c.Protocol != ProtocolGrpc {
+		return fmt.Errorf("invalid protocol: %s, should be '%s' or '%s'", c.Protocol, ProtocolNats, ProtocolGrpc)
 	}
 	if c.ServerAddr == "" {
 		return fmt.Errorf("server address is required")
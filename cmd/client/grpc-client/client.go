@@ -0,0 +1,262 @@
+package grpcclient
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/cmd/client/config"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	maxBatchSize  = 500
+	batchInterval = 200 * time.Millisecond
+	retryDelay    = 2 * time.Second
+	// drainTimeout is how long to wait for the acks of the last batches on exit
+	drainTimeout = 10 * time.Second
+)
+
+// GrpcClient pushes log lines to the server's gRPC ingest port. Batches stay
+// pending until the server acks them and are resent after reconnecting.
+type GrpcClient struct {
+	config   config.ClientConfig
+	conn     *grpc.ClientConn
+	logLines <-chan *rpc.LogLine
+
+	jwt  string
+	seed string
+
+	mutex   sync.Mutex
+	nextId  uint64
+	pending map[uint64]rpc.LogBatch
+}
+
+func NewGrpcClient(cfg config.ClientConfig, logLines <-chan *rpc.LogLine) *GrpcClient {
+	return &GrpcClient{
+		config:   cfg,
+		logLines: logLines,
+		pending:  map[uint64]rpc.LogBatch{},
+	}
+}
+
+func (g *GrpcClient) Run() {
+	defer g.Close()
+
+	g.connect()
+
+	batches := g.batch()
+	for !g.push(batches) {
+		time.Sleep(retryDelay)
+	}
+
+	slog.Debug("Log lines channel closed, all batches pushed")
+}
+
+func (g *GrpcClient) Close() {
+	if g.conn != nil {
+		g.conn.Close()
+		slog.Debug("gRPC connection closed")
+	}
+}
+
+func (g *GrpcClient) connect() {
+	jwt, seed, err := serverauth.ParseCredsFile(g.config.Creds)
+	if err != nil {
+		slog.Error("Failed to parse credentials", "err", err)
+		panic(err)
+	}
+	g.jwt, g.seed = jwt, seed
+
+	transportCreds := insecure.NewCredentials()
+	if g.config.TLS {
+		transportCreds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	conn, err := grpc.NewClient(g.config.ServerAddr, grpc.WithTransportCredentials(transportCreds))
+	if err != nil {
+		slog.Error("Failed to create gRPC client", "err", err)
+		panic(err)
+	}
+	g.conn = conn
+}
+
+// push streams batches until they are all acked, it returns false when the
+// stream broke and should be reopened.
+func (g *GrpcClient) push(batches <-chan []rpc.LogLine) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := g.openStream(ctx)
+	if err != nil {
+		slog.Warn("Failed to open gRPC stream, retrying in 2s...", "err", err)
+		return false
+	}
+
+	acked := make(chan error, 1)
+	go func() {
+		acked <- g.receiveAcks(stream)
+	}()
+
+	for _, batch := range g.unacked() {
+		if err := stream.Send(&batch); err != nil {
+			slog.Warn("Failed to resend batch", "err", err)
+			return false
+		}
+	}
+
+	for {
+		select {
+		case lines, ok := <-batches:
+			if !ok {
+				return g.drain(stream, acked)
+			}
+			batch := g.track(lines)
+			if err := stream.Send(&batch); err != nil {
+				slog.Warn("Failed to send batch", "err", err)
+				return false
+			}
+		case err := <-acked:
+			slog.Warn("gRPC stream closed, reconnecting...", "err", err)
+			return false
+		}
+	}
+}
+
+// drain closes the sending side and waits for the server to ack what was sent.
+func (g *GrpcClient) drain(stream grpc.BidiStreamingClient[rpc.LogBatch, rpc.PushAck], acked <-chan error) bool {
+	if err := stream.CloseSend(); err != nil {
+		return false
+	}
+
+	select {
+	case err := <-acked:
+		if err != nil && len(g.unacked()) > 0 {
+			slog.Warn("gRPC stream closed before all batches were acked", "err", err)
+			return false
+		}
+	case <-time.After(drainTimeout):
+		slog.Error("Timed out waiting for acks", "pending", len(g.unacked()))
+	}
+	return true
+}
+
+func (g *GrpcClient) openStream(ctx context.Context) (grpc.BidiStreamingClient[rpc.LogBatch, rpc.PushAck], error) {
+	nonce := serverauth.NewNonce()
+	signature, err := serverauth.SignNonce(g.seed, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		rpc.MetadataJWT, g.jwt,
+		rpc.MetadataNonce, nonce,
+		rpc.MetadataSignature, signature,
+		rpc.MetadataTopic, g.config.LineTopic(""),
+	)
+
+	return rpc.NewLogIngestClient(g.conn).Push(ctx)
+}
+
+func (g *GrpcClient) receiveAcks(stream grpc.BidiStreamingClient[rpc.LogBatch, rpc.PushAck]) error {
+	for {
+		ack, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if ack.Error != "" {
+			return fmt.Errorf("batch %d rejected: %s", ack.Id, ack.Error)
+		}
+
+		g.mutex.Lock()
+		delete(g.pending, ack.Id)
+		g.mutex.Unlock()
+	}
+}
+
+func (g *GrpcClient) track(lines []rpc.LogLine) rpc.LogBatch {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.nextId++
+	batch := rpc.LogBatch{Id: g.nextId, Lines: lines}
+	if topic := g.config.LineTopic(lines[0].ClientId); topic != g.config.LineTopic("") {
+		batch.Topic = topic
+	}
+	g.pending[batch.Id] = batch
+	return batch
+}
+
+// unacked returns the pending batches in the order they were sent.
+func (g *GrpcClient) unacked() []rpc.LogBatch {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	batches := make([]rpc.LogBatch, 0, len(g.pending))
+	for _, batch := range g.pending {
+		batches = append(batches, batch)
+	}
+	slices.SortFunc(batches, func(a, b rpc.LogBatch) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return batches
+}
+
+// batch groups log lines into batches of up to maxBatchSize lines, flushed at
+// least every batchInterval or when the client of the lines changes. The
+// returned channel is closed with logLines.
+func (g *GrpcClient) batch() <-chan []rpc.LogLine {
+	batches := make(chan []rpc.LogLine)
+
+	go func() {
+		defer close(batches)
+
+		ticker := time.NewTicker(batchInterval)
+		defer ticker.Stop()
+
+		var lines []rpc.LogLine
+		for {
+			select {
+			case line, ok := <-g.logLines:
+				if !ok {
+					if len(lines) > 0 {
+						batches <- lines
+					}
+					return
+				}
+				if len(lines) > 0 && lines[0].ClientId != line.ClientId {
+					batches <- lines
+					lines = nil
+				}
+				lines = append(lines, *line)
+				if len(lines) >= maxBatchSize {
+					batches <- lines
+					lines = nil
+				}
+			case <-ticker.C:
+				if len(lines) > 0 {
+					batches <- lines
+					lines = nil
+				}
+			}
+		}
+	}()
+
+	return batches
+}
@@ -4,16 +4,24 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 
 	"github.com/charmbracelet/log"
 	"github.com/markojerkic/svarog/cmd/client/config"
+	grpcclient "github.com/markojerkic/svarog/cmd/client/grpc-client"
 	natsclient "github.com/markojerkic/svarog/cmd/client/nats-client"
 	"github.com/markojerkic/svarog/cmd/client/reader"
 	"github.com/markojerkic/svarog/internal/lib/util"
 	"github.com/markojerkic/svarog/internal/rpc"
 )
 
+// logClient publishes the read log lines over one of the transports.
+type logClient interface {
+	Run()
+}
+
 func getInstanceId() string {
 	instanceId := os.Getenv("SVAROG_INSTANCE_ID")
 	if instanceId != "" {
@@ -29,6 +37,14 @@ func getInstanceId() string {
 	return hostname
 }
 
+// getClientId is the client name used with project-wide credentials.
+func getClientId(instanceId string) string {
+	if clientId := os.Getenv("SVAROG_CLIENT_ID"); clientId != "" {
+		return clientId
+	}
+	return instanceId
+}
+
 func readStdin(output chan *rpc.LogLine, instanceId string) {
 	r := reader.NewReader(os.Stdin, output, instanceId)
 
@@ -39,6 +55,35 @@ func readStdin(output chan *rpc.LogLine, instanceId string) {
 	waitGroup.Wait()
 }
 
+// readDocker follows the logs of all containers on the host until the client
+// is stopped. Lines of each container are published as the client named by
+// SVAROG_DOCKER_CLIENT when the credentials are project-wide.
+func readDocker(output chan *rpc.LogLine, wildcard bool) {
+	dockerConfig := reader.DockerConfig{
+		Root:             os.Getenv("SVAROG_DOCKER_ROOT"),
+		InstanceTemplate: os.Getenv("SVAROG_DOCKER_INSTANCE"),
+		ClientTemplate:   os.Getenv("SVAROG_DOCKER_CLIENT"),
+	}
+	if dockerConfig.ClientTemplate != "" && !wildcard {
+		log.Warn("SVAROG_DOCKER_CLIENT needs credentials for all clients, ignoring it")
+		dockerConfig.ClientTemplate = ""
+	}
+
+	r, err := reader.NewDockerReader(dockerConfig, output)
+	if err != nil {
+		log.Fatal("Failed to create Docker reader", "err", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	waitGroup := &sync.WaitGroup{}
+	waitGroup.Add(1)
+	go r.Run(ctx, waitGroup)
+
+	waitGroup.Wait()
+}
+
 func setupLogger(debug bool) {
 	util.SetupLogger(util.LoggerOptions{Debug: debug})
 }
@@ -71,17 +116,34 @@ func main() {
 	instanceId := getInstanceId()
 	slog.Debug("Instance ID", "id", instanceId)
 
+	if config.IsWildcard() {
+		config.ClientId = getClientId(instanceId)
+		slog.Debug("Publishing with project-wide credentials", "topic", config.LineTopic(""))
+	}
+
 	processedLines := make(chan *rpc.LogLine, 1024*1024)
-	natsClient := natsclient.NewNatsClient(config, processedLines)
+	var client logClient
+	if config.UsesGrpc() {
+		client = grpcclient.NewGrpcClient(config, processedLines)
+	} else {
+		client = natsclient.NewNatsClient(config, processedLines)
+	}
 
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		natsClient.Run()
+		client.Run()
 	}()
 
-	readStdin(processedLines, instanceId)
-	close(processedLines) // Signal NATS client to drain and exit
+	switch input := os.Getenv("SVAROG_INPUT"); input {
+	case "", "stdin":
+		readStdin(processedLines, instanceId)
+	case "docker":
+		readDocker(processedLines, config.IsWildcard())
+	default:
+		log.Fatal("Unknown input", "SVAROG_INPUT", input)
+	}
+	close(processedLines) // Signal the client to drain and exit
 	wg.Wait()
 }
@@ -40,7 +40,7 @@ func (n *NatsClient) Run() {
 			continue
 		}
 
-		if _, err := n.js.Publish(context.Background(), n.config.Topic, data); err != nil {
+		if _, err := n.js.Publish(context.Background(), n.config.LineTopic(logLine.ClientId), data); err != nil {
 			slog.Error("Failed to publish log line", "err", err)
 		}
 	}
@@ -63,8 +63,15 @@ func (n *NatsClient) connectNats() {
 		panic(err)
 	}
 
+	inboxPrefix, err := serverauth.InboxPrefix(jwt)
+	if err != nil {
+		slog.Error("Failed to parse credentials", "err", err)
+		panic(err)
+	}
+
 	opts := []nats.Option{
 		nats.UserJWTAndSeed(jwt, seed),
+		nats.CustomInboxPrefix(inboxPrefix),
 		nats.MaxReconnects(-1),
 		nats.ReconnectWait(time.Second),
 		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
@@ -0,0 +1,427 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/rpc"
+)
+
+const (
+	DefaultDockerRoot             = "/var/lib/docker"
+	DefaultDockerInstanceTemplate = "{{.Name}}"
+	DefaultDockerPollInterval     = 250 * time.Millisecond
+	// dockerDiscoverEvery is how many polls pass before new and removed
+	// containers are looked up
+	dockerDiscoverEvery = 8
+)
+
+type DockerConfig struct {
+	// Root is the Docker data directory, the logs are read from
+	// <Root>/containers/<id>/<id>-json.log
+	Root string
+	// InstanceTemplate and ClientTemplate are text/template templates
+	// executed with the ContainerInfo. An empty ClientTemplate publishes as
+	// the connection's client.
+	InstanceTemplate string
+	ClientTemplate   string
+	// PollInterval is how often the logs are checked for new lines
+	PollInterval time.Duration
+}
+
+// ContainerInfo is read from the container's config.v2.json
+type ContainerInfo struct {
+	ID      string
+	ShortID string
+	Name    string
+	Image   string
+	Labels  map[string]string
+}
+
+// DockerReader follows the json-file logs of every container on the host,
+// including containers started later, and survives log rotation.
+type DockerReader struct {
+	config           DockerConfig
+	instanceTemplate *template.Template
+	clientTemplate   *template.Template
+	output           chan<- *rpc.LogLine
+
+	tails map[string]*containerTail
+}
+
+func NewDockerReader(config DockerConfig, output chan<- *rpc.LogLine) (*DockerReader, error) {
+	if config.Root == "" {
+		config.Root = DefaultDockerRoot
+	}
+	if config.InstanceTemplate == "" {
+		config.InstanceTemplate = DefaultDockerInstanceTemplate
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultDockerPollInterval
+	}
+
+	instanceTemplate, err := template.New("instance").Option("missingkey=zero").Parse(config.InstanceTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid instance template: %w", err)
+	}
+	var clientTemplate *template.Template
+	if config.ClientTemplate != "" {
+		clientTemplate, err = template.New("client").Option("missingkey=zero").Parse(config.ClientTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client template: %w", err)
+		}
+	}
+
+	reader := &DockerReader{
+		config:           config,
+		instanceTemplate: instanceTemplate,
+		clientTemplate:   clientTemplate,
+		output:           output,
+		tails:            map[string]*containerTail{},
+	}
+	// Containers that are already running are followed from the end of their
+	// logs, containers started later from the start.
+	reader.discover(true)
+
+	return reader, nil
+}
+
+// Run follows the logs until ctx is done.
+func (r *DockerReader) Run(ctx context.Context, waitGroup *sync.WaitGroup) {
+	defer waitGroup.Done()
+	defer func() {
+		for _, tail := range r.tails {
+			tail.close()
+		}
+	}()
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for poll := 1; ; poll++ {
+		for _, tail := range r.tails {
+			if err := tail.follow(ctx, r.output); err != nil {
+				slog.Error("Failed to read container logs", "container", tail.info.Name, "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if poll%dockerDiscoverEvery == 0 {
+			r.discover(false)
+		}
+	}
+}
+
+func (r *DockerReader) discover(initial bool) {
+	paths, err := filepath.Glob(filepath.Join(r.config.Root, "containers", "*", "*-json.log"))
+	if err != nil {
+		slog.Error("Failed to list container logs", "err", err)
+		return
+	}
+
+	found := map[string]bool{}
+	for _, path := range paths {
+		found[path] = true
+		if _, ok := r.tails[path]; ok {
+			continue
+		}
+
+		tail, err := r.newTail(path, initial)
+		if err != nil {
+			// The container's config may not be written yet, retried on the next discovery
+			slog.Debug("Skipping container log", "path", path, "err", err)
+			continue
+		}
+		slog.Debug("Following container logs", "container", tail.info.Name, "instance", tail.instance, "client", tail.client)
+		r.tails[path] = tail
+	}
+
+	for path, tail := range r.tails {
+		if !found[path] {
+			slog.Debug("Container removed", "container", tail.info.Name)
+			tail.close()
+			delete(r.tails, path)
+		}
+	}
+}
+
+func (r *DockerReader) newTail(path string, fromEnd bool) (*containerTail, error) {
+	info, err := ReadContainerInfo(filepath.Join(filepath.Dir(path), "config.v2.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := executeTemplate(r.instanceTemplate, info)
+	if err != nil {
+		return nil, err
+	}
+	if instance == "" {
+		instance = info.ShortID
+	}
+	client := ""
+	if r.clientTemplate != nil {
+		if client, err = executeTemplate(r.clientTemplate, info); err != nil {
+			return nil, err
+		}
+	}
+
+	tail := &containerTail{
+		path:     path,
+		info:     info,
+		instance: instance,
+		client:   client,
+		fields:   containerFields(info),
+	}
+	if err := tail.open(fromEnd); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+func executeTemplate(tmpl *template.Template, info ContainerInfo) (string, error) {
+	var value strings.Builder
+	if err := tmpl.Execute(&value, info); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value.String()), nil
+}
+
+// ReadContainerInfo reads the name, image and labels of a container from its
+// config.v2.json.
+func ReadContainerInfo(file string) (ContainerInfo, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ContainerInfo{}, err
+	}
+
+	var config struct {
+		ID     string `json:"ID"`
+		Name   string `json:"Name"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ContainerInfo{}, fmt.Errorf("invalid container config: %w", err)
+	}
+	if config.ID == "" {
+		return ContainerInfo{}, errors.New("container config has no ID")
+	}
+
+	info := ContainerInfo{
+		ID:      config.ID,
+		ShortID: config.ID[:min(12, len(config.ID))],
+		Name:    strings.TrimPrefix(config.Name, "/"),
+		Image:   config.Config.Image,
+		Labels:  config.Config.Labels,
+	}
+	if info.Labels == nil {
+		info.Labels = map[string]string{}
+	}
+	return info, nil
+}
+
+// containerFields are stored with every line of the container, label keys
+// get a "label_" prefix and their dots are replaced by underscores.
+func containerFields(info ContainerInfo) map[string]any {
+	fields := map[string]any{
+		"container_id":   info.ShortID,
+		"container_name": info.Name,
+	}
+	if info.Image != "" {
+		fields["container_image"] = info.Image
+	}
+	for key, value := range info.Labels {
+		fields["label_"+strings.ReplaceAll(key, ".", "_")] = value
+	}
+	return fields
+}
+
+type containerTail struct {
+	path     string
+	info     ContainerInfo
+	instance string
+	client   string
+	fields   map[string]any
+
+	file     *os.File
+	reader   *bufio.Reader
+	offset   int64
+	pending  []byte
+	partial  strings.Builder
+	sequence int
+}
+
+func (t *containerTail) open(fromEnd bool) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+
+	t.offset = 0
+	if fromEnd {
+		if t.offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	t.file = file
+	t.reader = bufio.NewReader(file)
+	t.pending = nil
+	return nil
+}
+
+func (t *containerTail) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// follow sends the lines written since the last call. A rotated log is read
+// to its end before the new file is opened.
+func (t *containerTail) follow(ctx context.Context, output chan<- *rpc.LogLine) error {
+	if t.file == nil {
+		if err := t.open(false); err != nil {
+			return nil
+		}
+	}
+
+	if err := t.readAvailable(ctx, output); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.path)
+	if err != nil {
+		// Rotated and not created again yet
+		return nil
+	}
+	opened, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !os.SameFile(current, opened):
+		// Lines written between the read and the rename
+		if err := t.readAvailable(ctx, output); err != nil {
+			return err
+		}
+		t.close()
+		if err := t.open(false); err != nil {
+			return nil
+		}
+		return t.readAvailable(ctx, output)
+	case current.Size() < t.offset:
+		// Truncated in place
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.offset = 0
+		t.reader.Reset(t.file)
+		t.pending = nil
+		return t.readAvailable(ctx, output)
+	}
+	return nil
+}
+
+func (t *containerTail) readAvailable(ctx context.Context, output chan<- *rpc.LogLine) error {
+	for {
+		chunk, err := t.reader.ReadBytes('\n')
+		t.offset += int64(len(chunk))
+
+		if errors.Is(err, io.EOF) {
+			// Keep the incomplete line until the rest is written
+			t.pending = append(t.pending, chunk...)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := chunk
+		if len(t.pending) > 0 {
+			entry = append(t.pending, chunk...)
+			t.pending = nil
+		}
+
+		line, ok := t.parse(entry)
+		if !ok {
+			continue
+		}
+		select {
+		case output <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type dockerLogEntry struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// parse decodes a json-file entry. Docker splits long lines into several
+// entries, only the last one ends with a newline.
+func (t *containerTail) parse(data []byte) (*rpc.LogLine, bool) {
+	var entry dockerLogEntry
+	if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+		slog.Debug("Invalid container log entry", "container", t.info.Name, "err", err)
+		return nil, false
+	}
+
+	if !strings.HasSuffix(entry.Log, "\n") {
+		t.partial.WriteString(entry.Log)
+		return nil, false
+	}
+	message := entry.Log
+	if t.partial.Len() > 0 {
+		message = t.partial.String() + message
+		t.partial.Reset()
+	}
+	message = strings.TrimRight(message, "\r\n")
+
+	fields := make(map[string]any, len(t.fields)+1)
+	for key, value := range t.fields {
+		fields[key] = value
+	}
+	fields["stream"] = entry.Stream
+
+	timestamp := entry.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	line := &rpc.LogLine{
+		Message:    ansiRegex.ReplaceAllString(message, ""),
+		Timestamp:  timestamp,
+		Sequence:   t.sequence,
+		InstanceId: t.instance,
+		Fields:     fields,
+		ClientId:   t.client,
+	}
+	t.sequence++
+	return line, true
+}
@@ -1,71 +1,153 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
 	"github.com/nats-io/jwt/v2"
-	"github.com/nats-io/nkeys"
 )
 
+const usage = `Usage: nats-setup <command> [flags]
+
+Commands:
+  init            generate the operator, accounts and server user
+  rotate-account  replace the account signing key and server user
+  show            print the public keys and account claims
+
+Run nats-setup <command> -h for the flags of a command.
+`
+
+type setupFlags struct {
+	dir     string
+	conf    string
+	envFile string
+}
+
+func registerCommonFlags(fs *flag.FlagSet) *setupFlags {
+	flags := &setupFlags{}
+	fs.StringVar(&flags.dir, "dir", "nats-secrets", "directory holding the seeds and JWTs")
+	fs.StringVar(&flags.conf, "conf", "nats-server.conf", "nats-server.conf to write")
+	fs.StringVar(&flags.envFile, "env", ".env.nats", "env file to write")
+	return flags
+}
+
 func main() {
-	// 1. Create Operator (The Root of Trust)
-	opKp, _ := nkeys.CreateOperator()
-	opPub, _ := opKp.PublicKey()
-	opClaims := jwt.NewOperatorClaims(opPub)
-	opClaims.Name = "Svarog-Operator"
-	opJwt, _ := opClaims.Encode(opKp)
-
-	// 2. Create the SYSTEM Account (Required for JetStream)
-	sysKp, _ := nkeys.CreateAccount()
-	sysPub, _ := sysKp.PublicKey()
-	sysClaims := jwt.NewAccountClaims(sysPub)
-	sysClaims.Name = "SYS"
-	sysJwt, _ := sysClaims.Encode(opKp)
-
-	// 3. Create the APP Account (Main data account)
-	accKp, _ := nkeys.CreateAccount()
-	accPub, _ := accKp.PublicKey()
-	accSeed, _ := accKp.Seed()
-	accClaims := jwt.NewAccountClaims(accPub)
-	accClaims.Name = "APP"
-	accClaims.Limits.JetStreamLimits.DiskStorage = -1
-	accClaims.Limits.JetStreamLimits.MemoryStorage = -1
-	accJwt, _ := accClaims.Encode(opKp)
-
-	// 4. Create the "Admin" User (Inside the APP account)
-	// This is the user the server uses to connect
-	adminKp, _ := nkeys.CreateUser()
-	adminPub, _ := adminKp.PublicKey()
-	adminSeed, _ := adminKp.Seed()
-	adminClaims := jwt.NewUserClaims(adminPub)
-	adminClaims.Name = "admin"
-	adminClaims.IssuerAccount = accPub
-	adminClaims.Permissions.Pub.Allow.Add(">")
-	adminClaims.Permissions.Sub.Allow.Add(">")
-	adminJwt, _ := adminClaims.Encode(accKp)
-
-	// --- OUTPUT FOR .env ---
-	fmt.Println("# ===========================================================")
-	fmt.Println("# COPY TO YOUR .env FILE")
-	fmt.Println("# ===========================================================")
-	fmt.Printf("NATS_ACCOUNT_SEED=%s\n", accSeed)
-	fmt.Printf("NATS_SERVER_USER_JWT=%s\n", adminJwt)
-	fmt.Printf("NATS_SERVER_USER_SEED=%s\n", adminSeed)
-
-	// Optional: You still need NATS_ADDR and likely the Public Key for reference
-	fmt.Printf("NATS_ACCOUNT_PUBLIC_KEY=%s\n", accPub)
-
-	// --- OUTPUT FOR nats-server.conf ---
-	fmt.Println("\n# ===========================================================")
-	fmt.Println("# COPY TO YOUR nats-server.conf")
-	fmt.Println("# ===========================================================")
-	fmt.Printf(`operator: %s
-system_account: %s
-
-resolver: MEMORY
-resolver_preload: {
-    %s: %s,
-    %s: %s
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "init":
+		err = runInit(os.Args[2:])
+	case "rotate-account":
+		err = runRotateAccount(os.Args[2:])
+	case "show":
+		err = runShow(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 }
-`, opJwt, sysPub, accPub, accJwt, sysPub, sysJwt)
+
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	port := fs.Int("port", 4222, "client port of the NATS server")
+	storeDir := fs.String("store-dir", "/data/jetstream", "JetStream store directory")
+	inline := fs.Bool("inline", false, "write seeds into the env file instead of pointing to the secrets directory")
+	force := fs.Bool("force", false, "overwrite an existing trust, invalidating every issued credential")
+	fs.Parse(args)
+
+	if natstrust.Exists(flags.dir) && !*force {
+		return fmt.Errorf("%s already holds a trust, use rotate-account or pass -force", flags.dir)
+	}
+
+	trust, err := natstrust.Generate()
+	if err != nil {
+		return err
+	}
+
+	if err := trust.Save(flags.dir); err != nil {
+		return err
+	}
+	if err := trust.WriteServerConfig(flags.conf, natstrust.ServerConfigOptions{Port: *port, StoreDir: *storeDir}); err != nil {
+		return err
+	}
+	if err := trust.WriteEnvFile(flags.envFile, flags.dir, *inline); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote secrets to %s\n", flags.dir)
+	fmt.Printf("Wrote NATS server config to %s\n", flags.conf)
+	fmt.Printf("Wrote server environment to %s\n", flags.envFile)
+	return nil
+}
+
+func runRotateAccount(args []string) error {
+	fs := flag.NewFlagSet("rotate-account", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	inline := fs.Bool("inline", false, "write seeds into the env file instead of pointing to the secrets directory")
+	fs.Parse(args)
+
+	trust, err := natstrust.Load(flags.dir)
+	if err != nil {
+		return fmt.Errorf("failed to load trust from %s: %w", flags.dir, err)
+	}
+
+	if err := trust.RotateAccount(); err != nil {
+		return err
+	}
+
+	// Update the config first, a failure there leaves the old secrets usable
+	if err := trust.UpdateServerConfig(flags.conf); err != nil {
+		return fmt.Errorf("failed to update %s: %w", flags.conf, err)
+	}
+	if err := trust.Save(flags.dir); err != nil {
+		return err
+	}
+	if err := trust.WriteEnvFile(flags.envFile, flags.dir, *inline); err != nil {
+		return err
+	}
+
+	fmt.Printf("Rotated signing key of account %s\n", trust.Account.Public)
+	fmt.Println("Reload the NATS server and restart svarog, then issue new client credentials.")
+	return nil
+}
+
+func runShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	flags := registerCommonFlags(fs)
+	fs.Parse(args)
+
+	trust, err := natstrust.Load(flags.dir)
+	if err != nil {
+		return fmt.Errorf("failed to load trust from %s: %w", flags.dir, err)
+	}
+
+	account, err := jwt.DecodeAccountClaims(trust.Account.JWT)
+	if err != nil {
+		return fmt.Errorf("failed to decode account JWT: %w", err)
+	}
+
+	fmt.Printf("Operator:        %s\n", trust.Operator.Public)
+	fmt.Printf("System account:  %s\n", trust.System.Public)
+	fmt.Printf("Account:         %s (%s)\n", trust.Account.Public, account.Name)
+	fmt.Printf("  issued at:     %s\n", time.Unix(account.IssuedAt, 0).Format(time.RFC3339))
+	fmt.Printf("  signing keys:  %v\n", account.SigningKeys.Keys())
+	fmt.Printf("  revocations:   %d\n", len(account.Revocations))
+	fmt.Printf("Server user:     %s\n", trust.ServerUser.Public)
+	return nil
 }
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
+	nethttp "net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,17 +19,28 @@ import (
 	dotenv "github.com/joho/godotenv"
 	"github.com/markojerkic/svarog/internal/lib/auth"
 	"github.com/markojerkic/svarog/internal/lib/files"
+	"github.com/markojerkic/svarog/internal/lib/health"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
 	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
 	"github.com/markojerkic/svarog/internal/lib/projects"
 	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/lib/snippets"
+	"github.com/markojerkic/svarog/internal/lib/syslog"
 	"github.com/markojerkic/svarog/internal/lib/util"
+	"github.com/markojerkic/svarog/internal/rpc"
 	"github.com/markojerkic/svarog/internal/server/db"
 	"github.com/markojerkic/svarog/internal/server/http"
 	"github.com/markojerkic/svarog/internal/server/ingest"
 	"github.com/markojerkic/svarog/internal/server/types"
 	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func loadEnv() types.ServerEnv {
@@ -42,9 +57,43 @@ func loadEnv() types.ServerEnv {
 		log.Fatal("Error parsing env", "error", err)
 	}
 
+	if err := loadSecretFiles(&env); err != nil {
+		log.Fatal("Error loading NATS secrets", "error", err)
+	}
+
 	return env
 }
 
+// loadSecretFiles reads the NATS keys written by nats-setup. A *_FILE
+// variable takes precedence over the value set directly in the environment.
+func loadSecretFiles(env *types.ServerEnv) error {
+	secrets := []struct {
+		path  string
+		value *string
+	}{
+		{env.NatsAccountSeedFile, &env.NatsAccountSeed},
+		{env.NatsServerUserJWTFile, &env.NatsServerUserJWT},
+		{env.NatsServerUserSeedFile, &env.NatsServerUserSeed},
+		{env.NatsOperatorSeedFile, &env.NatsOperatorSeed},
+		{env.NatsAccountJWTFile, &env.NatsAccountJWT},
+		{env.NatsSystemUserJWTFile, &env.NatsSystemUserJWT},
+		{env.NatsSystemUserSeedFile, &env.NatsSystemUserSeed},
+	}
+
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+		value, err := natstrust.ReadSecretFile(secret.path)
+		if err != nil {
+			return err
+		}
+		*secret.value = value
+	}
+
+	return nil
+}
+
 func setupLogger() {
 	util.SetupLogger()
 }
@@ -61,37 +110,331 @@ func newMongoDB(connectionUrl string) (*mongo.Client, *mongo.Database, error) {
 	return client, database, nil
 }
 
+// newLogService creates the log storage selected with LOG_STORAGE. The MongoDB
+// or SQLite service is also returned so it can be closed on shutdown.
+func newLogService(env types.ServerEnv, database *mongo.Database, wsLoglineRenderer *websocket.WsLogLineRenderer) (db.LogService, *db.MongoLogService, *db.SqliteLogService) {
+	switch env.LogStorage {
+	case "", "mongo":
+		mongoLogs := db.NewLogService(database, wsLoglineRenderer)
+		return mongoLogs, mongoLogs, nil
+	case "sqlite":
+		sqliteLogs, err := db.NewSqliteLogService(env.SqlitePath, wsLoglineRenderer)
+		if err != nil {
+			log.Fatal("Failed to open SQLite log storage", "path", env.SqlitePath, "error", err)
+		}
+		log.Info("Storing logs in SQLite", "path", env.SqlitePath)
+		return sqliteLogs, nil, sqliteLogs
+	default:
+		log.Fatal("Unknown log storage", "LOG_STORAGE", env.LogStorage)
+		return nil, nil, nil
+	}
+}
+
 type serverDependencies struct {
-	httpServer    *http.HttpServer
-	ingestService *ingest.IngestService
-	natsConn      *natsconn.NatsConnection
-	mongoClient   *mongo.Client
-	cancel        context.CancelFunc
+	httpServer        *http.HttpServer
+	grpcServer        *grpc.Server
+	syslogServer      *ingest.SyslogServer
+	metricsServer     *nethttp.Server
+	ingestService     *ingest.IngestService
+	replayService     *ingest.ReplayService
+	logServer         db.AggregatingLogServer
+	logServerDone     <-chan struct{}
+	logIngestChannel  chan db.LogLineWithHost
+	wsLoglineRenderer *websocket.WsLogLineRenderer
+	natsConn          *natsconn.NatsConnection
+	systemConn        *natsconn.NatsConnection
+	natsServer        *server.Server
+	mongoClient       *mongo.Client
+	mongoLogs         *db.MongoLogService
+	sqliteLogs        *db.SqliteLogService
+	cancelIngest      context.CancelFunc
+	cancelLogServer   context.CancelFunc
+}
+
+// shutdownStep runs a single shutdown step with its own timeout.
+func shutdownStep(name string, timeout time.Duration, step func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := step(ctx); err != nil {
+		log.Error("Shutdown step failed", "step", name, "error", err, "took", time.Since(start))
+		return
+	}
+	log.Info("Shutdown step done", "step", name, "took", time.Since(start))
 }
 
+// gracefulShutdown stops the server in dependency order so lines that were
+// already acked on JetStream still end up in MongoDB.
 func gracefulShutdown(deps serverDependencies) {
 	log.Info("Shutting down server...")
 
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
+	shutdownStep("http", 10*time.Second, deps.httpServer.Shutdown)
+
+	if deps.grpcServer != nil {
+		shutdownStep("grpc", 10*time.Second, func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				deps.grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				deps.grpcServer.Stop()
+				return ctx.Err()
+			}
+		})
+	}
 
-	if err := deps.httpServer.Shutdown(shutdownCtx); err != nil {
-		log.Error("HTTP server shutdown error", "error", err)
+	if deps.syslogServer != nil {
+		shutdownStep("syslog", 10*time.Second, deps.syslogServer.Shutdown)
 	}
 
-	deps.ingestService.Stop()
+	if deps.metricsServer != nil {
+		shutdownStep("metrics", 5*time.Second, deps.metricsServer.Shutdown)
+	}
+
+	shutdownStep("stop consuming", 10*time.Second, func(ctx context.Context) error {
+		defer deps.cancelIngest()
+		if err := deps.replayService.CancelAll(ctx); err != nil {
+			return fmt.Errorf("replays still running: %w", err)
+		}
+		return deps.ingestService.Drain(ctx)
+	})
+
+	shutdownStep("flush backlog", 30*time.Second, func(ctx context.Context) error {
+		pending := len(deps.logIngestChannel) + deps.logServer.BacklogCount()
+		savedBefore := deps.logServer.SavedCount()
+
+		deps.cancelLogServer()
+		select {
+		case <-deps.logServerDone:
+			log.Info("Flushed log lines", "pending", pending, "flushed", deps.logServer.SavedCount()-savedBefore)
+			return nil
+		case <-ctx.Done():
+			log.Error("Log lines not flushed", "pending", pending, "flushed", deps.logServer.SavedCount()-savedBefore)
+			return ctx.Err()
+		}
+	})
+
+	shutdownStep("ws renderer", 5*time.Second, deps.wsLoglineRenderer.Shutdown)
 
-	deps.cancel()
+	shutdownStep("nats", 5*time.Second, func(ctx context.Context) error {
+		deps.natsConn.Close()
+		if deps.systemConn != nil {
+			deps.systemConn.Close()
+		}
+		return nil
+	})
 
-	deps.natsConn.Close()
+	if deps.natsServer != nil {
+		shutdownStep("embedded nats", 10*time.Second, func(ctx context.Context) error {
+			deps.natsServer.Shutdown()
+			deps.natsServer.WaitForShutdown()
+			return nil
+		})
+	}
+
+	if deps.sqliteLogs != nil {
+		shutdownStep("sqlite", 5*time.Second, deps.sqliteLogs.Close)
+	}
 
-	if err := deps.mongoClient.Disconnect(shutdownCtx); err != nil {
-		log.Error("MongoDB disconnect error", "error", err)
+	if deps.mongoLogs != nil {
+		shutdownStep("trigram backfill", 5*time.Second, deps.mongoLogs.Close)
 	}
 
+	shutdownStep("mongo", 10*time.Second, deps.mongoClient.Disconnect)
+
 	log.Info("Server stopped gracefully")
 }
 
+// ensureProjectStreams creates streams for projects created before
+// per-project streams were enabled.
+func ensureProjectStreams(projectsService projects.ProjectsService, natsConn *natsconn.NatsConnection) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projectList, err := projectsService.GetProjects(ctx)
+	if err != nil {
+		log.Error("Failed to list projects for stream setup", "error", err)
+		return
+	}
+
+	for _, project := range projectList {
+		if err := natsConn.EnsureProjectStream(ctx, project.ID.Hex()); err != nil {
+			log.Error("Failed to create project stream", "project", project.Name, "error", err)
+		}
+	}
+}
+
+// projectStreamNames lists the stream every project should have.
+func projectStreamNames(ctx context.Context, projectsService projects.ProjectsService, natsConn *natsconn.NatsConnection) ([]string, error) {
+	projectList, err := projectsService.GetProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(projectList))
+	for i, project := range projectList {
+		names[i] = natsConn.StreamName(project.ID.Hex())
+	}
+	return names, nil
+}
+
+// startGrpcServer serves the gRPC ingest transport on GPRC_PORT. It's
+// disabled when the port isn't set.
+func startGrpcServer(env types.ServerEnv, natsConn *natsconn.NatsConnection, registry serverauth.CredentialRegistry) *grpc.Server {
+	if env.GrpcServerPort == 0 {
+		return nil
+	}
+
+	verifier, err := serverauth.NewCredentialVerifier(env.NatsAccountSeed, env.NatsAccountPublicKey, registry)
+	if err != nil {
+		log.Fatal("Failed to create credential verifier", "error", err)
+	}
+
+	var opts []grpc.ServerOption
+	if env.GrpcTLSCertFile != "" {
+		tlsCreds, err := credentials.NewServerTLSFromFile(env.GrpcTLSCertFile, env.GrpcTLSKeyFile)
+		if err != nil {
+			log.Fatal("Failed to load gRPC TLS certificate", "error", err)
+		}
+		opts = append(opts, grpc.Creds(tlsCreds))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	rpc.RegisterLogIngestServer(grpcServer, ingest.NewGrpcIngestServer(natsConn, verifier))
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", env.GrpcServerPort))
+	if err != nil {
+		log.Fatal("Failed to listen for gRPC", "port", env.GrpcServerPort, "error", err)
+	}
+	go func() {
+		log.Info("Starting gRPC ingest server", "port", env.GrpcServerPort, "tls", env.GrpcTLSCertFile != "")
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Info("gRPC server stopped", "error", err)
+		}
+	}()
+
+	return grpcServer
+}
+
+// startMetricsServer serves the Prometheus metrics on METRICS_PORT. It's
+// disabled when the port isn't set.
+func startMetricsServer(env types.ServerEnv) *nethttp.Server {
+	if env.MetricsPort == 0 {
+		return nil
+	}
+
+	mux := nethttp.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	metricsServer := &nethttp.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", env.MetricsPort))
+	if err != nil {
+		log.Fatal("Failed to listen for metrics", "port", env.MetricsPort, "error", err)
+	}
+	go func() {
+		log.Info("Starting metrics server", "port", env.MetricsPort)
+		if err := metricsServer.Serve(listener); err != nil {
+			log.Info("Metrics server stopped", "error", err)
+		}
+	}()
+
+	return metricsServer
+}
+
+// startSyslogServer serves syslog on SYSLOG_UDP_PORT and SYSLOG_TCP_PORT.
+// It's disabled when neither port is set.
+func startSyslogServer(env types.ServerEnv, receiver *ingest.Receiver) *ingest.SyslogServer {
+	if env.SyslogUDPPort == 0 && env.SyslogTCPPort == 0 {
+		return nil
+	}
+
+	rules, err := syslog.LoadRules(env.SyslogRulesFile)
+	if err != nil {
+		log.Fatal("Failed to load syslog rules", "file", env.SyslogRulesFile, "error", err)
+	}
+
+	var tlsConfig *tls.Config
+	if env.SyslogTLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(env.SyslogTLSCertFile, env.SyslogTLSKeyFile)
+		if err != nil {
+			log.Fatal("Failed to load syslog TLS certificate", "error", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	}
+
+	syslogServer := ingest.NewSyslogServer(rules, receiver)
+	if env.SyslogUDPPort != 0 {
+		if _, err := syslogServer.ListenUDP(fmt.Sprintf(":%d", env.SyslogUDPPort)); err != nil {
+			log.Fatal("Failed to start syslog listener", "error", err)
+		}
+		log.Info("Starting syslog UDP listener", "port", env.SyslogUDPPort)
+	}
+	if env.SyslogTCPPort != 0 {
+		if _, err := syslogServer.ListenTCP(fmt.Sprintf(":%d", env.SyslogTCPPort), tlsConfig); err != nil {
+			log.Fatal("Failed to start syslog listener", "error", err)
+		}
+		log.Info("Starting syslog TCP listener", "port", env.SyslogTCPPort, "tls", tlsConfig != nil)
+	}
+
+	return syslogServer
+}
+
+func syncRevocations(revoker *serverauth.AccountRevoker) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := revoker.Sync(ctx); err != nil {
+		log.Error("Failed to sync revoked credentials", "error", err)
+	}
+}
+
+// setupAccountRevoker connects as the system user so revoked credentials can be
+// pushed to the NATS server. Without the operator seed and system user,
+// revocations are only recorded.
+func setupAccountRevoker(env types.ServerEnv, natsServer *server.Server, registry serverauth.CredentialRegistry) (*serverauth.AccountRevoker, *natsconn.NatsConnection) {
+	if env.NatsOperatorSeed == "" || env.NatsAccountJWT == "" || env.NatsSystemUserJWT == "" || env.NatsSystemUserSeed == "" {
+		log.Warn("NATS operator seed or system user not configured, revoked credentials stay valid until they expire")
+		return nil, nil
+	}
+
+	var revoker *serverauth.AccountRevoker
+	systemConnConfig := natsconn.NatsConnectionConfig{
+		NatsAddr: env.NatsAddr,
+		JWT:      env.NatsSystemUserJWT,
+		Seed:     env.NatsSystemUserSeed,
+		// A restarted server starts from the preloaded account JWT again
+		OnReconnect: func() {
+			if revoker != nil {
+				syncRevocations(revoker)
+			}
+		},
+	}
+	if natsServer != nil {
+		systemConnConfig.InProcessServer = natsServer
+	}
+	systemConn, err := natsconn.NewNatsConnection(systemConnConfig)
+	if err != nil {
+		log.Fatal("Failed to connect to NATS as the system user", "error", err)
+	}
+
+	revoker, err = serverauth.NewAccountRevoker(env.NatsOperatorSeed, env.NatsAccountJWT, systemConn.Conn, registry)
+	if err != nil {
+		log.Fatal("Failed to create account revoker", "error", err)
+	}
+
+	syncRevocations(revoker)
+	if _, err := revoker.TrackLastSeen(); err != nil {
+		log.Error("Failed to track credential usage", "error", err)
+	}
+
+	return revoker, systemConn
+}
+
 func main() {
 	setupLogger()
 	env := loadEnv()
@@ -105,34 +448,76 @@ func main() {
 	sessionCollection := database.Collection("sessions")
 	filesCollectinon := database.Collection("files")
 	projectsCollection := database.Collection("projects")
+	pipelinesCollection := database.Collection("pipelines")
+	credentialsCollection := database.Collection("nats_credentials")
+	replayJobsCollection := database.Collection("replay_jobs")
 
 	projectsService := projects.NewProjectsService(projectsCollection, client)
+	pipelinesService := pipelines.NewPipelinesService(pipelinesCollection)
+	pipelineProcessor := pipelines.NewProcessor(pipelinesService)
 
-	natsCredService, err := serverauth.NewNatsCredentialService(env.NatsAccountSeed, env.NatsPublicAddr, projectsService)
+	credentialRegistry := serverauth.NewCredentialRegistry(credentialsCollection)
+	natsCredService, err := serverauth.NewNatsCredentialService(env.NatsAccountSeed, env.NatsAccountPublicKey, env.NatsPublicAddr, projectsService, credentialRegistry)
 	if err != nil {
 		log.Fatal("Failed to create credential service", "error", err)
 	}
+	ingestTokenService, err := serverauth.NewIngestTokenService(projectsService, credentialRegistry)
+	if err != nil {
+		log.Fatal("Failed to create ingest token service", "error", err)
+	}
 
-	slog.Debug("Starting NATS connection", "addr", env.NatsAddr, "publicAddr", env.NatsPublicAddr)
-	natsConn, err := natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+	streamStorage, err := natsconn.ParseStorageType(env.NatsStreamStorage)
+	if err != nil {
+		log.Fatal("Invalid NATS_STREAM_STORAGE", "error", err)
+	}
+
+	var natsServer *server.Server
+	if env.NatsEmbedded {
+		natsServer, err = natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{
+			ConfigFile: env.NatsEmbeddedConfig,
+			StoreDir:   env.NatsEmbeddedStoreDir,
+			Port:       env.NatsEmbeddedPort,
+		})
+		if err != nil {
+			log.Fatal("Failed to start embedded NATS server", "error", err)
+		}
+	}
+
+	slog.Debug("Starting NATS connection", "addr", env.NatsAddr, "publicAddr", env.NatsPublicAddr, "embedded", env.NatsEmbedded)
+	natsConnConfig := natsconn.NatsConnectionConfig{
 		NatsAddr:        env.NatsAddr,
 		JWT:             env.NatsServerUserJWT,
 		Seed:            env.NatsServerUserSeed,
 		EnableJetStream: true,
 		JetStreamConfig: natsconn.JetStreamConfig{
-			Name:     "LOGS",
-			Subjects: []string{"logs.>"},
+			Name:       "LOGS",
+			Subjects:   []string{"logs.>"},
+			MaxBytes:   env.NatsStreamMaxBytes,
+			MaxAge:     env.NatsStreamMaxAge,
+			Storage:    streamStorage,
+			Replicas:   env.NatsStreamReplicas,
+			PerProject: env.NatsStreamPerProject,
 		},
-	})
+	}
+	if natsServer != nil {
+		natsConnConfig.InProcessServer = natsServer
+	}
+	natsConn, err := natsconn.NewNatsConnection(natsConnConfig)
 	if err != nil {
 		log.Fatal("Failed to connect to NATS", "error", err)
 	}
 
+	accountRevoker, systemConn := setupAccountRevoker(env, natsServer, credentialRegistry)
+
+	if natsConn.PerProjectStreams() {
+		ensureProjectStreams(projectsService, natsConn)
+	}
+
 	watchHub := websocket.NewWatchHub(natsConn.Conn)
 	wsLoglineRenderer := websocket.NewWsLogLineRenderer(watchHub)
 
 	sessionStore := auth.NewMongoSessionStore(sessionCollection, userCollection, []byte(env.SessionSecret))
-	logsService := db.NewLogService(database, wsLoglineRenderer)
+	logsService, mongoLogs, sqliteLogs := newLogService(env, database, wsLoglineRenderer)
 	logServer := db.NewLogServer(logsService)
 
 	authService := auth.NewMongoAuthService(userCollection, sessionCollection, client, sessionStore)
@@ -141,7 +526,45 @@ func main() {
 	authService.CreateInitialAdminUser(context.Background())
 
 	logIngestChannel := make(chan db.LogLineWithHost, 1000)
-	ingestService := ingest.NewIngestService(logIngestChannel, natsConn)
+	clientRegistrar := ingest.NewClientRegistrar(projectsService)
+	ingestService := ingest.NewIngestService(logIngestChannel, natsConn, pipelineProcessor, clientRegistrar)
+	receiver := ingest.NewReceiver(natsConn, clientRegistrar)
+	replayService := ingest.NewReplayService(ingestService, replayJobsCollection)
+
+	metrics.RegisterGaugeFunc("backlog_size", "Number of log lines waiting in the backlog.", func() float64 {
+		return float64(logServer.BacklogCount())
+	})
+	metrics.RegisterGaugeFunc("ingest_channel_size", "Number of log lines waiting in the ingest channel.", func() float64 {
+		return float64(len(logIngestChannel))
+	})
+	metrics.RegisterGaugeFunc("jetstream_consumer_pending", "Number of JetStream messages not yet delivered to the ingest consumer.", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		pending, err := ingestService.PendingMessages(ctx)
+		if err != nil {
+			slog.Error("Failed to get consumer pending count", "err", err)
+			return 0
+		}
+		return float64(pending)
+	})
+
+	healthService := health.NewHealthService(
+		health.MongoCheck(client),
+		health.NatsCheck(natsConn.Conn),
+		health.Check{Name: "ingest", Check: ingestService.Healthy},
+		health.SaturationCheck("backlog", 0.9, logServer.BacklogCount, logServer.BacklogCapacity()),
+		health.SaturationCheck("ingest_channel", 0.9, func() int { return len(logIngestChannel) }, cap(logIngestChannel)),
+	)
+	if sqliteLogs != nil {
+		healthService.AddCheck(health.Check{Name: "sqlite", Check: sqliteLogs.Ping})
+	}
+	if natsConn.PerProjectStreams() {
+		healthService.AddCheck(health.StreamsCheck(natsConn.JetStream, func(ctx context.Context) ([]string, error) {
+			return projectStreamNames(ctx, projectsService, natsConn)
+		}))
+	} else {
+		healthService.AddCheck(health.StreamCheck(natsConn.JetStream, natsConn.StreamName("")))
+	}
 
 	httpServer := http.NewServer(
 		http.HttpServerOptions{
@@ -152,17 +575,38 @@ func main() {
 			FilesService:          filesService,
 			ProjectsService:       projectsService,
 			NatsCredentialService: natsCredService,
+			CredentialRegistry:    credentialRegistry,
+			AccountRevoker:        accountRevoker,
+			NatsConnection:        natsConn,
+			SnippetGenerator:      snippets.NewGenerator(env.ClientImage),
+			PipelinesService:      pipelinesService,
+			PipelineProcessor:     pipelineProcessor,
+			HealthService:         healthService,
+			ReplayService:         replayService,
+			IngestTokenService:    ingestTokenService,
+			Receiver:              receiver,
 			WatchHub:              watchHub,
 		})
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ingestCtx, cancelIngest := context.WithCancel(context.Background())
+	logServerCtx, cancelLogServer := context.WithCancel(context.Background())
 
 	// Signal handling for graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	go logServer.Run(ctx, logIngestChannel)
-	go ingestService.Run(ctx)
+	logServerDone := make(chan struct{})
+	go func() {
+		defer close(logServerDone)
+		logServer.Run(logServerCtx, logIngestChannel)
+	}()
+	go ingestService.Run(ingestCtx)
+	if err := replayService.Resume(context.Background()); err != nil {
+		log.Error("Failed to resume replay jobs", "error", err)
+	}
+	grpcServer := startGrpcServer(env, natsConn, credentialRegistry)
+	syslogServer := startSyslogServer(env, receiver)
+	metricsServer := startMetricsServer(env)
 	go func() {
 		if err := httpServer.Start(); err != nil {
 			log.Info("HTTP server stopped", "error", err)
@@ -171,10 +615,23 @@ func main() {
 
 	<-quit
 	gracefulShutdown(serverDependencies{
-		httpServer:    httpServer,
-		ingestService: ingestService,
-		natsConn:      natsConn,
-		mongoClient:   client,
-		cancel:        cancel,
+		httpServer:        httpServer,
+		grpcServer:        grpcServer,
+		syslogServer:      syslogServer,
+		metricsServer:     metricsServer,
+		ingestService:     ingestService,
+		replayService:     replayService,
+		logServer:         logServer,
+		logServerDone:     logServerDone,
+		logIngestChannel:  logIngestChannel,
+		wsLoglineRenderer: wsLoglineRenderer,
+		natsConn:          natsConn,
+		systemConn:        systemConn,
+		natsServer:        natsServer,
+		mongoClient:       client,
+		mongoLogs:         mongoLogs,
+		sqliteLogs:        sqliteLogs,
+		cancelIngest:      cancelIngest,
+		cancelLogServer:   cancelLogServer,
 	})
 }
@@ -1,6 +1,9 @@
 package backlog
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 type Backlog[T any] interface {
 	GetLogs() <-chan []T
@@ -10,6 +13,8 @@ type Backlog[T any] interface {
 	IsEmpty() bool
 	IsFull() bool
 	Count() int
+	Capacity() int
+	Release(count int)
 	Close()
 }
 
@@ -21,24 +26,47 @@ type IBacklog[T any] struct {
 	backlog     chan []T
 
 	index int
+
+	// queued counts the lines added and not yet released, dumped the lines
+	// dumped and not yet released. Dumping waits while dumped would go over
+	// maxLines.
+	queued    atomic.Int64
+	maxLines  int
+	spaceLock sync.Mutex
+	space     *sync.Cond
+	dumped    int
 }
 
 var _ Backlog[any] = &IBacklog[any]{}
 
 var backlogLimit = 1000
 
+// GetLogs returns the dumped batches. It doesn't lock, a dump waiting for
+// space holds the lock until batches are taken from it.
 func (self *IBacklog[T]) GetLogs() <-chan []T {
-	self.Lock()
-	defer self.Unlock()
-
 	return self.backlog
 }
 
+// Count returns the number of logs added and not yet released.
 func (self *IBacklog[T]) Count() int {
-	self.Lock()
-	defer self.Unlock()
+	return int(self.queued.Load())
+}
+
+// Capacity returns the number of dumped logs at which adding more blocks
+// until some are released.
+func (self *IBacklog[T]) Capacity() int {
+	return self.maxLines
+}
 
-	return self.index
+// Release frees the space of count logs taken from GetLogs once they are
+// handled.
+func (self *IBacklog[T]) Release(count int) {
+	self.queued.Add(-int64(count))
+
+	self.spaceLock.Lock()
+	self.dumped -= count
+	self.spaceLock.Unlock()
+	self.space.Broadcast()
 }
 
 func (self *IBacklog[T]) dump(index int) {
@@ -52,6 +80,13 @@ func (self *IBacklog[T]) dump(index int) {
 		return
 	}
 
+	self.spaceLock.Lock()
+	for self.dumped > 0 && self.dumped+len(logsToBeDumped) > self.maxLines {
+		self.space.Wait()
+	}
+	self.dumped += len(logsToBeDumped)
+	self.spaceLock.Unlock()
+
 	self.backlog <- logsToBeDumped
 }
 
@@ -69,6 +104,7 @@ func (self *IBacklog[T]) AddToBacklog(log T) {
 
 	self.workingLogs[self.index] = log
 	self.index = (self.index + 1) % backlogLimit
+	self.queued.Add(1)
 
 	if self.index == 0 {
 		self.dump(backlogLimit)
@@ -84,6 +120,7 @@ func (self *IBacklog[T]) AddAllToBacklog(logs []T) {
 		self.workingLogs[self.index] = log
 		self.index = (self.index + 1) % backlogLimit
 	}
+	self.queued.Add(int64(len(logs)))
 
 	self.ForceDump()
 }
@@ -109,12 +146,14 @@ func (self *IBacklog[T]) Close() {
 	close(self.backlog)
 }
 
-func NewBacklog[T any](backlogSize int) Backlog[T] {
-	return &IBacklog[T]{
-		sync.Mutex{},
-		sync.Mutex{},
-		make([]T, backlogLimit),
-		make(chan []T, backlogSize),
-		0,
+// NewBacklog returns a backlog holding up to maxLines dumped logs.
+func NewBacklog[T any](maxLines int) Backlog[T] {
+	backlog := &IBacklog[T]{
+		workingLogs: make([]T, backlogLimit),
+		// Every dumped batch has at least one log
+		backlog:  make(chan []T, maxLines),
+		maxLines: maxLines,
 	}
+	backlog.space = sync.NewCond(&backlog.spaceLock)
+	return backlog
 }
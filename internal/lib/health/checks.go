@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func MongoCheck(client *mongo.Client) Check {
+	return Check{
+		Name: "mongo",
+		Check: func(ctx context.Context) error {
+			return client.Ping(ctx, readpref.Primary())
+		},
+	}
+}
+
+func NatsCheck(conn *nats.Conn) Check {
+	return Check{
+		Name: "nats",
+		Check: func(ctx context.Context) error {
+			if status := conn.Status(); status != nats.CONNECTED {
+				return fmt.Errorf("connection is %s", status)
+			}
+			return nil
+		},
+	}
+}
+
+func StreamCheck(js jetstream.JetStream, streamName string) Check {
+	return StreamsCheck(js, func(ctx context.Context) ([]string, error) {
+		return []string{streamName}, nil
+	})
+}
+
+// StreamsCheck fails when any of the streams listed by streamNames is missing.
+func StreamsCheck(js jetstream.JetStream, streamNames func(ctx context.Context) ([]string, error)) Check {
+	return Check{
+		Name: "jetstream",
+		Check: func(ctx context.Context) error {
+			if js == nil {
+				return errors.New("JetStream is not enabled")
+			}
+			names, err := streamNames(ctx)
+			if err != nil {
+				return err
+			}
+			missing := []string{}
+			for _, name := range names {
+				if _, err := js.Stream(ctx, name); errors.Is(err, jetstream.ErrStreamNotFound) {
+					missing = append(missing, name)
+				} else if err != nil {
+					return fmt.Errorf("stream %s: %w", name, err)
+				}
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("missing streams: %v", missing)
+			}
+			return nil
+		},
+	}
+}
+
+// SaturationCheck fails when used/capacity reaches the threshold (0..1).
+func SaturationCheck(name string, threshold float64, used func() int, capacity int) Check {
+	return Check{
+		Name: name,
+		Check: func(ctx context.Context) error {
+			if capacity <= 0 {
+				return nil
+			}
+			current := used()
+			if float64(current)/float64(capacity) >= threshold {
+				return fmt.Errorf("%d of %d slots used", current, capacity)
+			}
+			return nil
+		},
+	}
+}
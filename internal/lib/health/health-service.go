@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+const checkTimeout = 3 * time.Second
+
+// Check is a single named readiness dependency.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type HealthService struct {
+	checks []Check
+}
+
+func NewHealthService(checks ...Check) *HealthService {
+	return &HealthService{checks: checks}
+}
+
+func (h *HealthService) AddCheck(check Check) {
+	h.checks = append(h.checks, check)
+}
+
+// Ready runs all checks concurrently. The report is ok only if every check passed.
+func (h *HealthService) Ready(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOk,
+		Checks: make(map[string]CheckResult, len(h.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			result := CheckResult{
+				Status:    StatusOk,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil {
+				report.Status = StatusFail
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
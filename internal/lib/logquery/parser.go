@@ -0,0 +1,384 @@
+package logquery
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenRegex
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+	end   int
+}
+
+// fieldPrefix is the start of a word like level:error or fields.status>=500
+var fieldPrefix = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_-]+)?)(>=|<=|:|=|>|<)`)
+
+// Parse parses a search query.
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }
+//	unary   = "NOT" unary | primary
+//	primary = "(" or ")" | word | "phrase" | /regex/ | field op value
+//
+// Terms next to each other must all match. Words containing ":" and words
+// that are operators are searched for by quoting them. A word starting with
+// "/" is a regular expression only when it also ends with "/", so paths like
+// /v2/orders are words.
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return &Query{Source: query}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		if next.kind == tokenRParen {
+			return nil, &SyntaxError{Pos: next.pos, Message: "unexpected )"}
+		}
+		return nil, &SyntaxError{Pos: next.pos, Message: fmt.Sprintf("unexpected %q", next.value)}
+	}
+
+	return &Query{Source: query, Root: root}, nil
+}
+
+func lex(query string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i, end: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i, end: i + 1})
+			i++
+		case r == '"':
+			value, end, err := lexDelimited(query, i, '"')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: i, end: end})
+			i = end
+		case r == '/':
+			value, end, err := lexDelimited(query, i, '/')
+			fieldValue := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenWord && tokens[len(tokens)-1].end == i
+			if !fieldValue && (err != nil || !endsToken(query, end)) {
+				// A path like /v2/orders
+				end = lexWord(query, i)
+				tokens = append(tokens, token{kind: tokenWord, value: query[i:end], pos: i, end: end})
+				i = end
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenRegex, value: value, pos: i, end: end})
+			i = end
+		default:
+			end := lexWord(query, i)
+			word := query[i:end]
+			kind := tokenWord
+			switch word {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind: kind, value: word, pos: i, end: end})
+			i = end
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(query), end: len(query)}), nil
+}
+
+// lexWord returns the end of the word starting at start. A word ends before a
+// quote or a regex that is the value of a field.
+func lexWord(query string, start int) int {
+	for i := start; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		if unicode.IsSpace(r) || r == '(' || r == ')' || r == '"' {
+			return i
+		}
+		if r == '/' && i > start && fieldPrefix.FindString(query[start:i]) == query[start:i] {
+			return i
+		}
+		i += size
+	}
+	return len(query)
+}
+
+// endsToken reports whether a token ending at end isn't followed by more of
+// the same word.
+func endsToken(query string, end int) bool {
+	if end >= len(query) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(query[end:])
+	return unicode.IsSpace(r) || r == ')'
+}
+
+// lexDelimited reads a "string" or /regex/ starting at start, the delimiter
+// is escaped with a backslash.
+func lexDelimited(query string, start int, delimiter byte) (string, int, error) {
+	var value strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && i+1 < len(query) && query[i+1] == delimiter:
+			value.WriteByte(delimiter)
+			i++
+		case query[i] == '\\' && delimiter == '"' && i+1 < len(query) && query[i+1] == '\\':
+			value.WriteByte('\\')
+			i++
+		case query[i] == delimiter:
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(query[i])
+		}
+	}
+
+	if delimiter == '"' {
+		return "", 0, &SyntaxError{Pos: start, Message: "unterminated quote"}
+	}
+	return "", 0, &SyntaxError{Pos: start, Message: "unterminated regular expression"}
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Node{first}
+
+	for p.peek().kind == tokenOr {
+		p.advance()
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return Or{Position: first.Pos(), Terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Node{first}
+
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.advance()
+		case tokenWord, tokenString, tokenRegex, tokenLParen, tokenNot:
+		default:
+			if len(terms) == 1 {
+				return first, nil
+			}
+			return And{Position: first.Pos(), Terms: terms}, nil
+		}
+
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	next := p.peek()
+	if next.kind == tokenNot {
+		p.advance()
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Position: next.pos, Term: term}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	next := p.advance()
+
+	switch next.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Message: "missing )"}
+		}
+		return inner, nil
+	case tokenString:
+		if next.value == "" {
+			return nil, &SyntaxError{Pos: next.pos, Message: "empty phrase"}
+		}
+		return Text{Position: next.pos, Value: next.value}, nil
+	case tokenRegex:
+		return newRegex(next.pos, FieldMessage, next.value)
+	case tokenWord:
+		return p.parseWord(next)
+	case tokenEOF:
+		return nil, &SyntaxError{Pos: next.pos, Message: "unexpected end of query"}
+	default:
+		return nil, &SyntaxError{Pos: next.pos, Message: fmt.Sprintf("unexpected %q", next.value)}
+	}
+}
+
+func (p *parser) parseWord(word token) (Node, error) {
+	prefix := fieldPrefix.FindStringSubmatch(word.value)
+	if prefix == nil {
+		return Text{Position: word.pos, Value: word.value}, nil
+	}
+
+	field, ok := lookupField(prefix[1])
+	if !ok {
+		return nil, &SyntaxError{Pos: word.pos, Message: fmt.Sprintf("unknown field %q, quote the word to search for it", prefix[1])}
+	}
+	op := Operator(prefix[2])
+	valuePos := word.pos + len(prefix[0])
+	value := word.value[len(prefix[0]):]
+
+	if value == "" {
+		// The value is a "phrase" or /regex/ right after the operator
+		next := p.peek()
+		if next.pos != word.end || (next.kind != tokenString && next.kind != tokenRegex) {
+			return nil, &SyntaxError{Pos: valuePos, Message: fmt.Sprintf("missing value of %s", prefix[1])}
+		}
+		p.advance()
+		if next.kind == tokenRegex {
+			if op != OpMatch {
+				return nil, &SyntaxError{Pos: word.pos, Message: "regular expressions are matched with :"}
+			}
+			return newRegex(word.pos, field, next.value)
+		}
+		value = next.value
+	}
+
+	return newCompare(word.pos, valuePos, field, op, value)
+}
+
+func newRegex(pos int, field Field, pattern string) (Node, error) {
+	if pattern == "" {
+		return nil, &SyntaxError{Pos: pos, Message: "empty regular expression"}
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, &SyntaxError{Pos: pos, Message: fmt.Sprintf("invalid regular expression: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))}
+	}
+	// MongoDB runs regular expressions with PCRE, which backtracks
+	if hasNestedRepeat(parsed, false) {
+		return nil, &SyntaxError{Pos: pos, Message: "invalid regular expression: repeated groups can't contain repetitions, like (a+)+"}
+	}
+	return Regex{Position: pos, Field: field, Pattern: pattern}, nil
+}
+
+// hasNestedRepeat reports whether an unbounded repetition is repeated again,
+// which takes exponential time to fail in a backtracking engine.
+func hasNestedRepeat(re *syntax.Regexp, repeated bool) bool {
+	unbounded := re.Op == syntax.OpStar || re.Op == syntax.OpPlus ||
+		(re.Op == syntax.OpRepeat && (re.Max == -1 || re.Max > 1))
+	if unbounded && repeated {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasNestedRepeat(sub, repeated || unbounded) {
+			return true
+		}
+	}
+	return false
+}
+
+func newCompare(pos int, valuePos int, field Field, op Operator, value string) (Node, error) {
+	if value == "" {
+		return nil, &SyntaxError{Pos: valuePos, Message: fmt.Sprintf("missing value of %s", field)}
+	}
+
+	var number *float64
+	if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+		number = &parsed
+	}
+
+	if op != OpMatch && op != OpEqual {
+		if _, custom := field.Custom(); !custom {
+			return nil, &SyntaxError{Pos: pos, Message: fmt.Sprintf("%s can't be compared with %s", field, op)}
+		}
+		if number == nil {
+			return nil, &SyntaxError{Pos: valuePos, Message: fmt.Sprintf("%s needs a number", op)}
+		}
+	}
+
+	switch field {
+	case FieldMessage:
+		return Text{Position: pos, Value: value}, nil
+	case FieldLevel:
+		if level := pipelines.NormalizeLevel(value); level != "" {
+			value = level
+		}
+	}
+
+	return Compare{Position: pos, Field: field, Op: op, Value: value, Number: number}, nil
+}
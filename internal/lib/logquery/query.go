@@ -0,0 +1,170 @@
+package logquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Field is a searchable attribute of a log line, either one of the Field*
+// constants or "fields.<key>" for a field extracted at ingest.
+type Field string
+
+const (
+	FieldMessage  Field = "message"
+	FieldLevel    Field = "level"
+	FieldInstance Field = "instance"
+	FieldClient   Field = "client"
+	FieldTrace    Field = "trace"
+	FieldSpan     Field = "span"
+)
+
+const customFieldPrefix = "fields."
+
+var fieldAliases = map[string]Field{
+	"message":  FieldMessage,
+	"msg":      FieldMessage,
+	"level":    FieldLevel,
+	"instance": FieldInstance,
+	"client":   FieldClient,
+	"trace":    FieldTrace,
+	"trace_id": FieldTrace,
+	"span":     FieldSpan,
+	"span_id":  FieldSpan,
+}
+
+var customFieldKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// lookupField resolves a field name of a query.
+func lookupField(name string) (Field, bool) {
+	if field, ok := fieldAliases[strings.ToLower(name)]; ok {
+		return field, true
+	}
+	if key, ok := strings.CutPrefix(name, customFieldPrefix); ok && customFieldKey.MatchString(key) {
+		return Field(name), true
+	}
+	return "", false
+}
+
+// Custom returns the key of a "fields.<key>" field.
+func (f Field) Custom() (string, bool) {
+	return strings.CutPrefix(string(f), customFieldPrefix)
+}
+
+type Operator string
+
+const (
+	// OpMatch is field:value, containing the value for the message and equal
+	// to it for other fields
+	OpMatch        Operator = ":"
+	OpEqual        Operator = "="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+)
+
+// Node is an expression of a parsed query.
+type Node interface {
+	// Pos is the byte offset of the expression in the query
+	Pos() int
+}
+
+type And struct {
+	Position int
+	Terms    []Node
+}
+
+type Or struct {
+	Position int
+	Terms    []Node
+}
+
+type Not struct {
+	Position int
+	Term     Node
+}
+
+// Text matches lines whose message contains a word or "quoted phrase".
+type Text struct {
+	Position int
+	Value    string
+}
+
+// Compare matches a field against a value. Comparisons other than OpMatch
+// and OpEqual are numeric and only match fields with number values.
+type Compare struct {
+	Position int
+	Field    Field
+	Op       Operator
+	Value    string
+	// Number is set when the value is a number
+	Number *float64
+}
+
+// Regex matches lines whose field matches a /regular expression/.
+type Regex struct {
+	Position int
+	Field    Field
+	Pattern  string
+}
+
+func (n And) Pos() int     { return n.Position }
+func (n Or) Pos() int      { return n.Position }
+func (n Not) Pos() int     { return n.Position }
+func (n Text) Pos() int    { return n.Position }
+func (n Compare) Pos() int { return n.Position }
+func (n Regex) Pos() int   { return n.Position }
+
+// Query is a parsed search. Root is nil for an empty query.
+type Query struct {
+	Source string
+	Root   Node
+}
+
+func (q *Query) IsEmpty() bool {
+	return q == nil || q.Root == nil
+}
+
+// TextTerms splits the words and phrases that all matching lines must
+// contain from the rest of the query, so stores can look them up in their
+// full text index. rest is nil when nothing else is left.
+func (q *Query) TextTerms() (terms []string, rest Node) {
+	if q.IsEmpty() {
+		return nil, nil
+	}
+
+	conjunction := []Node{q.Root}
+	if and, ok := q.Root.(And); ok {
+		conjunction = and.Terms
+	}
+
+	others := []Node{}
+	for _, term := range conjunction {
+		if text, ok := term.(Text); ok {
+			terms = append(terms, text.Value)
+		} else {
+			others = append(others, term)
+		}
+	}
+
+	switch len(others) {
+	case 0:
+		return terms, nil
+	case 1:
+		return terms, others[0]
+	default:
+		return terms, And{Position: q.Root.Pos(), Terms: others}
+	}
+}
+
+// SyntaxError is returned for invalid queries, Pos is the byte offset of the
+// error in the query.
+type SyntaxError struct {
+	Pos     int    `json:"position"`
+	Message string `json:"message"`
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Message, e.Pos)
+}
@@ -0,0 +1,119 @@
+package logquery
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// Trigrams returns the distinct lowercase three character substrings of
+// text, which are stored with every line for substring search.
+func Trigrams(text string) []string {
+	runes := []rune(strings.ToLower(text))
+
+	seen := map[string]bool{}
+	trigrams := []string{}
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if !seen[trigram] {
+			seen[trigram] = true
+			trigrams = append(trigrams, trigram)
+		}
+	}
+	return trigrams
+}
+
+// RequiredTrigrams returns the trigrams every line matching the query in
+// substring mode contains, from the words, phrases and message regular
+// expressions all lines must match. It is empty when the query can't be
+// looked up in the trigram index.
+func (q *Query) RequiredTrigrams() []string {
+	if q.IsEmpty() {
+		return nil
+	}
+
+	conjunction := []Node{q.Root}
+	if and, ok := q.Root.(And); ok {
+		conjunction = and.Terms
+	}
+
+	required := map[string]bool{}
+	trigrams := []string{}
+	add := func(found []string) {
+		for _, trigram := range found {
+			if !required[trigram] {
+				required[trigram] = true
+				trigrams = append(trigrams, trigram)
+			}
+		}
+	}
+
+	for _, term := range conjunction {
+		switch term := term.(type) {
+		case Text:
+			add(Trigrams(term.Value))
+		case Regex:
+			if term.Field == FieldMessage {
+				add(regexTrigrams(term.Pattern))
+			}
+		}
+	}
+	return trigrams
+}
+
+// regexTrigrams returns the trigrams of the literal text every match of the
+// pattern contains.
+func regexTrigrams(pattern string) []string {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	trigrams := []string{}
+	for _, literal := range requiredLiterals(parsed.Simplify()) {
+		trigrams = append(trigrams, Trigrams(literal)...)
+	}
+	return trigrams
+}
+
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Neighbouring literals are joined, so trigrams can span them
+		literals := []string{}
+		var run strings.Builder
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run.WriteString(string(sub.Rune))
+				continue
+			}
+			if run.Len() > 0 {
+				literals = append(literals, run.String())
+				run.Reset()
+			}
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		if run.Len() > 0 {
+			literals = append(literals, run.String())
+		}
+		return literals
+	}
+	return nil
+}
+
+// SubstringTrigrams is RequiredTrigrams for a substring search, which is
+// refused when it would have to scan every line.
+func (q *Query) SubstringTrigrams() ([]string, error) {
+	trigrams := q.RequiredTrigrams()
+	if len(trigrams) == 0 {
+		return nil, &SyntaxError{Pos: 0, Message: "substring search needs a word or /regex/ of at least 3 characters that all lines contain"}
+	}
+	return trigrams, nil
+}
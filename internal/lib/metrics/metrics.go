@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "svarog"
+
+// Registry holds every svarog collector together with the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	IngestedLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingested_lines_total",
+		Help:      "Number of log lines received from JetStream and the HTTP and syslog receivers.",
+	}, []string{"project", "client"})
+
+	DroppedLines = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_lines_total",
+		Help:      "Number of log lines dropped by parsing pipelines or from clients that aren't approved.",
+	}, []string{"project", "client"})
+
+	UnroutedSyslogMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "syslog_unrouted_messages_total",
+		Help:      "Number of syslog messages dropped because they are invalid or match no routing rule.",
+	})
+
+	BatchSaveDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_save_duration_seconds",
+		Help:      "Time spent saving a batch of log lines.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+
+	BatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size_lines",
+		Help:      "Number of log lines in a saved batch.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 6),
+	})
+
+	WebSocketSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_subscribers",
+		Help:      "Number of open live tail WebSocket connections.",
+	})
+
+	SearchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_duration_seconds",
+		Help:      "Time spent executing log searches.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		IngestedLines,
+		DroppedLines,
+		UnroutedSyslogMessages,
+		BatchSaveDuration,
+		BatchSize,
+		WebSocketSubscribers,
+		SearchDuration,
+		HttpRequests,
+		HttpRequestDuration,
+	)
+}
+
+// maxLabelValues is how many projects, and clients of each project, get their
+// own series. Clients are named by the shippers, e.g. by Loki labels, so the
+// rest are counted together as otherLabel.
+const maxLabelValues = 200
+
+// maxLabelLength is the length label values are cut to.
+const maxLabelLength = 64
+
+const otherLabel = "other"
+
+var lineLabels = struct {
+	sync.Mutex
+	clients map[string]map[string]bool
+}{clients: map[string]map[string]bool{}}
+
+// LineLabels returns the project and client labels of IngestedLines and
+// DroppedLines for a line, which are only the project and client ids while
+// there are few enough of them.
+func LineLabels(projectId string, clientId string) (string, string) {
+	projectId = truncateLabel(projectId)
+	clientId = truncateLabel(clientId)
+
+	lineLabels.Lock()
+	defer lineLabels.Unlock()
+
+	clients, ok := lineLabels.clients[projectId]
+	if !ok {
+		if len(lineLabels.clients) >= maxLabelValues {
+			return otherLabel, otherLabel
+		}
+		clients = map[string]bool{}
+		lineLabels.clients[projectId] = clients
+	}
+	if !clients[clientId] {
+		if len(clients) >= maxLabelValues {
+			return projectId, otherLabel
+		}
+		clients[clientId] = true
+	}
+	return projectId, clientId
+}
+
+func truncateLabel(value string) string {
+	if len(value) <= maxLabelLength {
+		return value
+	}
+	value = value[:maxLabelLength]
+	// Don't cut a character in half
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
+
+// RegisterGaugeFunc exposes a value that is computed on every scrape,
+// e.g. the backlog size or the JetStream consumer pending count.
+func RegisterGaugeFunc(name string, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
@@ -0,0 +1,53 @@
+package natsconn
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+type EmbeddedServerConfig struct {
+	// ConfigFile is a nats-server.conf holding the operator and account JWTs
+	// generated by nats-setup.
+	ConfigFile string
+	// StoreDir overrides the JetStream store_dir of the config file.
+	StoreDir string
+	// Port overrides the client port of the config file. External clients
+	// still connect to it, the server itself connects in-process.
+	Port int
+}
+
+// StartEmbeddedServer runs a NATS server with JetStream inside the svarog process.
+func StartEmbeddedServer(cfg EmbeddedServerConfig) (*server.Server, error) {
+	opts, err := server.ProcessConfigFile(cfg.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read NATS config %s: %w", cfg.ConfigFile, err)
+	}
+
+	opts.JetStream = true
+	if cfg.StoreDir != "" {
+		opts.StoreDir = cfg.StoreDir
+	}
+	if cfg.Port != 0 {
+		opts.Port = cfg.Port
+	}
+
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedded NATS server: %w", err)
+	}
+
+	ns.ConfigureLogger()
+	ns.Start()
+
+	if !ns.ReadyForConnections(10 * time.Second) {
+		ns.Shutdown()
+		return nil, errors.New("embedded NATS server did not become ready")
+	}
+
+	slog.Info("Embedded NATS server started", "clientUrl", ns.ClientURL(), "storeDir", opts.StoreDir)
+	return ns, nil
+}
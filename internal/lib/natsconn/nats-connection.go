@@ -2,6 +2,7 @@ package natsconn
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,6 +14,18 @@ import (
 type JetStreamConfig struct {
 	Name     string
 	Subjects []string
+
+	// Limits applied to the log streams. Zero values fall back to 1GB,
+	// 7 days, file storage and a single replica. Existing streams get the
+	// new limits on startup unless an admin changed them.
+	MaxBytes int64
+	MaxAge   time.Duration
+	Storage  jetstream.StorageType
+	Replicas int
+
+	// PerProject creates a <Name>_<project> stream for every project instead
+	// of the single shared stream, so each project gets its own retention.
+	PerProject bool
 }
 
 type NatsConnectionConfig struct {
@@ -23,11 +36,29 @@ type NatsConnectionConfig struct {
 	Seed            string // For JWT auth (alternative to user/password)
 	EnableJetStream bool
 	JetStreamConfig JetStreamConfig
+
+	// InProcessServer connects to an embedded server without going through the network.
+	InProcessServer nats.InProcessConnProvider
+
+	// OnReconnect is called after the connection to the server is restored.
+	OnReconnect func()
 }
 
+// ErrSharedStreamExists is returned when project streams are enabled while
+// the shared stream, whose subjects overlap with them, still exists.
+var ErrSharedStreamExists = errors.New("shared stream still exists")
+
+const (
+	defaultStreamMaxBytes = 1024 * 1024 * 1024 // 1GB
+	defaultStreamMaxAge   = 7 * 24 * time.Hour // 7 days
+	defaultStreamReplicas = 1
+)
+
 type NatsConnection struct {
 	Conn      *nats.Conn
 	JetStream jetstream.JetStream
+
+	streamConfig JetStreamConfig
 }
 
 func NewNatsConnection(cfg NatsConnectionConfig) (*NatsConnection, error) {
@@ -40,6 +71,10 @@ func NewNatsConnection(cfg NatsConnectionConfig) (*NatsConnection, error) {
 		opts = append(opts, nats.UserInfo(cfg.User, cfg.Password))
 	}
 
+	if cfg.InProcessServer != nil {
+		opts = append(opts, nats.InProcessServer(cfg.InProcessServer))
+	}
+
 	opts = append(opts,
 		nats.MaxReconnects(-1),
 		nats.ReconnectWait(time.Second),
@@ -50,6 +85,9 @@ func NewNatsConnection(cfg NatsConnectionConfig) (*NatsConnection, error) {
 		}),
 		nats.ReconnectHandler(func(_ *nats.Conn) {
 			slog.Info("NATS reconnected")
+			if cfg.OnReconnect != nil {
+				cfg.OnReconnect()
+			}
 		}),
 	)
 
@@ -70,9 +108,15 @@ func NewNatsConnection(cfg NatsConnectionConfig) (*NatsConnection, error) {
 			return nil, fmt.Errorf("failed to create JetStream context: %w", err)
 		}
 		conn.JetStream = js
+		conn.streamConfig = withStreamDefaults(cfg.JetStreamConfig)
 
 		// Create the LOGS stream for log ingestion
-		if err := conn.ensureLogsStream(cfg.JetStreamConfig); err != nil {
+		err = conn.ensureLogsStream()
+		if errors.Is(err, ErrSharedStreamExists) {
+			nc.Close()
+			return nil, err
+		}
+		if err != nil {
 			slog.Warn("Failed to create JetStream LOGS stream", "err", err)
 		}
 	}
@@ -82,24 +126,52 @@ func NewNatsConnection(cfg NatsConnectionConfig) (*NatsConnection, error) {
 	return conn, nil
 }
 
-func (n *NatsConnection) ensureLogsStream(cfg JetStreamConfig) error {
+func withStreamDefaults(cfg JetStreamConfig) JetStreamConfig {
+	if cfg.Name == "" {
+		cfg.Name = "LOGS"
+	}
+	if len(cfg.Subjects) == 0 {
+		cfg.Subjects = []string{"logs.>"}
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultStreamMaxBytes
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = defaultStreamMaxAge
+	}
+	if cfg.Replicas == 0 {
+		cfg.Replicas = defaultStreamReplicas
+	}
+	return cfg
+}
+
+func (n *NatsConnection) ensureLogsStream() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := n.JetStream.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
-		Name:      cfg.Name,
-		Subjects:  cfg.Subjects,
-		Retention: jetstream.LimitsPolicy,
-		MaxBytes:  1024 * 1024 * 1024, // 1GB
-		MaxAge:    7 * 24 * time.Hour, // 7 days
-		Storage:   jetstream.FileStorage,
-		Discard:   jetstream.DiscardOld,
-	})
+	if n.streamConfig.PerProject {
+		// Project streams can't coexist with a shared stream on overlapping subjects
+		if _, err := n.JetStream.Stream(ctx, n.streamConfig.Name); err == nil {
+			return fmt.Errorf("%w: drain and delete stream %s before enabling NATS_STREAM_PER_PROJECT", ErrSharedStreamExists, n.streamConfig.Name)
+		} else if !errors.Is(err, jetstream.ErrStreamNotFound) {
+			return fmt.Errorf("failed to look up shared stream: %w", err)
+		}
+		return nil
+	}
+
+	_, err := n.JetStream.CreateStream(ctx, n.streamSettings(n.streamConfig.Name, n.streamConfig.Subjects))
+	if errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		if err := n.applyConfiguredLimits(ctx, n.streamConfig.Name); err != nil {
+			return err
+		}
+		slog.Info("JetStream LOGS stream ready", "created", false)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create stream: %w", err)
 	}
 
-	slog.Info("JetStream LOGS stream ready")
+	slog.Info("JetStream LOGS stream ready", "created", true)
 	return nil
 }
 
@@ -0,0 +1,259 @@
+package natsconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type ConsumerState struct {
+	Name          string `json:"name"`
+	NumPending    uint64 `json:"numPending"`
+	NumAckPending int    `json:"numAckPending"`
+}
+
+type StreamState struct {
+	Name      string          `json:"name"`
+	Subjects  []string        `json:"subjects"`
+	Messages  uint64          `json:"messages"`
+	Bytes     uint64          `json:"bytes"`
+	MaxBytes  int64           `json:"maxBytes"`
+	MaxAge    time.Duration   `json:"maxAge"`
+	Storage   string          `json:"storage"`
+	FirstSeq  uint64          `json:"firstSeq"`
+	LastSeq   uint64          `json:"lastSeq"`
+	FirstTime time.Time       `json:"firstTime"`
+	LastTime  time.Time       `json:"lastTime"`
+	Consumers []ConsumerState `json:"consumers"`
+}
+
+func ParseStorageType(storage string) (jetstream.StorageType, error) {
+	switch strings.ToLower(storage) {
+	case "", "file":
+		return jetstream.FileStorage, nil
+	case "memory":
+		return jetstream.MemoryStorage, nil
+	default:
+		return jetstream.FileStorage, fmt.Errorf("unknown storage type %q", storage)
+	}
+}
+
+// limitsSourceKey is the stream metadata key recording who set the stream's
+// limits, so startup only overwrites limits that came from the configuration.
+const (
+	limitsSourceKey  = "svarog_limits"
+	limitsFromConfig = "config"
+	limitsFromAdmin  = "admin"
+)
+
+func (n *NatsConnection) streamSettings(name string, subjects []string) jetstream.StreamConfig {
+	return jetstream.StreamConfig{
+		Name:      name,
+		Subjects:  subjects,
+		Retention: jetstream.LimitsPolicy,
+		MaxBytes:  n.streamConfig.MaxBytes,
+		MaxAge:    n.streamConfig.MaxAge,
+		Storage:   n.streamConfig.Storage,
+		Replicas:  n.streamConfig.Replicas,
+		Discard:   jetstream.DiscardOld,
+		Metadata:  map[string]string{limitsSourceKey: limitsFromConfig},
+	}
+}
+
+// applyConfiguredLimits updates an existing stream to the configured limits,
+// unless an admin changed them. Streams created before the source of their
+// limits was recorded are only updated while they still have the old defaults.
+func (n *NatsConnection) applyConfiguredLimits(ctx context.Context, name string) error {
+	stream, err := n.JetStream.Stream(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get stream %s: %w", name, err)
+	}
+
+	config := stream.CachedInfo().Config
+	switch config.Metadata[limitsSourceKey] {
+	case limitsFromAdmin:
+		return nil
+	case "":
+		if config.MaxBytes != defaultStreamMaxBytes || config.MaxAge != defaultStreamMaxAge || config.Replicas != defaultStreamReplicas {
+			return nil
+		}
+	}
+
+	if config.Storage != n.streamConfig.Storage {
+		slog.Warn("Storage of an existing stream can't be changed", "stream", name, "storage", config.Storage.String())
+	}
+
+	if config.Metadata[limitsSourceKey] == limitsFromConfig &&
+		config.MaxBytes == n.streamConfig.MaxBytes &&
+		config.MaxAge == n.streamConfig.MaxAge &&
+		config.Replicas == n.streamConfig.Replicas {
+		return nil
+	}
+
+	config.MaxBytes = n.streamConfig.MaxBytes
+	config.MaxAge = n.streamConfig.MaxAge
+	config.Replicas = n.streamConfig.Replicas
+	config.Metadata = withLimitsSource(config.Metadata, limitsFromConfig)
+	if _, err := n.JetStream.UpdateStream(ctx, config); err != nil {
+		return fmt.Errorf("failed to update limits of stream %s: %w", name, err)
+	}
+
+	slog.Info("Updated stream limits", "stream", name, "maxBytes", config.MaxBytes, "maxAge", config.MaxAge)
+	return nil
+}
+
+func withLimitsSource(metadata map[string]string, source string) map[string]string {
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata[limitsSourceKey] = source
+	return metadata
+}
+
+// PerProjectStreams reports whether every project has its own stream.
+func (n *NatsConnection) PerProjectStreams() bool {
+	return n.streamConfig.PerProject
+}
+
+// StreamName returns the name of the stream holding the project's messages.
+func (n *NatsConnection) StreamName(projectId string) string {
+	if !n.streamConfig.PerProject {
+		return n.streamConfig.Name
+	}
+	return fmt.Sprintf("%s_%s", n.streamConfig.Name, projectId)
+}
+
+// EnsureProjectStream creates the project's stream with the configured limits.
+// An existing stream gets the configured limits unless an admin changed them,
+// so their changes survive restarts. It is a no-op when all projects share one stream.
+func (n *NatsConnection) EnsureProjectStream(ctx context.Context, projectId string) error {
+	if !n.streamConfig.PerProject {
+		return nil
+	}
+
+	_, err := n.JetStream.CreateStream(ctx, n.streamSettings(
+		n.StreamName(projectId),
+		[]string{fmt.Sprintf("logs.%s.>", projectId)},
+	))
+	if errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		return n.applyConfiguredLimits(ctx, n.StreamName(projectId))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create stream for project %s: %w", projectId, err)
+	}
+
+	slog.Info("Created project stream", "stream", n.StreamName(projectId))
+	return nil
+}
+
+// DeleteProjectStream removes the project's stream together with its retained messages.
+func (n *NatsConnection) DeleteProjectStream(ctx context.Context, projectId string) error {
+	if !n.streamConfig.PerProject {
+		return nil
+	}
+
+	err := n.JetStream.DeleteStream(ctx, n.StreamName(projectId))
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		return nil
+	}
+	return err
+}
+
+// LogStreamNames lists the streams log lines are ingested from.
+func (n *NatsConnection) LogStreamNames(ctx context.Context) ([]string, error) {
+	if !n.streamConfig.PerProject {
+		return []string{n.streamConfig.Name}, nil
+	}
+
+	prefix := n.streamConfig.Name + "_"
+	names := []string{}
+	lister := n.JetStream.StreamNames(ctx)
+	for name := range lister.Name() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	if err := lister.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(names)
+	return names, nil
+}
+
+// StreamStates returns the current state of every log stream and its consumers.
+func (n *NatsConnection) StreamStates(ctx context.Context) ([]StreamState, error) {
+	names, err := n.LogStreamNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]StreamState, 0, len(names))
+	for _, name := range names {
+		stream, err := n.JetStream.Stream(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stream %s: %w", name, err)
+		}
+
+		info, err := stream.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stream %s info: %w", name, err)
+		}
+
+		state := StreamState{
+			Name:      name,
+			Subjects:  info.Config.Subjects,
+			Messages:  info.State.Msgs,
+			Bytes:     info.State.Bytes,
+			MaxBytes:  info.Config.MaxBytes,
+			MaxAge:    info.Config.MaxAge,
+			Storage:   info.Config.Storage.String(),
+			FirstSeq:  info.State.FirstSeq,
+			LastSeq:   info.State.LastSeq,
+			FirstTime: info.State.FirstTime,
+			LastTime:  info.State.LastTime,
+			Consumers: []ConsumerState{},
+		}
+
+		consumers := stream.ListConsumers(ctx)
+		for consumer := range consumers.Info() {
+			state.Consumers = append(state.Consumers, ConsumerState{
+				Name:          consumer.Name,
+				NumPending:    consumer.NumPending,
+				NumAckPending: consumer.NumAckPending,
+			})
+		}
+		if err := consumers.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list consumers of %s: %w", name, err)
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// UpdateStreamLimits changes the retention limits of a log stream. The limits
+// are marked as set by an admin, so the configured ones no longer replace them.
+func (n *NatsConnection) UpdateStreamLimits(ctx context.Context, name string, maxBytes int64, maxAge time.Duration) error {
+	stream, err := n.JetStream.Stream(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	config := stream.CachedInfo().Config
+	config.MaxBytes = maxBytes
+	config.MaxAge = maxAge
+	config.Metadata = withLimitsSource(config.Metadata, limitsFromAdmin)
+
+	_, err = n.JetStream.UpdateStream(ctx, config)
+	return err
+}
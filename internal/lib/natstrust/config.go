@@ -0,0 +1,106 @@
+package natstrust
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	trustBlockStart = "# BEGIN svarog trust, managed by nats-setup"
+	trustBlockEnd   = "# END svarog trust"
+)
+
+type ServerConfigOptions struct {
+	Port     int
+	StoreDir string
+}
+
+// trustBlock is the part of nats-server.conf that nats-setup owns.
+func (t Trust) trustBlock() string {
+	return fmt.Sprintf(`%s
+operator: %s
+system_account: %s
+
+resolver: MEMORY
+resolver_preload: {
+    %s: %s,
+    %s: %s
+}
+%s`, trustBlockStart, t.Operator.JWT, t.System.Public, t.Account.Public, t.Account.JWT, t.System.Public, t.System.JWT, trustBlockEnd)
+}
+
+// ServerConfig renders a complete nats-server.conf.
+func (t Trust) ServerConfig(options ServerConfigOptions) string {
+	return fmt.Sprintf(`port: %d
+
+jetstream {
+  store_dir: "%s"
+}
+
+%s
+`, options.Port, options.StoreDir, t.trustBlock())
+}
+
+// WriteServerConfig writes a complete nats-server.conf to path.
+func (t Trust) WriteServerConfig(path string, options ServerConfigOptions) error {
+	return os.WriteFile(path, []byte(t.ServerConfig(options)), 0o600)
+}
+
+// UpdateServerConfig replaces only the managed trust block of an existing
+// nats-server.conf, keeping everything else as it was edited.
+func (t Trust) UpdateServerConfig(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config := string(content)
+	start := strings.Index(config, trustBlockStart)
+	end := strings.Index(config, trustBlockEnd)
+	if start == -1 || end == -1 || end < start {
+		return errors.New("trust block markers not found, run init to regenerate the config")
+	}
+
+	updated := config[:start] + t.trustBlock() + config[end+len(trustBlockEnd):]
+	return os.WriteFile(path, []byte(updated), 0o600)
+}
+
+// EnvFile renders the server environment variables. With inline set the seeds
+// are written into the file, otherwise the server reads them from secretsDir.
+func (t Trust) EnvFile(secretsDir string, inline bool) string {
+	var env strings.Builder
+	env.WriteString("# Generated by nats-setup\n")
+	fmt.Fprintf(&env, "NATS_ACCOUNT_PUBLIC_KEY=%s\n", t.Account.Public)
+
+	secrets := []struct {
+		name  string
+		file  string
+		value string
+	}{
+		{"NATS_ACCOUNT_SEED", AccountSigningSeedFile, t.AccountSigning.Seed},
+		{"NATS_SERVER_USER_JWT", ServerUserJwtFile, t.ServerUser.JWT},
+		{"NATS_SERVER_USER_SEED", ServerUserSeedFile, t.ServerUser.Seed},
+		{"NATS_OPERATOR_SEED", OperatorSeedFile, t.Operator.Seed},
+		{"NATS_ACCOUNT_JWT", AccountJwtFile, t.Account.JWT},
+		{"NATS_SYSTEM_USER_JWT", SystemUserJwtFile, t.SystemUser.JWT},
+		{"NATS_SYSTEM_USER_SEED", SystemUserSeedFile, t.SystemUser.Seed},
+	}
+
+	for _, secret := range secrets {
+		if inline {
+			fmt.Fprintf(&env, "%s=%s\n", secret.name, secret.value)
+		} else {
+			fmt.Fprintf(&env, "%s_FILE=%s\n", secret.name, filepath.Join(secretsDir, secret.file))
+		}
+	}
+
+	return env.String()
+}
+
+// WriteEnvFile writes the server environment variables to path.
+func (t Trust) WriteEnvFile(path string, secretsDir string, inline bool) error {
+	return os.WriteFile(path, []byte(t.EnvFile(secretsDir, inline)), 0o600)
+}
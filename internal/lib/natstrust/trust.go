@@ -0,0 +1,272 @@
+package natstrust
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// Entity is a single NATS identity with its signed JWT.
+type Entity struct {
+	Public string
+	Seed   string
+	JWT    string
+}
+
+// Trust holds the operator, accounts and the server user svarog connects with.
+// User credentials for clients are signed with the account signing key, so it
+// can be rotated without changing the account and losing its JetStream data.
+// The system user is used to push account updates such as revocations.
+type Trust struct {
+	Operator       Entity
+	System         Entity
+	SystemUser     Entity
+	Account        Entity
+	AccountSigning Entity
+	ServerUser     Entity
+}
+
+const (
+	operatorName = "Svarog-Operator"
+	systemName   = "SYS"
+	accountName  = "APP"
+	userName     = "admin"
+)
+
+// Generate creates a new operator, system account, app account and server user.
+func Generate() (Trust, error) {
+	var trust Trust
+	var err error
+
+	operatorKp, err := nkeys.CreateOperator()
+	if err != nil {
+		return trust, err
+	}
+	if trust.Operator, err = entityFromKeyPair(operatorKp); err != nil {
+		return trust, err
+	}
+	operatorClaims := jwt.NewOperatorClaims(trust.Operator.Public)
+	operatorClaims.Name = operatorName
+	if trust.Operator.JWT, err = operatorClaims.Encode(operatorKp); err != nil {
+		return trust, fmt.Errorf("failed to encode operator: %w", err)
+	}
+
+	systemKp, err := nkeys.CreateAccount()
+	if err != nil {
+		return trust, err
+	}
+	if trust.System, err = entityFromKeyPair(systemKp); err != nil {
+		return trust, err
+	}
+	systemClaims := jwt.NewAccountClaims(trust.System.Public)
+	systemClaims.Name = systemName
+	if trust.System.JWT, err = systemClaims.Encode(operatorKp); err != nil {
+		return trust, fmt.Errorf("failed to encode system account: %w", err)
+	}
+
+	systemUserKp, err := nkeys.CreateUser()
+	if err != nil {
+		return trust, err
+	}
+	if trust.SystemUser, err = entityFromKeyPair(systemUserKp); err != nil {
+		return trust, err
+	}
+	systemUserClaims := jwt.NewUserClaims(trust.SystemUser.Public)
+	systemUserClaims.Name = "svarog"
+	if trust.SystemUser.JWT, err = systemUserClaims.Encode(systemKp); err != nil {
+		return trust, fmt.Errorf("failed to encode system user: %w", err)
+	}
+
+	accountKp, err := nkeys.CreateAccount()
+	if err != nil {
+		return trust, err
+	}
+	if trust.Account, err = entityFromKeyPair(accountKp); err != nil {
+		return trust, err
+	}
+
+	if err := trust.RotateAccount(); err != nil {
+		return trust, err
+	}
+
+	return trust, nil
+}
+
+// RotateAccount replaces the account signing key and the server user. Every
+// credential signed with the previous key stops working once the NATS server
+// loads the new account JWT.
+func (t *Trust) RotateAccount() error {
+	operatorKp, err := nkeys.FromSeed([]byte(t.Operator.Seed))
+	if err != nil {
+		return fmt.Errorf("failed to parse operator seed: %w", err)
+	}
+
+	signingKp, err := nkeys.CreateAccount()
+	if err != nil {
+		return err
+	}
+	signing, err := entityFromKeyPair(signingKp)
+	if err != nil {
+		return err
+	}
+
+	accountClaims := jwt.NewAccountClaims(t.Account.Public)
+	accountClaims.Name = accountName
+	accountClaims.Limits.JetStreamLimits.DiskStorage = -1
+	accountClaims.Limits.JetStreamLimits.MemoryStorage = -1
+	accountClaims.SigningKeys.Add(signing.Public)
+	accountJwt, err := accountClaims.Encode(operatorKp)
+	if err != nil {
+		return fmt.Errorf("failed to encode account: %w", err)
+	}
+
+	userKp, err := nkeys.CreateUser()
+	if err != nil {
+		return err
+	}
+	user, err := entityFromKeyPair(userKp)
+	if err != nil {
+		return err
+	}
+	userClaims := jwt.NewUserClaims(user.Public)
+	userClaims.Name = userName
+	userClaims.IssuerAccount = t.Account.Public
+	userClaims.Permissions.Pub.Allow.Add(">")
+	userClaims.Permissions.Sub.Allow.Add(">")
+	if user.JWT, err = userClaims.Encode(signingKp); err != nil {
+		return fmt.Errorf("failed to encode server user: %w", err)
+	}
+
+	t.Account.JWT = accountJwt
+	t.AccountSigning = signing
+	t.ServerUser = user
+	return nil
+}
+
+func entityFromKeyPair(kp nkeys.KeyPair) (Entity, error) {
+	public, err := kp.PublicKey()
+	if err != nil {
+		return Entity{}, err
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return Entity{}, err
+	}
+	return Entity{Public: public, Seed: string(seed)}, nil
+}
+
+// Files written to the secrets directory.
+const (
+	OperatorSeedFile       = "operator.nk"
+	OperatorJwtFile        = "operator.jwt"
+	SystemSeedFile         = "sys.nk"
+	SystemJwtFile          = "sys.jwt"
+	SystemUserSeedFile     = "sys-user.nk"
+	SystemUserJwtFile      = "sys-user.jwt"
+	AccountSeedFile        = "account.nk"
+	AccountJwtFile         = "account.jwt"
+	AccountSigningSeedFile = "account-signing.nk"
+	ServerUserSeedFile     = "server-user.nk"
+	ServerUserJwtFile      = "server-user.jwt"
+)
+
+// Save writes every seed and JWT into dir, readable only by the owner.
+func (t Trust) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	files := map[string]string{
+		OperatorSeedFile:       t.Operator.Seed,
+		OperatorJwtFile:        t.Operator.JWT,
+		SystemSeedFile:         t.System.Seed,
+		SystemJwtFile:          t.System.JWT,
+		SystemUserSeedFile:     t.SystemUser.Seed,
+		SystemUserJwtFile:      t.SystemUser.JWT,
+		AccountSeedFile:        t.Account.Seed,
+		AccountJwtFile:         t.Account.JWT,
+		AccountSigningSeedFile: t.AccountSigning.Seed,
+		ServerUserSeedFile:     t.ServerUser.Seed,
+		ServerUserJwtFile:      t.ServerUser.JWT,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Load reads a trust previously written with Save.
+func Load(dir string) (Trust, error) {
+	var trust Trust
+	var err error
+
+	if trust.Operator, err = loadEntity(dir, OperatorSeedFile, OperatorJwtFile); err != nil {
+		return trust, err
+	}
+	if trust.System, err = loadEntity(dir, SystemSeedFile, SystemJwtFile); err != nil {
+		return trust, err
+	}
+	if trust.SystemUser, err = loadEntity(dir, SystemUserSeedFile, SystemUserJwtFile); err != nil {
+		return trust, err
+	}
+	if trust.Account, err = loadEntity(dir, AccountSeedFile, AccountJwtFile); err != nil {
+		return trust, err
+	}
+	if trust.AccountSigning, err = loadEntity(dir, AccountSigningSeedFile, ""); err != nil {
+		return trust, err
+	}
+	if trust.ServerUser, err = loadEntity(dir, ServerUserSeedFile, ServerUserJwtFile); err != nil {
+		return trust, err
+	}
+
+	return trust, nil
+}
+
+func loadEntity(dir string, seedFile string, jwtFile string) (Entity, error) {
+	seed, err := ReadSecretFile(filepath.Join(dir, seedFile))
+	if err != nil {
+		return Entity{}, err
+	}
+	kp, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return Entity{}, fmt.Errorf("failed to parse %s: %w", seedFile, err)
+	}
+	entity, err := entityFromKeyPair(kp)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	if jwtFile != "" {
+		if entity.JWT, err = ReadSecretFile(filepath.Join(dir, jwtFile)); err != nil {
+			return Entity{}, err
+		}
+	}
+	return entity, nil
+}
+
+// ReadSecretFile reads a seed or JWT file, ignoring surrounding whitespace.
+func ReadSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return value, nil
+}
+
+// Exists reports whether dir already holds a trust.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, OperatorSeedFile))
+	return !errors.Is(err, os.ErrNotExist)
+}
@@ -0,0 +1,60 @@
+package pipelines
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StageType string
+
+const (
+	// StageRegex extracts named capture groups into fields.
+	StageRegex StageType = "regex"
+	// StageGrok extracts fields using %{PATTERN:field} expressions.
+	StageGrok StageType = "grok"
+	// StageKeyValue parses logfmt style key=value pairs.
+	StageKeyValue StageType = "kv"
+	// StageJSON parses the source as a JSON object.
+	StageJSON StageType = "json"
+	// StageRename renames fields according to Mapping.
+	StageRename StageType = "rename"
+	// StageLevel maps the value of Field to a log level.
+	StageLevel StageType = "level"
+	// StageDrop drops the line if Pattern matches the source.
+	StageDrop StageType = "drop"
+	// StageMessage replaces the stored message with the value of Field.
+	StageMessage StageType = "message"
+)
+
+// AllClients matches every client of a project.
+const AllClients = "*"
+
+type Stage struct {
+	Type StageType `bson:"type" json:"type"`
+	// Source is the field the stage reads from. Empty means the raw message.
+	Source    string            `bson:"source,omitempty" json:"source,omitempty"`
+	Pattern   string            `bson:"pattern,omitempty" json:"pattern,omitempty"`
+	Field     string            `bson:"field,omitempty" json:"field,omitempty"`
+	Separator string            `bson:"separator,omitempty" json:"separator,omitempty"`
+	Delimiter string            `bson:"delimiter,omitempty" json:"delimiter,omitempty"`
+	Mapping   map[string]string `bson:"mapping,omitempty" json:"mapping,omitempty"`
+}
+
+type Pipeline struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProjectId string             `bson:"project_id" json:"projectId"`
+	ClientId  string             `bson:"client_id" json:"clientId"`
+	Name      string             `bson:"name" json:"name"`
+	Enabled   bool               `bson:"enabled" json:"enabled"`
+	Stages    []Stage            `bson:"stages" json:"stages"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// Result is the outcome of running a line through one or more pipelines.
+type Result struct {
+	Message string
+	Level   string
+	Fields  map[string]any
+	Dropped bool
+}
@@ -0,0 +1,365 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var grokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d+)?|\.\d+)`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z\-.]*\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"USER":              `[a-zA-Z0-9._-]+`,
+	"QS":                `"(?:[^"\\]|\\.)*"`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"URIPATHPARAM":      `\S+`,
+	"HTTPDATE":          `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|panic|emerg)`,
+	"COMMONAPACHELOG":   `%{IPORHOST:client} %{USER:ident} %{USER:auth} \[%{HTTPDATE:time}\] "(?:%{WORD:method} %{NOTSPACE:path}(?: HTTP/%{NUMBER:http_version})?|%{DATA:request})" %{INT:status:int} (?:%{INT:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+	"NGINXACCESS":       `%{COMBINEDAPACHELOG}`,
+}
+
+var grokExpression = regexp.MustCompile(`%\{(\w+)(?::([\w.\-]+))?(?::(int|float))?\}`)
+
+const maxGrokDepth = 10
+
+var defaultLevels = map[string]string{
+	"trace":    "trace",
+	"debug":    "debug",
+	"dbg":      "debug",
+	"info":     "info",
+	"inf":      "info",
+	"notice":   "info",
+	"warn":     "warn",
+	"warning":  "warn",
+	"wrn":      "warn",
+	"err":      "error",
+	"error":    "error",
+	"crit":     "fatal",
+	"critical": "fatal",
+	"fatal":    "fatal",
+	"panic":    "fatal",
+	"emerg":    "fatal",
+}
+
+// NormalizeLevel maps common level names, like "WARNING" or "err", to the
+// levels svarog stores. Unknown levels return "".
+func NormalizeLevel(value string) string {
+	return defaultLevels[strings.ToLower(strings.TrimSpace(value))]
+}
+
+type grokCapture struct {
+	field     string
+	valueType string
+}
+
+type compiledStage struct {
+	Stage
+	regex    *regexp.Regexp
+	captures map[string]grokCapture
+}
+
+type CompiledPipeline struct {
+	Pipeline
+	stages []compiledStage
+}
+
+// Compile validates the pipeline and precompiles all of its expressions.
+func Compile(pipeline Pipeline) (*CompiledPipeline, error) {
+	compiled := &CompiledPipeline{
+		Pipeline: pipeline,
+		stages:   make([]compiledStage, len(pipeline.Stages)),
+	}
+
+	for i, stage := range pipeline.Stages {
+		c, err := compileStage(stage)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i+1, stage.Type, err)
+		}
+		compiled.stages[i] = c
+	}
+
+	return compiled, nil
+}
+
+func compileStage(stage Stage) (compiledStage, error) {
+	compiled := compiledStage{Stage: stage}
+
+	switch stage.Type {
+	case StageRegex, StageDrop:
+		if stage.Pattern == "" {
+			return compiled, errors.New("pattern is required")
+		}
+		regex, err := regexp.Compile(stage.Pattern)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.regex = regex
+	case StageGrok:
+		if stage.Pattern == "" {
+			return compiled, errors.New("pattern is required")
+		}
+		captures := map[string]grokCapture{}
+		expanded, err := expandGrok(stage.Pattern, captures, 0)
+		if err != nil {
+			return compiled, err
+		}
+		regex, err := regexp.Compile(expanded)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.regex = regex
+		compiled.captures = captures
+	case StageRename:
+		if len(stage.Mapping) == 0 {
+			return compiled, errors.New("mapping is required")
+		}
+	case StageMessage:
+		if stage.Field == "" {
+			return compiled, errors.New("field is required")
+		}
+	case StageKeyValue, StageJSON, StageLevel:
+	default:
+		return compiled, fmt.Errorf("unknown stage type %q", stage.Type)
+	}
+
+	return compiled, nil
+}
+
+func expandGrok(pattern string, captures map[string]grokCapture, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", errors.New("grok patterns are nested too deeply")
+	}
+
+	var expandErr error
+	expanded := grokExpression.ReplaceAllStringFunc(pattern, func(match string) string {
+		parts := grokExpression.FindStringSubmatch(match)
+		name, field, valueType := parts[1], parts[2], parts[3]
+
+		definition, ok := grokPatterns[name]
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %q", name)
+			return ""
+		}
+
+		inner, err := expandGrok(definition, captures, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+
+		groupName := fmt.Sprintf("g%d", len(captures))
+		captures[groupName] = grokCapture{field: field, valueType: valueType}
+		return fmt.Sprintf("(?P<%s>%s)", groupName, inner)
+	})
+
+	return expanded, expandErr
+}
+
+// Process runs the message through every stage of the pipeline.
+func (p *CompiledPipeline) Process(result *Result) {
+	if result.Fields == nil {
+		result.Fields = map[string]any{}
+	}
+
+	for _, stage := range p.stages {
+		if result.Dropped {
+			return
+		}
+		stage.run(result)
+	}
+}
+
+func (s *compiledStage) source(result *Result) (string, bool) {
+	if s.Source == "" {
+		return result.Message, true
+	}
+
+	value, ok := result.Fields[s.Source]
+	if !ok {
+		return "", false
+	}
+	if str, ok := value.(string); ok {
+		return str, true
+	}
+	return fmt.Sprint(value), true
+}
+
+func (s *compiledStage) run(result *Result) {
+	switch s.Type {
+	case StageRegex:
+		source, ok := s.source(result)
+		if !ok {
+			return
+		}
+		match := s.regex.FindStringSubmatch(source)
+		if match == nil {
+			return
+		}
+		for i, name := range s.regex.SubexpNames() {
+			if name != "" && i < len(match) {
+				result.Fields[name] = match[i]
+			}
+		}
+	case StageGrok:
+		source, ok := s.source(result)
+		if !ok {
+			return
+		}
+		match := s.regex.FindStringSubmatch(source)
+		if match == nil {
+			return
+		}
+		for i, name := range s.regex.SubexpNames() {
+			capture, ok := s.captures[name]
+			if !ok || i >= len(match) || match[i] == "" {
+				continue
+			}
+			result.Fields[capture.field] = convertValue(match[i], capture.valueType)
+		}
+	case StageKeyValue:
+		source, ok := s.source(result)
+		if !ok {
+			return
+		}
+		for key, value := range parseKeyValues(source, s.Separator, s.Delimiter) {
+			result.Fields[key] = value
+		}
+	case StageJSON:
+		source, ok := s.source(result)
+		if !ok {
+			return
+		}
+		var parsed map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(source)), &parsed); err != nil {
+			return
+		}
+		for key, value := range parsed {
+			result.Fields[key] = value
+		}
+	case StageRename:
+		for from, to := range s.Mapping {
+			if value, ok := result.Fields[from]; ok {
+				delete(result.Fields, from)
+				result.Fields[to] = value
+			}
+		}
+	case StageLevel:
+		field := s.Field
+		if field == "" {
+			field = "level"
+		}
+		value, ok := result.Fields[field]
+		if !ok {
+			return
+		}
+		raw := strings.ToLower(strings.TrimSpace(fmt.Sprint(value)))
+		if level, ok := s.Mapping[raw]; ok {
+			result.Level = level
+		} else if level, ok := defaultLevels[raw]; ok {
+			result.Level = level
+		}
+	case StageDrop:
+		source, ok := s.source(result)
+		if ok && s.regex.MatchString(source) {
+			result.Dropped = true
+		}
+	case StageMessage:
+		if value, ok := result.Fields[s.Field]; ok {
+			result.Message = fmt.Sprint(value)
+		}
+	}
+}
+
+func convertValue(value string, valueType string) any {
+	switch valueType {
+	case "int":
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	case "float":
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+// parseKeyValues parses logfmt style pairs. Values may be wrapped in double quotes.
+func parseKeyValues(source string, separator string, delimiter string) map[string]string {
+	if separator == "" {
+		separator = " "
+	}
+	if delimiter == "" {
+		delimiter = "="
+	}
+
+	pairs := map[string]string{}
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	for i := 0; i < len(source); i++ {
+		char := source[i]
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+			current.WriteByte(char)
+		case !inQuotes && strings.HasPrefix(source[i:], separator):
+			tokens = append(tokens, current.String())
+			current.Reset()
+			i += len(separator) - 1
+		default:
+			current.WriteByte(char)
+		}
+	}
+	tokens = append(tokens, current.String())
+
+	for _, token := range tokens {
+		key, value, found := strings.Cut(token, delimiter)
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		pairs[key] = value
+	}
+
+	return pairs
+}
+
+// ParseStages parses the JSON representation of pipeline stages used by the admin UI.
+func ParseStages(raw string) ([]Stage, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []Stage{}, nil
+	}
+
+	var stages []Stage
+	if err := json.Unmarshal([]byte(raw), &stages); err != nil {
+		return nil, fmt.Errorf("invalid stages JSON: %w", err)
+	}
+
+	return stages, nil
+}
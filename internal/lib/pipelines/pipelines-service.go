@@ -0,0 +1,181 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/server/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PipelinesService interface {
+	GetPipelines(ctx context.Context, projectId string) ([]Pipeline, error)
+	GetPipeline(ctx context.Context, id string) (Pipeline, error)
+	GetPipelinesForClient(ctx context.Context, projectId string, clientId string) ([]Pipeline, error)
+	SavePipeline(ctx context.Context, form types.PipelineForm) (Pipeline, error)
+	DeletePipeline(ctx context.Context, id string) error
+}
+
+type MongoPipelinesService struct {
+	pipelinesCollection *mongo.Collection
+}
+
+const (
+	ErrPipelineNotFound = "pipeline not found"
+)
+
+var _ PipelinesService = &MongoPipelinesService{}
+
+// GetPipelines implements PipelinesService.
+func (m *MongoPipelinesService) GetPipelines(ctx context.Context, projectId string) ([]Pipeline, error) {
+	cursor, err := m.pipelinesCollection.Find(ctx,
+		bson.M{"project_id": projectId},
+		options.Find().SetSort(bson.D{{Key: "client_id", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	pipelines := []Pipeline{}
+	if err := cursor.All(ctx, &pipelines); err != nil {
+		return nil, err
+	}
+
+	return pipelines, nil
+}
+
+// GetPipeline implements PipelinesService.
+func (m *MongoPipelinesService) GetPipeline(ctx context.Context, id string) (Pipeline, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	var pipeline Pipeline
+	if err := m.pipelinesCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&pipeline); err != nil {
+		return Pipeline{}, errors.New(ErrPipelineNotFound)
+	}
+
+	return pipeline, nil
+}
+
+// GetPipelinesForClient returns enabled pipelines that apply to the client.
+// Project wide pipelines are returned before client specific ones.
+func (m *MongoPipelinesService) GetPipelinesForClient(ctx context.Context, projectId string, clientId string) ([]Pipeline, error) {
+	cursor, err := m.pipelinesCollection.Find(ctx, bson.M{
+		"project_id": projectId,
+		"client_id":  bson.M{"$in": bson.A{AllClients, clientId}},
+		"enabled":    true,
+	}, options.Find().SetSort(bson.D{{Key: "client_id", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	pipelines := []Pipeline{}
+	if err := cursor.All(ctx, &pipelines); err != nil {
+		return nil, err
+	}
+
+	return pipelines, nil
+}
+
+// SavePipeline implements PipelinesService.
+func (m *MongoPipelinesService) SavePipeline(ctx context.Context, form types.PipelineForm) (Pipeline, error) {
+	stages, err := ParseStages(form.Stages)
+	if err != nil {
+		return Pipeline{}, err
+	}
+
+	pipeline := Pipeline{
+		ProjectId: form.ProjectId,
+		ClientId:  form.ClientId,
+		Name:      form.Name,
+		Enabled:   form.Enabled,
+		Stages:    stages,
+		UpdatedAt: time.Now(),
+	}
+
+	if _, err := Compile(pipeline); err != nil {
+		return Pipeline{}, err
+	}
+
+	if form.ID == "" {
+		result, err := m.pipelinesCollection.InsertOne(ctx, pipeline)
+		if err != nil {
+			slog.Error("Error creating pipeline", "error", err)
+			return Pipeline{}, err
+		}
+		pipeline.ID = result.InsertedID.(primitive.ObjectID)
+		return pipeline, nil
+	}
+
+	objID, err := primitive.ObjectIDFromHex(form.ID)
+	if err != nil {
+		return Pipeline{}, err
+	}
+	pipeline.ID = objID
+
+	result, err := m.pipelinesCollection.ReplaceOne(ctx, bson.M{"_id": objID}, pipeline)
+	if err != nil {
+		slog.Error("Error updating pipeline", "error", err)
+		return Pipeline{}, err
+	}
+	if result.MatchedCount == 0 {
+		return Pipeline{}, errors.New(ErrPipelineNotFound)
+	}
+
+	return pipeline, nil
+}
+
+// DeletePipeline implements PipelinesService.
+func (m *MongoPipelinesService) DeletePipeline(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := m.pipelinesCollection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New(ErrPipelineNotFound)
+	}
+
+	return nil
+}
+
+func (m *MongoPipelinesService) createIndexes(ctx context.Context) error {
+	_, err := m.pipelinesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "project_id", Value: 1},
+			{Key: "client_id", Value: 1},
+			{Key: "enabled", Value: 1},
+		},
+	})
+	if err != nil {
+		return errors.Join(errors.New("error creating pipelines index"), err)
+	}
+
+	return nil
+}
+
+func NewPipelinesService(pipelinesCollection *mongo.Collection) PipelinesService {
+	service := &MongoPipelinesService{
+		pipelinesCollection: pipelinesCollection,
+	}
+
+	if err := service.createIndexes(context.Background()); err != nil {
+		slog.Error("Error creating pipeline indexes", "error", err)
+		panic(err)
+	}
+
+	return service
+}
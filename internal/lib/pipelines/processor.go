@@ -0,0 +1,92 @@
+package pipelines
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+)
+
+const processorCacheTTL = 30 * time.Second
+
+type cachedPipelines struct {
+	pipelines []*CompiledPipeline
+	loadedAt  time.Time
+}
+
+// Processor applies the configured pipelines of a project/client to ingested lines.
+// Compiled pipelines are cached and reloaded after processorCacheTTL or on Invalidate.
+type Processor struct {
+	pipelinesService PipelinesService
+
+	mu    sync.RWMutex
+	cache map[string]cachedPipelines
+}
+
+func NewProcessor(pipelinesService PipelinesService) *Processor {
+	return &Processor{
+		pipelinesService: pipelinesService,
+		cache:            make(map[string]cachedPipelines),
+	}
+}
+
+func (p *Processor) Process(ctx context.Context, projectId string, clientId string, message string) Result {
+	result := Result{Message: message}
+
+	for _, pipeline := range p.pipelinesFor(ctx, projectId, clientId) {
+		pipeline.Process(&result)
+		if result.Dropped {
+			break
+		}
+	}
+
+	return result
+}
+
+// Invalidate drops cached pipelines of a project so the next line reloads them.
+func (p *Processor) Invalidate(projectId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix := projectId + "."
+	for key := range p.cache {
+		if strings.HasPrefix(key, prefix) {
+			delete(p.cache, key)
+		}
+	}
+}
+
+func (p *Processor) pipelinesFor(ctx context.Context, projectId string, clientId string) []*CompiledPipeline {
+	key := projectId + "." + clientId
+
+	p.mu.RLock()
+	cached, ok := p.cache[key]
+	p.mu.RUnlock()
+	if ok && time.Since(cached.loadedAt) < processorCacheTTL {
+		return cached.pipelines
+	}
+
+	stored, err := p.pipelinesService.GetPipelinesForClient(ctx, projectId, clientId)
+	if err != nil {
+		slog.Error("Failed to load pipelines", "projectId", projectId, "clientId", clientId, "err", err)
+		return cached.pipelines
+	}
+
+	compiled := make([]*CompiledPipeline, 0, len(stored))
+	for _, pipeline := range stored {
+		c, err := Compile(pipeline)
+		if err != nil {
+			slog.Error("Skipping invalid pipeline", "pipeline", pipeline.ID.Hex(), "err", err)
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	p.mu.Lock()
+	p.cache[key] = cachedPipelines{pipelines: compiled, loadedAt: time.Now()}
+	p.mu.Unlock()
+
+	return compiled
+}
@@ -1,6 +1,8 @@
 package projects
 
 import (
+	"slices"
+
 	"github.com/markojerkic/svarog/internal/server/types"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -10,6 +12,35 @@ type Project struct {
 	Name             string             `bson:"name" json:"name"`
 	Clients          []string           `bson:"clients" json:"clients"`
 	TotalStorageSize float64            `bson:"totalSizeMB" json:"totalStorageSize"`
+	// PendingClients were seen by the server, but wait for an admin to approve them
+	PendingClients []string `bson:"pending_clients,omitempty" json:"pendingClients"`
+	// RejectedClients are never registered, their log lines are dropped
+	RejectedClients []string `bson:"rejected_clients,omitempty" json:"rejectedClients"`
+	// RequireClientApproval keeps newly seen clients pending instead of registering them
+	RequireClientApproval bool `bson:"require_client_approval" json:"requireClientApproval"`
+}
+
+// ClientStatus is the registration state of a client on a project.
+type ClientStatus string
+
+const (
+	ClientApproved ClientStatus = "approved"
+	ClientPending  ClientStatus = "pending"
+	ClientRejected ClientStatus = "rejected"
+)
+
+// ClientStatus returns the registration state of clientId, unknown clients are "".
+func (p *Project) ClientStatus(clientId string) ClientStatus {
+	switch {
+	case slices.Contains(p.Clients, clientId):
+		return ClientApproved
+	case slices.Contains(p.RejectedClients, clientId):
+		return ClientRejected
+	case slices.Contains(p.PendingClients, clientId):
+		return ClientPending
+	default:
+		return ""
+	}
 }
 
 func (p *Project) ToCreateProjectForm() types.CreateProjectForm {
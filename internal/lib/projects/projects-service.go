@@ -22,6 +22,12 @@ type ProjectsService interface {
 	GetProjects(ctx context.Context) ([]Project, error)
 	DeleteProject(ctx context.Context, id string) error
 	ProjectExists(ctx context.Context, projectId, clientId string) bool
+	// RegisterClient adds a newly seen client to the project, or to its pending
+	// clients when the project requires approval, and returns the client's status.
+	RegisterClient(ctx context.Context, projectId, clientId string) (ClientStatus, error)
+	ApproveClient(ctx context.Context, projectId, clientId string) error
+	RejectClient(ctx context.Context, projectId, clientId string) error
+	SetRequireClientApproval(ctx context.Context, projectId string, required bool) error
 }
 
 type MongoProjectsService struct {
@@ -127,6 +133,9 @@ func (m *MongoProjectsService) GetProjects(ctx context.Context) ([]Project, erro
 				{Key: "_id", Value: 1},
 				{Key: "name", Value: 1},
 				{Key: "clients", Value: 1},
+				{Key: "pending_clients", Value: 1},
+				{Key: "rejected_clients", Value: 1},
+				{Key: "require_client_approval", Value: 1},
 				{Key: "totalSizeMB", Value: bson.D{
 					{Key: "$round", Value: bson.A{
 						bson.D{
@@ -203,18 +212,79 @@ func (m *MongoProjectsService) ProjectExists(ctx context.Context, projectId, cli
 		return false
 	}
 
-	if clientId == "*" {
-		return true
+	filter := bson.M{"_id": objID}
+	// A wildcard client is valid for any existing project
+	if clientId != "*" {
+		filter["clients"] = clientId
 	}
 
-	count, err := m.projectsCollection.CountDocuments(ctx, bson.M{
-		"_id":     objID,
-		"clients": clientId,
-	}, options.Count().SetLimit(1))
+	count, err := m.projectsCollection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
 
 	return err == nil && count > 0
 }
 
+// RegisterClient implements [ProjectsService].
+func (m *MongoProjectsService) RegisterClient(ctx context.Context, projectId, clientId string) (ClientStatus, error) {
+	project, err := m.GetProject(ctx, projectId)
+	if err != nil {
+		return "", err
+	}
+
+	if status := project.ClientStatus(clientId); status != "" {
+		return status, nil
+	}
+
+	status, field := ClientApproved, "clients"
+	if project.RequireClientApproval {
+		status, field = ClientPending, "pending_clients"
+	}
+
+	_, err = m.projectsCollection.UpdateByID(ctx, project.ID, bson.M{"$addToSet": bson.M{field: clientId}})
+	if err != nil {
+		return "", fmt.Errorf("failed to register client: %w", err)
+	}
+	slog.Info("Registered new client", "project", projectId, "client", clientId, "status", status)
+
+	return status, nil
+}
+
+// ApproveClient implements [ProjectsService].
+func (m *MongoProjectsService) ApproveClient(ctx context.Context, projectId, clientId string) error {
+	return m.updateClient(ctx, projectId, bson.M{
+		"$addToSet": bson.M{"clients": clientId},
+		"$pull":     bson.M{"pending_clients": clientId, "rejected_clients": clientId},
+	})
+}
+
+// RejectClient implements [ProjectsService].
+func (m *MongoProjectsService) RejectClient(ctx context.Context, projectId, clientId string) error {
+	return m.updateClient(ctx, projectId, bson.M{
+		"$addToSet": bson.M{"rejected_clients": clientId},
+		"$pull":     bson.M{"pending_clients": clientId, "clients": clientId},
+	})
+}
+
+// SetRequireClientApproval implements [ProjectsService].
+func (m *MongoProjectsService) SetRequireClientApproval(ctx context.Context, projectId string, required bool) error {
+	return m.updateClient(ctx, projectId, bson.M{"$set": bson.M{"require_client_approval": required}})
+}
+
+func (m *MongoProjectsService) updateClient(ctx context.Context, projectId string, update bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(projectId)
+	if err != nil {
+		return errors.New(ErrProjectNotFound)
+	}
+
+	result, err := m.projectsCollection.UpdateByID(ctx, objID, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New(ErrProjectNotFound)
+	}
+	return nil
+}
+
 var _ ProjectsService = &MongoProjectsService{}
 
 func NewProjectsService(projectsCollection *mongo.Collection, mongoClient *mongo.Client) ProjectsService {
@@ -0,0 +1,151 @@
+package serverauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// AccountRevoker pushes the revoked credentials from the registry to the NATS
+// server as the revocation list of the account JWT. The JWT is re-signed with
+// the operator key and sent as a claims update, which the server applies to the
+// resolver and uses to close connections of revoked users.
+type AccountRevoker struct {
+	operatorKp nkeys.KeyPair
+	accountJwt string
+	accountPub string
+	systemConn *nats.Conn
+	registry   CredentialRegistry
+
+	mu sync.Mutex
+}
+
+// NewAccountRevoker builds a revoker for the account described by accountJwt.
+// systemConn must belong to a user of the system account, only those are
+// allowed to update account claims.
+func NewAccountRevoker(operatorSeed string, accountJwt string, systemConn *nats.Conn, registry CredentialRegistry) (*AccountRevoker, error) {
+	if operatorSeed == "" {
+		return nil, errors.New("operatorSeed is required")
+	}
+	if systemConn == nil {
+		return nil, errors.New("systemConn is required")
+	}
+	if registry == nil {
+		return nil, errors.New("registry is required")
+	}
+
+	operatorKp, err := nkeys.FromSeed([]byte(operatorSeed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse operator seed: %w", err)
+	}
+
+	claims, err := jwt.DecodeAccountClaims(accountJwt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode account JWT: %w", err)
+	}
+
+	return &AccountRevoker{
+		operatorKp: operatorKp,
+		accountJwt: accountJwt,
+		accountPub: claims.Subject,
+		systemConn: systemConn,
+		registry:   registry,
+	}, nil
+}
+
+// AccountJWT builds the account JWT with the current revocation list.
+func (r *AccountRevoker) AccountJWT(ctx context.Context) (string, error) {
+	claims, err := jwt.DecodeAccountClaims(r.accountJwt)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode account JWT: %w", err)
+	}
+
+	revoked, err := r.registry.GetRevoked(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get revoked credentials: %w", err)
+	}
+	for _, credential := range revoked {
+		// Ingest tokens aren't NATS users, they are rejected by the HTTP endpoints
+		if credential.Scope == CredentialScopeIngest {
+			continue
+		}
+		claims.RevokeAt(credential.PublicKey, *credential.RevokedAt)
+	}
+
+	return claims.Encode(r.operatorKp)
+}
+
+// Sync sends the account JWT with the current revocation list to the server.
+// It has to run again after the server restarts, since a MEMORY resolver
+// starts from the preloaded JWT.
+func (r *AccountRevoker) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	accountJwt, err := r.AccountJWT(ctx)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("$SYS.REQ.ACCOUNT.%s.CLAIMS.UPDATE", r.accountPub)
+	msg, err := r.systemConn.RequestWithContext(ctx, subject, []byte(accountJwt))
+	if err != nil {
+		return fmt.Errorf("failed to send account update: %w", err)
+	}
+
+	var response server.ServerAPIClaimUpdateResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return fmt.Errorf("failed to parse account update response: %w", err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("account update rejected: %s", response.Error.Description)
+	}
+
+	slog.Debug("Synced account revocations", "account", r.accountPub)
+	return nil
+}
+
+// Revoke revokes the credential in the registry and pushes the new revocation
+// list to the server.
+func (r *AccountRevoker) Revoke(ctx context.Context, id string) (IssuedCredential, error) {
+	credential, err := r.registry.Revoke(ctx, id)
+	if err != nil {
+		return IssuedCredential{}, err
+	}
+
+	if err := r.Sync(ctx); err != nil {
+		return credential, err
+	}
+	return credential, nil
+}
+
+// TrackLastSeen records when issued credentials connect, using the connect
+// events the server publishes for the account.
+func (r *AccountRevoker) TrackLastSeen() (*nats.Subscription, error) {
+	subject := fmt.Sprintf("$SYS.ACCOUNT.%s.CONNECT", r.accountPub)
+	return r.systemConn.Subscribe(subject, func(msg *nats.Msg) {
+		var event server.ConnectEventMsg
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			slog.Error("Failed to parse connect event", "error", err)
+			return
+		}
+		if event.Client.User == "" {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.registry.MarkSeen(ctx, event.Client.User, event.Time); err != nil {
+			slog.Error("Failed to mark credential as seen", "error", err)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package serverauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nkeys"
+)
+
+const (
+	ErrUnknownIssuer     = "credentials were not issued by this account"
+	ErrCredentialExpired = "credentials expired"
+	ErrCredentialRevoked = "credentials revoked"
+	ErrInvalidSignature  = "invalid signature"
+	ErrNonceOutOfWindow  = "nonce is too old or in the future"
+	ErrNonceReused       = "nonce was already used"
+	ErrPublishNotAllowed = "publishing to this subject is not allowed"
+)
+
+// maxNonceClockSkew is how far the signed nonce may be from the server's clock.
+const maxNonceClockSkew = 5 * time.Minute
+
+// RevokedCheckInterval is how often long lived sessions should call CheckRevoked.
+const RevokedCheckInterval = time.Minute
+
+// CredentialVerifier authenticates clients using their NATS credentials
+// outside of NATS, e.g. on the gRPC transport. Clients prove they own the seed
+// by signing a nonce, the current time, with it. Each nonce is accepted once,
+// so a captured nonce and signature can't be used to connect again.
+type CredentialVerifier struct {
+	accountPublicKey string
+	issuers          map[string]bool
+	registry         CredentialRegistry
+
+	mutex sync.Mutex
+	// usedNonces holds when the nonces accepted within the clock skew
+	// window were signed, by user and nonce
+	usedNonces map[string]time.Time
+}
+
+// NewCredentialVerifier accepts credentials signed with accountSeed, the same
+// seed NatsCredentialService issues them with. Revoked credentials are
+// rejected when registry is set.
+func NewCredentialVerifier(accountSeed string, accountPublicKey string, registry CredentialRegistry) (*CredentialVerifier, error) {
+	accountKp, err := nkeys.FromSeed([]byte(accountSeed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse account seed: %w", err)
+	}
+	signingKey, err := accountKp.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account public key: %w", err)
+	}
+	if accountPublicKey == "" {
+		accountPublicKey = signingKey
+	}
+
+	return &CredentialVerifier{
+		accountPublicKey: accountPublicKey,
+		issuers:          map[string]bool{signingKey: true, accountPublicKey: true},
+		registry:         registry,
+		usedNonces:       map[string]time.Time{},
+	}, nil
+}
+
+// NewNonce returns the nonce a client signs when connecting.
+func NewNonce() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// SignNonce signs nonce with the user seed from the client's credentials.
+func SignNonce(seed string, nonce string) (string, error) {
+	userKp, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse seed: %w", err)
+	}
+	signature, err := userKp.Sign([]byte(nonce))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign nonce: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify checks that userJwt was issued by the account, is still valid and
+// that signature is the nonce signed by the user's seed.
+func (v *CredentialVerifier) Verify(ctx context.Context, userJwt string, nonce string, signature string) (*jwt.UserClaims, error) {
+	claims, err := jwt.DecodeUserClaims(userJwt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user JWT: %w", err)
+	}
+
+	if !v.issuers[claims.Issuer] || (claims.IssuerAccount != "" && claims.IssuerAccount != v.accountPublicKey) {
+		return nil, errors.New(ErrUnknownIssuer)
+	}
+	if claims.Expires != 0 && time.Now().Unix() > claims.Expires {
+		return nil, errors.New(ErrCredentialExpired)
+	}
+
+	signedAt, err := time.Parse(time.RFC3339Nano, nonce)
+	if err != nil || time.Since(signedAt).Abs() > maxNonceClockSkew {
+		return nil, errors.New(ErrNonceOutOfWindow)
+	}
+
+	userKp, err := nkeys.FromPublicKey(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user public key: %w", err)
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errors.New(ErrInvalidSignature)
+	}
+	if err := userKp.Verify([]byte(nonce), decodedSignature); err != nil {
+		return nil, errors.New(ErrInvalidSignature)
+	}
+	if !v.useNonce(claims.Subject, nonce, signedAt) {
+		return nil, errors.New(ErrNonceReused)
+	}
+
+	if err := v.CheckRevoked(ctx, claims.Subject); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// useNonce records the nonce of a user and reports whether it wasn't used
+// before. Nonces outside the clock skew window are rejected before, so they
+// are forgotten once they leave it.
+func (v *CredentialVerifier) useNonce(publicKey string, nonce string, signedAt time.Time) bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for key, usedAt := range v.usedNonces {
+		if time.Since(usedAt) > maxNonceClockSkew {
+			delete(v.usedNonces, key)
+		}
+	}
+
+	key := publicKey + " " + nonce
+	if _, used := v.usedNonces[key]; used {
+		return false
+	}
+	v.usedNonces[key] = signedAt
+	return true
+}
+
+// CheckRevoked returns ErrCredentialRevoked when the credentials of publicKey
+// were revoked.
+func (v *CredentialVerifier) CheckRevoked(ctx context.Context, publicKey string) error {
+	if v.registry == nil {
+		return nil
+	}
+
+	revoked, err := v.registry.GetRevoked(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get revoked credentials: %w", err)
+	}
+	for _, credential := range revoked {
+		if credential.PublicKey == publicKey {
+			return errors.New(ErrCredentialRevoked)
+		}
+	}
+	return nil
+}
+
+// CanPublish applies the publish permissions of claims to subject the way
+// the NATS server does.
+func CanPublish(claims *jwt.UserClaims, subject string) bool {
+	if !server.IsValidPublishSubject(subject) {
+		return false
+	}
+	for _, denied := range claims.Permissions.Pub.Deny {
+		if server.SubjectMatchesFilter(subject, denied) {
+			return false
+		}
+	}
+	if len(claims.Permissions.Pub.Allow) == 0 {
+		return true
+	}
+	for _, allowed := range claims.Permissions.Pub.Allow {
+		if server.SubjectMatchesFilter(subject, allowed) {
+			return true
+		}
+	}
+	return false
+}
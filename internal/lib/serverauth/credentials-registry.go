@@ -0,0 +1,178 @@
+package serverauth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IssuedCredential is a NATS user credential handed out to a client. The seed
+// is never stored, only the public key needed to revoke it. For HTTP ingest
+// tokens the public key is the hash of the token.
+type IssuedCredential struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PublicKey  string             `bson:"public_key" json:"publicKey"`
+	ProjectId  string             `bson:"project_id" json:"projectId"`
+	ClientId   string             `bson:"client_id" json:"clientId"`
+	Scope      string             `bson:"scope,omitempty" json:"scope,omitempty"`
+	IssuedBy   string             `bson:"issued_by" json:"issuedBy"`
+	IssuedAt   time.Time          `bson:"issued_at" json:"issuedAt"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	LastSeenAt *time.Time         `bson:"last_seen_at,omitempty" json:"lastSeenAt,omitempty"`
+}
+
+func (c IssuedCredential) Revoked() bool {
+	return c.RevokedAt != nil
+}
+
+func (c IssuedCredential) Expired() bool {
+	return c.ExpiresAt != nil && c.ExpiresAt.Before(time.Now())
+}
+
+type CredentialRegistry interface {
+	Record(ctx context.Context, credential IssuedCredential) (IssuedCredential, error)
+	GetCredentials(ctx context.Context, projectId string) ([]IssuedCredential, error)
+	GetCredential(ctx context.Context, id string) (IssuedCredential, error)
+	GetByPublicKey(ctx context.Context, publicKey string) (IssuedCredential, error)
+	Revoke(ctx context.Context, id string) (IssuedCredential, error)
+	// GetRevoked returns revoked credentials that have not expired yet, the
+	// ones the account JWT still has to list.
+	GetRevoked(ctx context.Context) ([]IssuedCredential, error)
+	MarkSeen(ctx context.Context, publicKey string, at time.Time) error
+}
+
+const (
+	ErrCredentialNotFound = "credential not found"
+)
+
+type MongoCredentialRegistry struct {
+	collection *mongo.Collection
+}
+
+var _ CredentialRegistry = &MongoCredentialRegistry{}
+
+func (self *MongoCredentialRegistry) Record(ctx context.Context, credential IssuedCredential) (IssuedCredential, error) {
+	if credential.IssuedAt.IsZero() {
+		credential.IssuedAt = time.Now()
+	}
+
+	result, err := self.collection.InsertOne(ctx, credential)
+	if err != nil {
+		slog.Error("Error recording issued credential", "error", err)
+		return IssuedCredential{}, err
+	}
+
+	credential.ID = result.InsertedID.(primitive.ObjectID)
+	return credential, nil
+}
+
+func (self *MongoCredentialRegistry) GetCredentials(ctx context.Context, projectId string) ([]IssuedCredential, error) {
+	cursor, err := self.collection.Find(ctx,
+		bson.M{"project_id": projectId},
+		options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := []IssuedCredential{}
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (self *MongoCredentialRegistry) GetCredential(ctx context.Context, id string) (IssuedCredential, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return IssuedCredential{}, errors.New(ErrCredentialNotFound)
+	}
+
+	var credential IssuedCredential
+	if err := self.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&credential); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return IssuedCredential{}, errors.New(ErrCredentialNotFound)
+		}
+		return IssuedCredential{}, err
+	}
+	return credential, nil
+}
+
+func (self *MongoCredentialRegistry) GetByPublicKey(ctx context.Context, publicKey string) (IssuedCredential, error) {
+	var credential IssuedCredential
+	if err := self.collection.FindOne(ctx, bson.M{"public_key": publicKey}).Decode(&credential); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return IssuedCredential{}, errors.New(ErrCredentialNotFound)
+		}
+		return IssuedCredential{}, err
+	}
+	return credential, nil
+}
+
+// Revoke marks the credential as revoked. Revoking it again keeps the original
+// revocation time.
+func (self *MongoCredentialRegistry) Revoke(ctx context.Context, id string) (IssuedCredential, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return IssuedCredential{}, errors.New(ErrCredentialNotFound)
+	}
+
+	_, err = self.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		slog.Error("Error revoking credential", "error", err)
+		return IssuedCredential{}, err
+	}
+
+	return self.GetCredential(ctx, id)
+}
+
+func (self *MongoCredentialRegistry) GetRevoked(ctx context.Context) ([]IssuedCredential, error) {
+	cursor, err := self.collection.Find(ctx, bson.M{
+		"revoked_at": bson.M{"$exists": true},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := []IssuedCredential{}
+	if err := cursor.All(ctx, &credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (self *MongoCredentialRegistry) MarkSeen(ctx context.Context, publicKey string, at time.Time) error {
+	_, err := self.collection.UpdateOne(ctx,
+		bson.M{"public_key": publicKey},
+		bson.M{"$set": bson.M{"last_seen_at": at}})
+	return err
+}
+
+func NewCredentialRegistry(collection *mongo.Collection) *MongoCredentialRegistry {
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "public_key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "issued_at", Value: -1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return &MongoCredentialRegistry{collection: collection}
+}
@@ -0,0 +1,144 @@
+package serverauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/lib/projects"
+)
+
+const ErrInvalidIngestToken = "invalid ingest token"
+
+// ingestTokenPrefix makes leaked tokens easy to recognize
+const ingestTokenPrefix = "svarog_"
+
+// ingestTokenCacheTTL is how long an authenticated token is trusted without
+// looking it up again, so revoking a token takes effect within this interval.
+const ingestTokenCacheTTL = 30 * time.Second
+
+// IngestToken is a bearer token for the HTTP ingest endpoints of a client.
+// The token itself is only returned when it's generated.
+type IngestToken struct {
+	Token     string `json:"token"`
+	ProjectId string `json:"projectId"`
+	ClientId  string `json:"clientId"`
+}
+
+type cachedIngestToken struct {
+	credential IssuedCredential
+	expiresAt  time.Time
+}
+
+// IngestTokenService issues and checks tokens for shippers that can't use
+// NATS credentials, like OpenTelemetry exporters. Tokens are recorded in the
+// credential registry next to the NATS credentials and revoked the same way.
+type IngestTokenService struct {
+	projectsService projects.ProjectsService
+	registry        CredentialRegistry
+
+	mutex sync.Mutex
+	cache map[string]cachedIngestToken
+}
+
+func NewIngestTokenService(projectsService projects.ProjectsService, registry CredentialRegistry) (*IngestTokenService, error) {
+	if projectsService == nil {
+		return nil, errors.New("projectsService is required")
+	}
+	if registry == nil {
+		return nil, errors.New("registry is required")
+	}
+
+	return &IngestTokenService{
+		projectsService: projectsService,
+		registry:        registry,
+		cache:           map[string]cachedIngestToken{},
+	}, nil
+}
+
+func (s *IngestTokenService) Generate(ctx context.Context, generationRequest CredentialGenerationRequest) (IngestToken, error) {
+	if exists := s.projectsService.ProjectExists(ctx, generationRequest.ProjectID, generationRequest.ClientID); !exists {
+		return IngestToken{}, errors.New("project not found")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return IngestToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := ingestTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	credential := IssuedCredential{
+		PublicKey: ingestTokenKey(token),
+		ProjectId: generationRequest.ProjectID,
+		ClientId:  generationRequest.ClientID,
+		Scope:     CredentialScopeIngest,
+		IssuedBy:  generationRequest.IssuedBy,
+		IssuedAt:  time.Now(),
+	}
+	if generationRequest.Expiry.Valid {
+		expiresAt := generationRequest.Expiry.Time
+		credential.ExpiresAt = &expiresAt
+	}
+
+	if _, err := s.registry.Record(ctx, credential); err != nil {
+		return IngestToken{}, fmt.Errorf("failed to record ingest token: %w", err)
+	}
+
+	return IngestToken{
+		Token:     token,
+		ProjectId: generationRequest.ProjectID,
+		ClientId:  generationRequest.ClientID,
+	}, nil
+}
+
+// Authenticate returns the credential of token, or ErrInvalidIngestToken when
+// the token is unknown, revoked or expired.
+func (s *IngestTokenService) Authenticate(ctx context.Context, token string) (IssuedCredential, error) {
+	if !strings.HasPrefix(token, ingestTokenPrefix) {
+		return IssuedCredential{}, errors.New(ErrInvalidIngestToken)
+	}
+	key := ingestTokenKey(token)
+
+	s.mutex.Lock()
+	cached, ok := s.cache[key]
+	s.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) && !cached.credential.Expired() {
+		return cached.credential, nil
+	}
+
+	credential, err := s.registry.GetByPublicKey(ctx, key)
+	if err != nil {
+		if err.Error() == ErrCredentialNotFound {
+			return IssuedCredential{}, errors.New(ErrInvalidIngestToken)
+		}
+		return IssuedCredential{}, err
+	}
+	if credential.Scope != CredentialScopeIngest || credential.Revoked() || credential.Expired() {
+		return IssuedCredential{}, errors.New(ErrInvalidIngestToken)
+	}
+
+	if err := s.registry.MarkSeen(ctx, key, time.Now()); err != nil {
+		slog.Error("Failed to mark ingest token as seen", "error", err)
+	}
+
+	s.mutex.Lock()
+	s.cache[key] = cachedIngestToken{credential: credential, expiresAt: time.Now().Add(ingestTokenCacheTTL)}
+	s.mutex.Unlock()
+
+	return credential, nil
+}
+
+// ingestTokenKey is the registry key of token, tokens are only stored hashed.
+func ingestTokenKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
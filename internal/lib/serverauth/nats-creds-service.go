@@ -19,12 +19,19 @@ type NatsCredentialService struct {
 	accountPublicKey string
 	natsPublicAddr   string
 	projectsService  projects.ProjectsService
+	registry         CredentialRegistry
 }
 
+// NewNatsCredentialService signs user credentials with accountSeed. When the
+// seed belongs to an account signing key, accountPublicKey must be the public
+// key of the account itself, otherwise it can be left empty. Issued credentials
+// are recorded in registry when it is set.
 func NewNatsCredentialService(
 	accountSeed string,
+	accountPublicKey string,
 	natsPublicAddr string,
-	projectsService projects.ProjectsService) (*NatsCredentialService, error) {
+	projectsService projects.ProjectsService,
+	registry CredentialRegistry) (*NatsCredentialService, error) {
 	if projectsService == nil {
 		return nil, errors.New("projectsService is required")
 	}
@@ -37,23 +44,38 @@ func NewNatsCredentialService(
 		return nil, fmt.Errorf("failed to parse account seed: %w", err)
 	}
 
-	accountPubKey, err := accountKp.PublicKey()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get account public key: %w", err)
+	if accountPublicKey == "" {
+		accountPublicKey, err = accountKp.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account public key: %w", err)
+		}
 	}
 
 	return &NatsCredentialService{
 		accountKeyPair:   accountKp,
-		accountPublicKey: accountPubKey,
+		accountPublicKey: accountPublicKey,
 		projectsService:  projectsService,
 		natsPublicAddr:   natsPublicAddr,
+		registry:         registry,
 	}, nil
 }
 
+const (
+	// CredentialScopePublish allows publishing log lines of a client
+	CredentialScopePublish = "publish"
+	// CredentialScopeTail only allows subscribing to the client's live lines
+	CredentialScopeTail = "tail"
+	// CredentialScopeIngest is a bearer token for the HTTP ingest endpoints
+	CredentialScopeIngest = "ingest"
+)
+
 type CredentialGenerationRequest struct {
 	ProjectID string             `form:"projectId" validate:"required"`
 	ClientID  string             `form:"clientId" validate:"required"`
 	Expiry    types.NullableDate `form:"expiry"`
+	Scope     string             `form:"scope" validate:"omitempty,oneof=publish tail ingest"`
+	// IssuedBy is the username of the admin generating the credentials
+	IssuedBy string `form:"-" json:"-"`
 }
 
 func (s *NatsCredentialService) GenerateConnString(ctx context.Context, generationRequest CredentialGenerationRequest) (config.ClientConfig, error) {
@@ -71,6 +93,47 @@ func (s *NatsCredentialService) GenerateConnString(ctx context.Context, generati
 	}, nil
 }
 
+// TailCredentials let a tool outside the browser follow a client's live lines.
+type TailCredentials struct {
+	ServerUrl string `json:"serverUrl"`
+	Subject   string `json:"subject"`
+	Creds     string `json:"creds"`
+}
+
+// InboxPrefix is the reply subject prefix of a client publishing with
+// credentials issued to userJwt. Clients only receive the JetStream acks of
+// their own publishes, subscribing to other subjects is denied.
+func InboxPrefix(userJwt string) (string, error) {
+	claims, err := jwt.DecodeUserClaims(userJwt)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode user JWT: %w", err)
+	}
+	return inboxPrefix(claims.Subject), nil
+}
+
+func inboxPrefix(userPublicKey string) string {
+	return "_INBOX_" + userPublicKey
+}
+
+// LiveTailSubject is the subject carrying the structured live lines of a client.
+func LiveTailSubject(projectId, clientId string) string {
+	return fmt.Sprintf("live.logs.%s.%s", projectId, clientId)
+}
+
+func (s *NatsCredentialService) GenerateTailCreds(ctx context.Context, generationRequest CredentialGenerationRequest) (TailCredentials, error) {
+	generationRequest.Scope = CredentialScopeTail
+	creds, err := s.GenerateUserCreds(ctx, generationRequest)
+	if err != nil {
+		return TailCredentials{}, err
+	}
+
+	return TailCredentials{
+		ServerUrl: fmt.Sprintf("nats://%s", s.natsPublicAddr),
+		Subject:   LiveTailSubject(generationRequest.ProjectID, generationRequest.ClientID),
+		Creds:     creds,
+	}, nil
+}
+
 func (s *NatsCredentialService) GenerateUserCreds(ctx context.Context, generationRequest CredentialGenerationRequest) (string, error) {
 	if exists := s.projectsService.ProjectExists(ctx, generationRequest.ProjectID, generationRequest.ClientID); !exists {
 		return "", errors.New("project not found")
@@ -82,14 +145,56 @@ func (s *NatsCredentialService) GenerateUserCreds(ctx context.Context, generatio
 		expiry = &duration
 	}
 
-	topic := fmt.Sprintf("logs.%s.%s", generationRequest.ProjectID, generationRequest.ClientID)
-	return s.generateUserCreds(generationRequest.ProjectID, []string{topic}, []string{}, expiry)
+	var pubAllowed, subAllowed []string
+	switch generationRequest.Scope {
+	case CredentialScopeTail:
+		subAllowed = []string{LiveTailSubject(generationRequest.ProjectID, generationRequest.ClientID)}
+	default:
+		generationRequest.Scope = CredentialScopePublish
+		pubAllowed = []string{fmt.Sprintf("logs.%s.%s", generationRequest.ProjectID, generationRequest.ClientID)}
+	}
+
+	creds, userPub, err := s.generateUserCreds(generationRequest.ProjectID, pubAllowed, subAllowed, expiry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.record(ctx, userPub, generationRequest); err != nil {
+		return "", err
+	}
+
+	return creds, nil
+}
+
+// record stores the issued credential so it can be listed and revoked later.
+func (s *NatsCredentialService) record(ctx context.Context, publicKey string, generationRequest CredentialGenerationRequest) error {
+	if s.registry == nil {
+		return nil
+	}
+
+	credential := IssuedCredential{
+		PublicKey: publicKey,
+		ProjectId: generationRequest.ProjectID,
+		ClientId:  generationRequest.ClientID,
+		Scope:     generationRequest.Scope,
+		IssuedBy:  generationRequest.IssuedBy,
+		IssuedAt:  time.Now(),
+	}
+	if generationRequest.Expiry.Valid {
+		expiresAt := generationRequest.Expiry.Time
+		credential.ExpiresAt = &expiresAt
+	}
+
+	if _, err := s.registry.Record(ctx, credential); err != nil {
+		return fmt.Errorf("failed to record credentials: %w", err)
+	}
+	return nil
 }
 
-func (s *NatsCredentialService) generateUserCreds(username string, pubAllowed []string, subAllowed []string, expiry *time.Duration) (string, error) {
+func (s *NatsCredentialService) generateUserCreds(username string, pubAllowed []string, subAllowed []string, expiry *time.Duration) (string, string, error) {
 	userKp, err := nkeys.CreateUser()
 	if err != nil {
-		return "", fmt.Errorf("failed to create user key pair: %w", err)
+		return "", "", fmt.Errorf("failed to create user key pair: %w", err)
 	}
 
 	userPub, _ := userKp.PublicKey()
@@ -106,25 +211,32 @@ func (s *NatsCredentialService) generateUserCreds(username string, pubAllowed []
 	// Publish Permissions
 	if len(pubAllowed) > 0 {
 		claims.Permissions.Pub.Allow.Add(pubAllowed...)
+		claims.Permissions.Resp = &jwt.ResponsePermission{
+			MaxMsgs: 1,
+			Expires: time.Minute * 5,
+		}
+		// The acks of JetStream publishes
+		subAllowed = append(subAllowed, inboxPrefix(userPub)+".>")
+	} else {
+		// An empty allow list permits everything, subscribe-only users publish nothing
+		claims.Permissions.Pub.Deny.Add(">")
 	}
 	// Subscribe Permissions
 	if len(subAllowed) > 0 {
 		claims.Permissions.Sub.Allow.Add(subAllowed...)
-	}
-	claims.Permissions.Resp = &jwt.ResponsePermission{
-		MaxMsgs: 1,
-		Expires: time.Minute * 5,
+	} else {
+		claims.Permissions.Sub.Deny.Add(">")
 	}
 
 	userJwt, err := claims.Encode(s.accountKeyPair)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode user claims: %w", err)
+		return "", "", fmt.Errorf("failed to encode user claims: %w", err)
 	}
 
 	credsBytes, err := jwt.FormatUserConfig(userJwt, userSeed)
 	if err != nil {
-		return "", fmt.Errorf("failed to format creds: %w", err)
+		return "", "", fmt.Errorf("failed to format creds: %w", err)
 	}
 
-	return string(credsBytes), nil
+	return string(credsBytes), userPub, nil
 }
@@ -0,0 +1,143 @@
+package snippets
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/markojerkic/svarog/cmd/client/config"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("snippets").
+	Funcs(template.FuncMap{"shellQuote": shellQuote}).
+	ParseFS(templateFiles, "templates/*.tmpl"))
+
+type Kind string
+
+const (
+	KindDocker     Kind = "docker"
+	KindCompose    Kind = "compose"
+	KindSystemd    Kind = "systemd"
+	KindKubernetes Kind = "kubernetes"
+	KindShell      Kind = "shell"
+)
+
+// File is a single file or command of a snippet.
+type File struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Snippet is a ready to use way of running the client with a connection string.
+type Snippet struct {
+	Kind  Kind   `json:"kind"`
+	Title string `json:"title"`
+	Files []File `json:"files"`
+}
+
+type snippetDefinition struct {
+	kind  Kind
+	title string
+	// files maps the displayed file name to the template rendering it
+	files [][2]string
+}
+
+var definitions = []snippetDefinition{
+	{KindDocker, "Dockerfile", [][2]string{
+		{"Dockerfile", "Dockerfile"},
+		{"svarog.env", "svarog.env"},
+		{"Run", "docker run"},
+	}},
+	{KindCompose, "Docker Compose sidecar", [][2]string{
+		{"docker-compose.yml", "docker-compose.yml"},
+		{"svarog.env", "svarog.env"},
+	}},
+	{KindSystemd, "systemd", [][2]string{
+		{"/etc/systemd/system/{{name}}-svarog.service", "systemd unit"},
+		{"/etc/svarog/{{name}}.env", "systemd env"},
+	}},
+	{KindKubernetes, "Kubernetes", [][2]string{
+		{"{{name}}-svarog.yaml", "kubernetes"},
+	}},
+	{KindShell, "Shell", [][2]string{
+		{"Shell", "shell"},
+	}},
+}
+
+type templateData struct {
+	ConnString       string
+	ConnStringBase64 string
+	ClientId         string
+	Name             string
+	Image            string
+}
+
+type Generator struct {
+	clientImage string
+}
+
+// NewGenerator renders snippets running clientImage, the image containing
+// the svarog client at /svarog/client.
+func NewGenerator(clientImage string) *Generator {
+	return &Generator{clientImage: clientImage}
+}
+
+// Render renders every snippet for the client described by clientConfig.
+// The connection string is only placed in env files, a Kubernetes secret or a
+// quoted shell variable, never on a command line of a long running process.
+func (g *Generator) Render(clientConfig config.ClientConfig, clientId string) ([]Snippet, error) {
+	connString := clientConfig.GetConnString()
+	data := templateData{
+		ConnString:       connString,
+		ConnStringBase64: base64.StdEncoding.EncodeToString([]byte(connString)),
+		ClientId:         clientId,
+		Name:             ResourceName(clientId),
+		Image:            g.clientImage,
+	}
+
+	snippets := make([]Snippet, 0, len(definitions))
+	for _, definition := range definitions {
+		snippet := Snippet{Kind: definition.kind, Title: definition.title}
+		for _, file := range definition.files {
+			var buf bytes.Buffer
+			if err := templates.ExecuteTemplate(&buf, file[1], data); err != nil {
+				return nil, fmt.Errorf("failed to render %s snippet: %w", definition.kind, err)
+			}
+			snippet.Files = append(snippet.Files, File{
+				Name:    strings.ReplaceAll(file[0], "{{name}}", data.Name),
+				Content: buf.String(),
+			})
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// ResourceName turns a client id into a name valid for Kubernetes resources,
+// systemd units and compose services.
+func ResourceName(clientId string) string {
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(clientId), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 40 {
+		name = strings.TrimRight(name[:40], "-")
+	}
+	if name == "" {
+		return "app"
+	}
+	return name
+}
+
+// shellQuote wraps value in single quotes so the shell doesn't expand it.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,64 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// MaxMessageSize limits the size of a single framed message.
+const MaxMessageSize = 64 * 1024
+
+// SplitFrames is a bufio.SplitFunc for syslog over TCP. Every frame is read
+// either with octet counting (RFC 6587, "<length> <message>") or as a line
+// terminated by a newline or NUL, so senders may mix both on one connection.
+func SplitFrames(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	// Octet counted messages start with the length, framed ones with "<"
+	if data[0] >= '1' && data[0] <= '9' {
+		space := bytes.IndexByte(data, ' ')
+		if space < 0 {
+			if len(data) > 7 {
+				return 0, nil, errors.New("invalid syslog frame length")
+			}
+			if atEOF {
+				return 0, nil, errors.New("incomplete syslog frame")
+			}
+			return 0, nil, nil
+		}
+
+		length, err := strconv.Atoi(string(data[:space]))
+		if err != nil || length > MaxMessageSize {
+			return 0, nil, errors.New("invalid syslog frame length")
+		}
+		end := space + 1 + length
+		if len(data) < end {
+			if atEOF {
+				return 0, nil, errors.New("incomplete syslog frame")
+			}
+			return 0, nil, nil
+		}
+		return end, data[space+1 : end], nil
+	}
+
+	if i := bytes.IndexAny(data, "\n\x00"); i >= 0 {
+		return i + 1, bytes.TrimRight(data[:i], "\r"), nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// NewFrameScanner reads framed messages of a TCP connection.
+func NewFrameScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 4096), MaxMessageSize+16)
+	scanner.Split(SplitFrames)
+	return scanner
+}
@@ -0,0 +1,209 @@
+package syslog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const ErrMissingPriority = "syslog message has no priority"
+
+// Message is a parsed RFC 5424 or RFC 3164 syslog message. Header fields
+// that were not set (the NILVALUE "-") are empty.
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcId    string
+	MsgId     string
+	// StructuredData is keyed by SD-ID and then by parameter name.
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+var severityLevels = []string{"fatal", "fatal", "fatal", "error", "warn", "info", "info", "debug"}
+
+// Level maps the syslog severity to the levels svarog stores.
+func (m Message) Level() string {
+	if m.Severity < 0 || m.Severity >= len(severityLevels) {
+		return ""
+	}
+	return severityLevels[m.Severity]
+}
+
+// Parse parses an RFC 5424 message, or an RFC 3164 (BSD) message when the
+// priority isn't followed by a version. now is used for the missing year of
+// BSD timestamps and for messages without a timestamp.
+func Parse(data []byte, now time.Time) (Message, error) {
+	raw := strings.TrimRight(string(data), "\r\n\x00")
+	if !strings.HasPrefix(raw, "<") {
+		return Message{}, errors.New(ErrMissingPriority)
+	}
+	end := strings.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return Message{}, errors.New(ErrMissingPriority)
+	}
+	priority, err := strconv.Atoi(raw[1:end])
+	if err != nil || priority > 191 {
+		return Message{}, errors.New(ErrMissingPriority)
+	}
+
+	message := Message{
+		Facility:  priority / 8,
+		Severity:  priority % 8,
+		Timestamp: now,
+	}
+	rest := raw[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		return parseRFC5424(message, rest[2:])
+	}
+	return parseRFC3164(message, rest, now), nil
+}
+
+func parseRFC5424(message Message, rest string) (Message, error) {
+	var header [5]string
+	for i := range header {
+		var ok bool
+		header[i], rest, ok = strings.Cut(rest, " ")
+		if !ok && i < len(header)-1 {
+			return Message{}, errors.New("syslog message header is incomplete")
+		}
+	}
+
+	if header[0] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, header[0])
+		if err != nil {
+			return Message{}, errors.New("invalid syslog timestamp")
+		}
+		message.Timestamp = timestamp
+	}
+	message.Hostname = nilValue(header[1])
+	message.AppName = nilValue(header[2])
+	message.ProcId = nilValue(header[3])
+	message.MsgId = nilValue(header[4])
+
+	structuredData, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return Message{}, err
+	}
+	message.StructuredData = structuredData
+	message.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+
+	return message, nil
+}
+
+func nilValue(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+// parseStructuredData parses the SD-ELEMENTs at the start of rest and returns
+// the remaining message.
+func parseStructuredData(rest string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(rest, "-") {
+		return nil, rest[1:], nil
+	}
+
+	elements := map[string]map[string]string{}
+	for strings.HasPrefix(rest, "[") {
+		rest = rest[1:]
+		idEnd := strings.IndexAny(rest, " ]")
+		if idEnd <= 0 {
+			return nil, "", errors.New("invalid syslog structured data")
+		}
+		params := map[string]string{}
+		elements[rest[:idEnd]] = params
+		rest = rest[idEnd:]
+
+		for {
+			rest = strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(rest, "]") {
+				rest = rest[1:]
+				break
+			}
+
+			name, after, ok := strings.Cut(rest, "=\"")
+			if !ok || name == "" {
+				return nil, "", errors.New("invalid syslog structured data")
+			}
+
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(after); i++ {
+				if after[i] == '\\' && i+1 < len(after) && strings.IndexByte(`"\]`, after[i+1]) >= 0 {
+					value.WriteByte(after[i+1])
+					i++
+					continue
+				}
+				if after[i] == '"' {
+					rest = after[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(after[i])
+			}
+			if !closed {
+				return nil, "", errors.New("invalid syslog structured data")
+			}
+			params[name] = value.String()
+		}
+	}
+
+	return elements, rest, nil
+}
+
+// parseRFC3164 is lenient, BSD syslog senders rarely agree on the format.
+// Parts that can't be parsed are left in the message.
+func parseRFC3164(message Message, rest string, now time.Time) Message {
+	if len(rest) >= len(time.Stamp) {
+		if timestamp, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], now.Location()); err == nil {
+			timestamp = timestamp.AddDate(now.Year(), 0, 0)
+			// Messages from late December received in January
+			if timestamp.After(now.Add(24 * time.Hour)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+			message.Timestamp = timestamp
+			rest = strings.TrimLeft(rest[len(time.Stamp):], " ")
+		}
+	}
+
+	// The hostname is missing when the first word is already the tag
+	if word, after, ok := strings.Cut(rest, " "); ok && !isTag(word) {
+		message.Hostname = word
+		rest = after
+	}
+
+	if word, after, ok := strings.Cut(rest, " "); ok && isTag(word) {
+		tag := strings.TrimSuffix(word, ":")
+		if name, pid, ok := strings.Cut(tag, "["); ok {
+			message.AppName = name
+			message.ProcId = strings.TrimSuffix(pid, "]")
+		} else {
+			message.AppName = tag
+		}
+		rest = after
+	}
+
+	message.Message = rest
+	return message
+}
+
+func isTag(word string) bool {
+	if !strings.HasSuffix(word, ":") || len(word) == 1 {
+		return false
+	}
+	name, _, _ := strings.Cut(strings.TrimSuffix(word, ":"), "[")
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./", r) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,105 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule routes matching messages to a project and client. Empty conditions
+// match every message, hostname and app are glob patterns and source is the
+// sender's network in CIDR notation. The client may contain the {hostname}
+// and {app} placeholders, dots in their values are replaced by dashes so the
+// client stays a single NATS subject token.
+type Rule struct {
+	Hostname string `yaml:"hostname"`
+	App      string `yaml:"app"`
+	Source   string `yaml:"source"`
+	Project  string `yaml:"project"`
+	Client   string `yaml:"client"`
+
+	sourceNet *net.IPNet
+}
+
+// Rules are checked in order, the first matching rule wins. Messages that
+// match no rule are dropped.
+type Rules struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// LoadRules reads the routing rules from a YAML file.
+func LoadRules(file string) (*Rules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read syslog rules: %w", err)
+	}
+	return ParseRules(data)
+}
+
+func ParseRules(data []byte) (*Rules, error) {
+	rules := &Rules{}
+	if err := yaml.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("invalid syslog rules: %w", err)
+	}
+	if len(rules.Rules) == 0 {
+		return nil, errors.New("syslog rules are empty")
+	}
+
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if rule.Project == "" || rule.Client == "" {
+			return nil, fmt.Errorf("syslog rule %d needs a project and a client", i+1)
+		}
+		for _, pattern := range []string{rule.Hostname, rule.App} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("syslog rule %d has an invalid pattern %q", i+1, pattern)
+			}
+		}
+		if rule.Source != "" {
+			_, sourceNet, err := net.ParseCIDR(rule.Source)
+			if err != nil {
+				return nil, fmt.Errorf("syslog rule %d has an invalid source: %w", i+1, err)
+			}
+			rule.sourceNet = sourceNet
+		}
+	}
+
+	return rules, nil
+}
+
+// Route returns the project and client of a message sent from sender.
+func (r *Rules) Route(message Message, sender net.IP) (string, string, bool) {
+	for _, rule := range r.Rules {
+		if !matches(rule.Hostname, message.Hostname) || !matches(rule.App, message.AppName) {
+			continue
+		}
+		if rule.sourceNet != nil && (sender == nil || !rule.sourceNet.Contains(sender)) {
+			continue
+		}
+
+		client := strings.NewReplacer("{hostname}", subjectToken(message.Hostname), "{app}", subjectToken(message.AppName)).Replace(rule.Client)
+		if client == "" {
+			continue
+		}
+		return rule.Project, client, true
+	}
+
+	return "", "", false
+}
+
+func matches(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+func subjectToken(value string) string {
+	return strings.NewReplacer(".", "-", " ", "-", "*", "-", ">", "-").Replace(value)
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// LogBatch is a batch of log lines pushed over the gRPC transport. Id is
+// chosen by the client and echoed back in the ack.
+type LogBatch struct {
+	Id    uint64    `json:"id"`
+	Lines []LogLine `json:"lines"`
+	// Topic publishes the batch to another subject than the stream's topic,
+	// the stream's credentials have to allow it.
+	Topic string `json:"topic,omitempty"`
+}
+
+// PushAck acknowledges a LogBatch once its lines are stored in JetStream.
+// Error is set when the batch wasn't accepted and should be resent.
+type PushAck struct {
+	Id    uint64 `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+
+// Metadata keys authenticating a Push stream with the client's NATS credentials
+const (
+	MetadataJWT       = "svarog-jwt"
+	MetadataNonce     = "svarog-nonce"
+	MetadataSignature = "svarog-signature"
+	MetadataTopic     = "svarog-topic"
+)
+
+// CodecName is the content subtype of the JSON codec used by LogIngest,
+// LogLine is a plain Go struct and not a protobuf message.
+const CodecName = "json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return CodecName }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+const LogIngest_Push_FullMethodName = "/svarog.LogIngest/Push"
+
+// LogIngestServer receives batches of log lines and acks each of them.
+type LogIngestServer interface {
+	Push(grpc.BidiStreamingServer[LogBatch, PushAck]) error
+}
+
+func RegisterLogIngestServer(s grpc.ServiceRegistrar, srv LogIngestServer) {
+	s.RegisterService(&LogIngest_ServiceDesc, srv)
+}
+
+func _LogIngest_Push_Handler(srv any, stream grpc.ServerStream) error {
+	return srv.(LogIngestServer).Push(&grpc.GenericServerStream[LogBatch, PushAck]{ServerStream: stream})
+}
+
+var LogIngest_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "svarog.LogIngest",
+	HandlerType: (*LogIngestServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Push",
+			Handler:       _LogIngest_Push_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+type LogIngestClient interface {
+	Push(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LogBatch, PushAck], error)
+}
+
+type logIngestClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLogIngestClient(cc grpc.ClientConnInterface) LogIngestClient {
+	return &logIngestClient{cc}
+}
+
+func (c *logIngestClient) Push(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[LogBatch, PushAck], error) {
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(CodecName)}, opts...)
+	stream, err := c.cc.NewStream(ctx, &LogIngest_ServiceDesc.Streams[0], LogIngest_Push_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[LogBatch, PushAck]{ClientStream: stream}, nil
+}
@@ -10,6 +10,16 @@ type LogLine struct {
 	Timestamp  time.Time `json:"timestamp"`
 	Sequence   int       `json:"sequence"`
 	InstanceId string    `json:"instanceId"`
+	// Fields are structured fields read by the client, like container labels
+	Fields map[string]any `json:"fields,omitempty"`
+	// Level, TraceId and SpanId are read by the HTTP and syslog receivers
+	// from the shipped lines
+	Level   string `json:"level,omitempty"`
+	TraceId string `json:"traceId,omitempty"`
+	SpanId  string `json:"spanId,omitempty"`
+	// ClientId publishes the line as another client of the project, it needs
+	// project-wide credentials. It isn't sent, the subject carries it.
+	ClientId string `json:"-"`
 }
 
 func (l *LogLine) Validate() error {
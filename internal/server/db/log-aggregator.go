@@ -3,11 +3,13 @@ package db
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"log/slog"
 
 	"github.com/markojerkic/svarog/internal/lib/backlog"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
 	"github.com/markojerkic/svarog/internal/rpc"
 	"github.com/markojerkic/svarog/internal/server/types"
 )
@@ -17,12 +19,22 @@ type LogLineWithHost struct {
 	ProjectId string
 	ClientId  string
 	Hostname  string
+	Level     string
+	Fields    map[string]any
+	// TraceId and SpanId correlate the line with a trace, set by OpenTelemetry shippers
+	TraceId string
+	SpanId  string
+
+	Stream         string
+	StreamSequence uint64
 }
 
 type AggregatingLogServer interface {
 	Run(ctx context.Context, logIngestChannel <-chan LogLineWithHost)
 	IsBacklogEmpty() bool
 	BacklogCount() int
+	BacklogCapacity() int
+	SavedCount() int64
 }
 
 type LogServer struct {
@@ -31,6 +43,7 @@ type LogServer struct {
 
 	logs    chan types.StoredLog
 	backlog backlog.Backlog[types.StoredLog]
+	saved   atomic.Int64
 }
 type AvailableClient struct {
 	Client   types.StoredClient
@@ -39,20 +52,28 @@ type AvailableClient struct {
 
 var _ AggregatingLogServer = &LogServer{}
 
+// maxBacklogLines is how many lines wait to be saved before ingestion blocks.
+const maxBacklogLines = 256 * 1024
+
 func NewLogServer(dbClient LogService) AggregatingLogServer {
 	return &LogServer{
 		logService: dbClient,
 		logs:       make(chan types.StoredLog, 1024*1024),
-		backlog:    backlog.NewBacklog[types.StoredLog](1024 * 1024),
+		backlog:    backlog.NewBacklog[types.StoredLog](maxBacklogLines),
 	}
 }
 
 func (self *LogServer) dumpBacklog(ctx context.Context, logsToSave []types.StoredLog) {
-	err := self.logService.SaveLogs(ctx, logsToSave)
+	start := time.Now()
+	// Batches queued before shutdown must still be saved after ctx is cancelled
+	err := self.logService.SaveLogs(context.WithoutCancel(ctx), logsToSave)
+	metrics.BatchSaveDuration.Observe(time.Since(start).Seconds())
+	metrics.BatchSize.Observe(float64(len(logsToSave)))
 	if err != nil {
 		slog.Error("Could not save logs", "error", err)
 		panic(err)
 	}
+	self.saved.Add(int64(len(logsToSave)))
 }
 
 func (self *LogServer) Run(ctx context.Context, logIngestChannel <-chan LogLineWithHost) {
@@ -70,6 +91,7 @@ func (self *LogServer) Run(ctx context.Context, logIngestChannel <-chan LogLineW
 		defer wg.Done()
 		for logsToSave := range self.backlog.GetLogs() {
 			self.dumpBacklog(self.ctx, logsToSave)
+			self.backlog.Release(len(logsToSave))
 		}
 	}()
 
@@ -82,29 +104,58 @@ outer:
 				self.backlog.Close()
 				break outer
 			}
-			logLine := types.StoredLog{
-				LogLine:        line.Message,
-				Timestamp:      line.Timestamp,
-				SequenceNumber: line.Sequence,
-				Client: types.StoredClient{
-					ProjectId:  line.ProjectId,
-					ClientId:   line.ClientId,
-					InstanceId: line.Hostname,
-				},
-			}
-			self.backlog.AddToBacklog(logLine)
+			self.backlog.AddToBacklog(toStoredLog(line))
 
 		case <-interval.C:
 			self.backlog.ForceDump()
 
 		case <-ctx.Done():
-			slog.Debug("Context done")
+			drained := self.drainIngestChannel(logIngestChannel)
+			slog.Debug("Context done, flushing backlog", "drained", drained, "backlog", self.backlog.Count())
+			self.backlog.ForceDump()
 			self.backlog.Close()
 			break outer
 		}
 	}
 }
 
+// drainIngestChannel moves lines that are already queued in the ingest channel
+// into the backlog without waiting for new ones.
+func (self *LogServer) drainIngestChannel(logIngestChannel <-chan LogLineWithHost) int {
+	drained := 0
+	for {
+		select {
+		case line, ok := <-logIngestChannel:
+			if !ok {
+				return drained
+			}
+			self.backlog.AddToBacklog(toStoredLog(line))
+			drained++
+		default:
+			return drained
+		}
+	}
+}
+
+func toStoredLog(line LogLineWithHost) types.StoredLog {
+	return types.StoredLog{
+		LogLine:        line.Message,
+		Timestamp:      line.Timestamp,
+		SequenceNumber: line.Sequence,
+		Level:          line.Level,
+		Fields:         line.Fields,
+		TraceId:        line.TraceId,
+		SpanId:         line.SpanId,
+		Stream:         line.Stream,
+		StreamSequence: line.StreamSequence,
+		Client: types.StoredClient{
+			ProjectId:  line.ProjectId,
+			ClientId:   line.ClientId,
+			InstanceId: line.Hostname,
+		},
+	}
+}
+
 func (self *LogServer) IsBacklogEmpty() bool {
 	return self.backlog.IsEmpty()
 }
@@ -112,3 +163,12 @@ func (self *LogServer) IsBacklogEmpty() bool {
 func (self *LogServer) BacklogCount() int {
 	return self.backlog.Count()
 }
+
+func (self *LogServer) BacklogCapacity() int {
+	return self.backlog.Capacity()
+}
+
+// SavedCount returns the number of lines saved since the server was created.
+func (self *LogServer) SavedCount() int64 {
+	return self.saved.Load()
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/markojerkic/svarog/internal/server/types"
+)
+
+// LogSource is a client whose lines are merged with the lines of other
+// clients. Instances limits it to some of its instances.
+type LogSource struct {
+	ProjectId string
+	ClientId  string
+	Instances *[]string
+}
+
+// MergedCursor is a position in the lines of several sources merged by time.
+// Lines are ordered by timestamp and sequence number like the lines of one
+// client, and by their source when both are equal, so a page never skips a
+// line another source logged at the same instant.
+type MergedCursor struct {
+	LastCursor
+	// ProjectId and ClientId are the source of the line of the cursor
+	ProjectId string
+	ClientId  string
+}
+
+func newMergedCursor(log types.StoredLog, isBackward bool) *MergedCursor {
+	return &MergedCursor{
+		LastCursor: LastCursor{
+			Timestamp:      log.Timestamp,
+			SequenceNumber: log.SequenceNumber,
+			IsBackward:     isBackward,
+		},
+		ProjectId: log.Client.ProjectId,
+		ClientId:  log.Client.ClientId,
+	}
+}
+
+// sourceCursor is the cursor of the lines of source past the merged cursor.
+func (c *MergedCursor) sourceCursor(source LogSource) *LastCursor {
+	if c == nil {
+		return nil
+	}
+
+	cursor := c.LastCursor
+	order := cmp.Or(cmp.Compare(source.ProjectId, c.ProjectId), cmp.Compare(source.ClientId, c.ClientId))
+	// Sequence numbers are whole, so a line at the cursor's timestamp and
+	// sequence number is included by moving the cursor one past it
+	switch {
+	case c.IsBackward && order < 0:
+		cursor.SequenceNumber++
+	case !c.IsBackward && order > 0:
+		cursor.SequenceNumber--
+	}
+	return &cursor
+}
+
+// compareMerged orders lines of several sources from oldest to newest.
+func compareMerged(a, b types.StoredLog) int {
+	return cmp.Or(
+		a.Timestamp.Compare(b.Timestamp),
+		cmp.Compare(a.SequenceNumber, b.SequenceNumber),
+		cmp.Compare(a.Client.ProjectId, b.Client.ProjectId),
+		cmp.Compare(a.Client.ClientId, b.Client.ClientId),
+	)
+}
+
+// mergeLogs sorts the lines of several sources newest first and keeps the
+// page size of lines next to the cursor, reporting whether any were cut.
+func mergeLogs(logs []types.StoredLog, pageSize int64, forward bool) ([]types.StoredLog, bool) {
+	slices.SortFunc(logs, func(a, b types.StoredLog) int { return compareMerged(b, a) })
+	if int64(len(logs)) <= pageSize {
+		return logs, false
+	}
+	if forward {
+		return logs[int64(len(logs))-pageSize:], true
+	}
+	return logs[:pageSize], true
+}
+
+// MergedLogsRequest is a LogPageRequest over several sources. The cursor
+// scrolls back to older lines, like the log view does.
+type MergedLogsRequest struct {
+	Sources  []LogSource
+	PageSize int64
+	Cursor   *MergedCursor
+	TimeRange
+}
+
+// MergedLogPage is a page of the lines of several sources, newest first.
+type MergedLogPage struct {
+	Logs           []types.StoredLog
+	BackwardCursor *MergedCursor
+	// IsLastPage is set on the newest page, which follows new lines
+	IsLastPage bool
+	TimeRange  TimeRange
+}
+
+// GetSourcesLogs loads a page of the lines of every source and merges them by
+// time.
+func GetSourcesLogs(ctx context.Context, service LogService, req MergedLogsRequest) (MergedLogPage, error) {
+	if req.Cursor != nil {
+		cursor := *req.Cursor
+		cursor.IsBackward = true
+		req.Cursor = &cursor
+	}
+
+	logs := []types.StoredLog{}
+	for _, source := range req.Sources {
+		// One more line tells whether the source has older lines
+		sourcePage, err := service.GetLogs(ctx, LogPageRequest{
+			ProjectId: source.ProjectId,
+			ClientId:  source.ClientId,
+			Instances: source.Instances,
+			PageSize:  req.PageSize + 1,
+			Cursor:    req.Cursor.sourceCursor(source),
+			TimeRange: req.TimeRange,
+		})
+		if err != nil {
+			return MergedLogPage{}, err
+		}
+		logs = append(logs, sourcePage.Logs...)
+	}
+
+	logs, hasMore := mergeLogs(logs, req.PageSize, false)
+	page := MergedLogPage{
+		Logs:       logs,
+		IsLastPage: req.Cursor == nil && req.To == nil,
+		TimeRange:  req.TimeRange,
+	}
+	if hasMore {
+		page.BackwardCursor = newMergedCursor(logs[len(logs)-1], true)
+	}
+	return page, nil
+}
+
+// MergedSearchRequest is a SearchRequest over several sources.
+type MergedSearchRequest struct {
+	Sources  []LogSource
+	PageSize int64
+	Cursor   *MergedCursor
+	TimeRange
+	Query string
+	Mode  SearchMode
+	// CountLimit is how many matches are counted at most, 0 doesn't count
+	CountLimit int64
+}
+
+// MergedSearchPage is a page of matches of several sources, newest first.
+type MergedSearchPage struct {
+	Logs           []types.StoredLog
+	ForwardCursor  *MergedCursor
+	BackwardCursor *MergedCursor
+	Count          int64
+	CountLimited   bool
+}
+
+// SearchSources searches every source for a page of matches and merges them
+// by time. A *logquery.SyntaxError is returned for invalid queries.
+func SearchSources(ctx context.Context, service LogService, req MergedSearchRequest) (MergedSearchPage, error) {
+	forward := req.Cursor != nil && !req.Cursor.IsBackward
+
+	page := MergedSearchPage{}
+	logs := []types.StoredLog{}
+	hasMore := false
+	for _, source := range req.Sources {
+		sourcePage, err := service.SearchLogs(ctx, SearchRequest{
+			LogPageRequest: LogPageRequest{
+				ProjectId: source.ProjectId,
+				ClientId:  source.ClientId,
+				Instances: source.Instances,
+				PageSize:  req.PageSize,
+				Cursor:    req.Cursor.sourceCursor(source),
+				TimeRange: req.TimeRange,
+			},
+			Query:      req.Query,
+			Mode:       req.Mode,
+			CountLimit: req.CountLimit,
+		})
+		if err != nil {
+			return MergedSearchPage{}, err
+		}
+
+		logs = append(logs, sourcePage.Logs...)
+		if forward {
+			hasMore = hasMore || sourcePage.ForwardCursor != nil
+		} else {
+			hasMore = hasMore || sourcePage.BackwardCursor != nil
+		}
+		page.Count += sourcePage.Count
+		page.CountLimited = page.CountLimited || sourcePage.CountLimited
+	}
+
+	if req.CountLimit > 0 && page.Count > req.CountLimit {
+		page.Count = req.CountLimit
+		page.CountLimited = true
+	}
+
+	logs, cut := mergeLogs(logs, req.PageSize, forward)
+	hasMore = hasMore || cut
+	page.Logs = logs
+	if len(logs) == 0 {
+		return page, nil
+	}
+
+	if (forward && hasMore) || (req.Cursor != nil && req.Cursor.IsBackward) {
+		page.ForwardCursor = newMergedCursor(logs[0], false)
+	}
+	if (!forward && hasMore) || forward {
+		page.BackwardCursor = newMergedCursor(logs[len(logs)-1], true)
+	}
+	return page, nil
+}
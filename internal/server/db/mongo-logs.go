@@ -2,12 +2,16 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"time"
 
 	"log/slog"
 
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
 	"github.com/markojerkic/svarog/internal/server/types"
 	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
 	"go.mongodb.org/mongo-driver/bson"
@@ -22,11 +26,42 @@ type LastCursor struct {
 	IsBackward     bool
 }
 
+// TimeRange bounds the timestamps of the lines of a page or search. Both ends
+// are optional and inclusive.
+type TimeRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+func (r TimeRange) IsSet() bool {
+	return r.From != nil || r.To != nil
+}
+
+// SearchMode is how words of a search match the messages.
+type SearchMode string
+
+const (
+	// SearchWords looks words up in the full text index, they match whole words
+	SearchWords SearchMode = ""
+	// SearchSubstring looks words and regular expressions up in the trigram
+	// index, words match anywhere in the message
+	SearchSubstring SearchMode = "substring"
+)
+
+func (m SearchMode) IsValid() bool {
+	return m == SearchWords || m == SearchSubstring
+}
+
 type LogPage struct {
 	Logs           []types.StoredLog
 	ForwardCursor  *LastCursor
 	BackwardCursor *LastCursor
 	IsLastPage     bool
+	// TimeRange and Search the page was loaded with, kept when loading the
+	// next page
+	TimeRange  TimeRange
+	Search     string
+	SearchMode SearchMode
 }
 
 func (l *LogPage) ToPath(projectId, clientId string, instanceId *string, cursor *LastCursor, direction string) string {
@@ -42,6 +77,18 @@ func (l *LogPage) ToPath(projectId, clientId string, instanceId *string, cursor
 	if instanceId != nil {
 		query.Set("instance", *instanceId)
 	}
+	if l.TimeRange.From != nil {
+		query.Set("from", fmt.Sprintf("%d", l.TimeRange.From.UnixMilli()))
+	}
+	if l.TimeRange.To != nil {
+		query.Set("to", fmt.Sprintf("%d", l.TimeRange.To.UnixMilli()))
+	}
+	if l.Search != "" {
+		query.Set("search", l.Search)
+	}
+	if l.SearchMode != SearchWords {
+		query.Set("mode", string(l.SearchMode))
+	}
 	u.RawQuery = query.Encode()
 	return u.String()
 }
@@ -53,19 +100,44 @@ type LogPageRequest struct {
 	PageSize  int64
 	LogLineId *string
 	Cursor    *LastCursor
+	TimeRange
+}
+
+// SearchRequest is a page of lines matching Query. Cursor, PageSize and
+// TimeRange of the embedded request apply, LogLineId doesn't.
+type SearchRequest struct {
+	LogPageRequest
+	Query string
+	Mode  SearchMode
+	// CountLimit is how many matches are counted at most, 0 doesn't count
+	CountLimit int64
+}
+
+// SearchPage is a page of matching lines, newest first. The forward cursor
+// loads newer matches and the backward cursor older ones.
+type SearchPage struct {
+	LogPage
+	// Count is the number of matching lines, CountLimited is set when there
+	// are more than CountLimit and Count stopped there
+	Count        int64
+	CountLimited bool
 }
 
 type LogService interface {
 	SaveLogs(ctx context.Context, logs []types.StoredLog) error
 	GetLogs(ctx context.Context, req LogPageRequest) (LogPage, error)
 	GetInstances(ctx context.Context, projectId string, clientId string) ([]string, error)
-	SearchLogs(ctx context.Context, query string, projectId string, clientId string, instances *[]string, pageSize int64, lastCursor *LastCursor) ([]types.StoredLog, error)
+	SearchLogs(ctx context.Context, req SearchRequest) (SearchPage, error)
 	DeleteLogBeforeTimestamp(ctx context.Context, timestamp time.Time) error
 }
 
 type MongoLogService struct {
 	logCollection *mongo.Collection
+	migrations    *mongo.Collection
 	wsLogRenderer *websocket.WsLogLineRenderer
+
+	stopBackfill context.CancelFunc
+	backfillDone chan struct{}
 }
 
 var _ LogService = &MongoLogService{}
@@ -106,19 +178,41 @@ func (self *MongoLogService) DeleteLogBeforeTimestamp(ctx context.Context, times
 // GetLogs implements LogRepository.
 func (self *MongoLogService) GetLogs(ctx context.Context, req LogPageRequest) (LogPage, error) {
 	filter, projection := createFilter(self.logCollection, req)
+	if req.Cursor != nil && !req.Cursor.IsBackward {
+		// One more line tells whether there are newer lines
+		projection.SetLimit(req.PageSize + 1)
+		projection.SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "sequence_number", Value: 1}})
+	}
 
 	logs, err := self.getAndMapLogs(ctx, filter, projection)
 	if err != nil {
 		return LogPage{}, err
 	}
 
+	return newLogPage(logs, req), nil
+}
+
+// newLogPage sets the cursors of a page of logs sorted from newest to oldest.
+// Lines after a forward cursor are sorted from oldest to newest, with one
+// line more than the page size when there are newer lines.
+func newLogPage(logs []types.StoredLog, req LogPageRequest) LogPage {
+	forward := req.Cursor != nil && !req.Cursor.IsBackward
+	hasNewer := forward && int64(len(logs)) > req.PageSize
+	if hasNewer {
+		logs = logs[:req.PageSize]
+	}
+	if forward {
+		slices.Reverse(logs)
+	}
+
 	if len(logs) == 0 {
 		return LogPage{
 			Logs:           logs,
 			ForwardCursor:  nil,
 			BackwardCursor: nil,
-			IsLastPage:     true,
-		}, nil
+			IsLastPage:     req.To == nil,
+			TimeRange:      req.TimeRange,
+		}
 	}
 
 	// BackwardCursor: for scrolling up (older logs)
@@ -133,8 +227,7 @@ func (self *MongoLogService) GetLogs(ctx context.Context, req LogPageRequest) (L
 	}
 
 	var forwardCursor *LastCursor
-	shouldHaveForwardCursor := req.Cursor != nil && !req.Cursor.IsBackward
-	if shouldHaveForwardCursor {
+	if hasNewer {
 		forwardCursor = &LastCursor{
 			Timestamp:      logs[0].Timestamp,
 			SequenceNumber: logs[0].SequenceNumber,
@@ -142,49 +235,157 @@ func (self *MongoLogService) GetLogs(ctx context.Context, req LogPageRequest) (L
 		}
 	}
 
-	isLastPage := forwardCursor == nil && req.Cursor == nil
+	// Pages ending before now don't get live lines, scrolling forward gets
+	// them once it reaches the newest line
+	isLastPage := (req.Cursor == nil || (forward && !hasNewer)) && req.To == nil
 
 	return LogPage{
 		Logs:           logs,
 		ForwardCursor:  forwardCursor,
 		BackwardCursor: backwardCursor,
 		IsLastPage:     isLastPage,
-	}, nil
+		TimeRange:      req.TimeRange,
+	}
 }
 
-func (self *MongoLogService) SearchLogs(ctx context.Context, query string, projectId string, clientId string, instances *[]string, pageSize int64, lastCursor *LastCursor) ([]types.StoredLog, error) {
-	slog.Debug("Getting logs for client", "projectId", projectId, "clientId", clientId)
+// searchTimeout bounds the lines a search scans. Queries the indexes narrow
+// down poorly, like substrings all lines contain, stop instead of scanning
+// the whole collection.
+const searchTimeout = 10 * time.Second
+
+// ErrSearchTimeout is returned in place of the matches of a search that ran
+// for longer than searchTimeout.
+var ErrSearchTimeout = errors.New("search took too long, narrow it down with a time range or more specific words")
+
+// SearchLogs implements LogService. The query is parsed with logquery, a
+// *logquery.SyntaxError is returned for invalid queries and ErrSearchTimeout
+// for searches that take longer than searchTimeout.
+func (self *MongoLogService) SearchLogs(ctx context.Context, req SearchRequest) (SearchPage, error) {
+	slog.Debug("Getting logs for client", "projectId", req.ProjectId, "clientId", req.ClientId)
+	start := time.Now()
+	defer func() {
+		metrics.SearchDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	parsedQuery, err := logquery.Parse(req.Query)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	if parsedQuery.IsEmpty() {
+		return newSearchPage([]types.StoredLog{}, req), nil
+	}
+	queryFilter, err := createQueryFilter(parsedQuery, req.Mode)
+	if err != nil {
+		return SearchPage{}, err
+	}
 
-	filter, projection := createFilter(self.logCollection, LogPageRequest{
-		ProjectId: projectId,
-		ClientId:  clientId,
-		Instances: instances,
-		PageSize:  pageSize,
-		LogLineId: nil,
-		Cursor:    lastCursor,
-	})
+	pageRequest := req.LogPageRequest
+	pageRequest.LogLineId = nil
+	filter, projection := createFilter(self.logCollection, pageRequest)
+	filter = append(filter, queryFilter...)
+
+	// One more line tells whether there are more matches
+	projection.SetLimit(req.PageSize + 1).SetMaxTime(searchTimeout)
+	if req.Cursor != nil && !req.Cursor.IsBackward {
+		projection.SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "sequence_number", Value: 1}})
+	}
+
+	logs, err := self.getAndMapLogs(ctx, filter, projection)
+	if mongo.IsTimeout(err) && ctx.Err() == nil {
+		return SearchPage{}, ErrSearchTimeout
+	}
+	if err != nil {
+		return SearchPage{}, err
+	}
+	page := newSearchPage(logs, req)
+
+	if req.CountLimit > 0 {
+		pageRequest.Cursor = nil
+		countFilter, _ := createFilter(self.logCollection, pageRequest)
+		countFilter = append(countFilter, queryFilter...)
+
+		count, err := self.logCollection.CountDocuments(ctx, countFilter, options.Count().
+			SetLimit(req.CountLimit+1).
+			SetMaxTime(searchTimeout))
+		if mongo.IsTimeout(err) && ctx.Err() == nil {
+			return SearchPage{}, ErrSearchTimeout
+		}
+		if err != nil {
+			return SearchPage{}, err
+		}
+		page.Count = min(count, req.CountLimit)
+		page.CountLimited = count > req.CountLimit
+	}
+
+	return page, nil
+}
+
+// newSearchPage sets the cursors of a page of matches. logs has one line
+// more than the page size when there are more matches, lines after a forward
+// cursor are sorted from oldest to newest.
+func newSearchPage(logs []types.StoredLog, req SearchRequest) SearchPage {
+	forward := req.Cursor != nil && !req.Cursor.IsBackward
+	hasMore := int64(len(logs)) > req.PageSize
+	if hasMore {
+		logs = logs[:req.PageSize]
+	}
+	if forward {
+		slices.Reverse(logs)
+	}
 
-	filter = append(filter, bson.E{Key: "$text", Value: bson.D{{Key: "$search", Value: query}}})
+	page := SearchPage{LogPage: LogPage{
+		Logs:       logs,
+		TimeRange:  req.TimeRange,
+		Search:     req.Query,
+		SearchMode: req.Mode,
+	}}
+	if len(logs) == 0 {
+		return page
+	}
 
-	return self.getAndMapLogs(ctx, filter, projection)
+	// The line of the cursor is on the other side of the page
+	if (forward && hasMore) || (req.Cursor != nil && req.Cursor.IsBackward) {
+		newest := logs[0]
+		page.ForwardCursor = &LastCursor{
+			Timestamp:      newest.Timestamp,
+			SequenceNumber: newest.SequenceNumber,
+			IsBackward:     false,
+		}
+	}
+	if (!forward && hasMore) || forward {
+		oldest := logs[len(logs)-1]
+		page.BackwardCursor = &LastCursor{
+			Timestamp:      oldest.Timestamp,
+			SequenceNumber: oldest.SequenceNumber,
+			IsBackward:     true,
+		}
+	}
+	return page
 }
 
 func (self *MongoLogService) SaveLogs(ctx context.Context, logs []types.StoredLog) error {
 	saveableLogs := make([]any, len(logs))
 	for i, log := range logs {
-		saveableLogs[i] = log
+		saveableLogs[i] = mongoStoredLog{StoredLog: log, Trigrams: logquery.Trigrams(log.LogLine)}
 	}
 	insertedLines, err := self.logCollection.InsertMany(
 		ctx,
 		saveableLogs,
 		options.InsertMany().SetOrdered(false),
 	)
+	duplicates, err := duplicateIndexes(err)
 	if err != nil {
 		slog.Error("Error saving logs", "error", err)
 		return err
 	}
+	if len(duplicates) > 0 {
+		slog.Debug("Skipped already stored log lines", "count", len(duplicates))
+	}
 
 	for i := range logs {
+		if duplicates[i] {
+			continue
+		}
 		logs[i].ID = insertedLines.InsertedIDs[i].(primitive.ObjectID)
 		self.wsLogRenderer.Render(ctx, logs[i])
 	}
@@ -192,19 +393,71 @@ func (self *MongoLogService) SaveLogs(ctx context.Context, logs []types.StoredLo
 	return nil
 }
 
+// duplicateIndexes returns the indexes of lines rejected because their stream
+// sequence is already stored. Any other write error is returned as is.
+func duplicateIndexes(err error) (map[int]bool, error) {
+	if err == nil {
+		return nil, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+
+	duplicates := make(map[int]bool, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return nil, err
+		}
+		duplicates[writeErr.Index] = true
+	}
+
+	return duplicates, nil
+}
+
+// mongoStoredLog is the stored document of a line, with the trigrams of its
+// message for substring search.
+type mongoStoredLog struct {
+	types.StoredLog `bson:",inline"`
+	// Trigrams is empty rather than missing for short lines, lines saved
+	// without it are backfilled
+	Trigrams []string `bson:"trigrams"`
+}
+
 func NewLogService(db *mongo.Database, wsLogRenderer *websocket.WsLogLineRenderer) *MongoLogService {
 	collection := db.Collection("log_lines")
 
+	backfillCtx, stopBackfill := context.WithCancel(context.Background())
 	repo := &MongoLogService{
 		logCollection: collection,
+		migrations:    db.Collection("migrations"),
 		wsLogRenderer: wsLogRenderer,
+		stopBackfill:  stopBackfill,
+		backfillDone:  make(chan struct{}),
 	}
 
 	repo.createIndexes()
+	go func() {
+		defer close(repo.backfillDone)
+		repo.backfillTrigramsOnce(backfillCtx)
+	}()
 
 	return repo
 }
 
+// Close stops the trigram backfill, called before the MongoDB client is
+// disconnected. The backfill continues where it stopped on the next start.
+func (self *MongoLogService) Close(ctx context.Context) error {
+	self.stopBackfill()
+	select {
+	case <-self.backfillDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func createFilter(collection *mongo.Collection, req LogPageRequest) (bson.D, *options.FindOptions) {
 	sortDirection := -1
 
@@ -268,10 +521,22 @@ func createFilter(collection *mongo.Collection, req LogPageRequest) (bson.D, *op
 		})
 	}
 
+	if req.TimeRange.IsSet() {
+		bounds := bson.D{}
+		if req.From != nil {
+			bounds = append(bounds, bson.E{Key: "$gte", Value: primitive.NewDateTimeFromTime(*req.From)})
+		}
+		if req.To != nil {
+			bounds = append(bounds, bson.E{Key: "$lte", Value: primitive.NewDateTimeFromTime(*req.To)})
+		}
+		filter = append(filter, bson.E{Key: "timestamp", Value: bounds})
+	}
+
 	return filter, projection
 }
 
 func (self *MongoLogService) getAndMapLogs(ctx context.Context, filter bson.D, projection *options.FindOptions) ([]types.StoredLog, error) {
+	projection.SetProjection(bson.D{{Key: "trigrams", Value: 0}})
 	cursor, err := self.logCollection.Find(ctx, filter, projection)
 	if err != nil {
 		slog.Error("Error getting logs", "error", err)
@@ -367,6 +632,23 @@ func (self *MongoLogService) createIndexes() {
 				{Key: "client.instance_id", Value: 1},
 			},
 		},
+		{
+			Keys: bson.D{
+				{Key: "client.project_id", Value: 1},
+				{Key: "client.client_id", Value: 1},
+				{Key: "trigrams", Value: 1},
+			},
+		},
+		{
+			Keys: bson.D{
+				{Key: "client.project_id", Value: 1},
+				{Key: "stream", Value: 1},
+				{Key: "stream_seq", Value: 1},
+			},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "stream_seq", Value: bson.D{{Key: "$gt", Value: 0}}}}),
+		},
 	})
 	if err != nil {
 		panic(fmt.Sprintf("Error creating indexes: %v", err))
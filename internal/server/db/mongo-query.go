@@ -0,0 +1,138 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var mongoQueryFields = map[logquery.Field]string{
+	logquery.FieldMessage:  "log_line",
+	logquery.FieldLevel:    "level",
+	logquery.FieldInstance: "client.instance_id",
+	logquery.FieldClient:   "client.client_id",
+	logquery.FieldTrace:    "trace_id",
+	logquery.FieldSpan:     "span_id",
+}
+
+func mongoQueryField(field logquery.Field) string {
+	if key, ok := field.Custom(); ok {
+		return "fields." + key
+	}
+	return mongoQueryFields[field]
+}
+
+// createQueryFilter compiles a search query. The first word or phrase every
+// line must contain is looked up in the text index, or the trigrams of the
+// query in the trigram index in substring mode, and the rest of the query
+// filters the lines found. Every word and phrase matches whole words, like in
+// the SQLite full text index.
+func createQueryFilter(query *logquery.Query, mode SearchMode) (bson.D, error) {
+	if mode == SearchSubstring {
+		trigrams, err := query.SubstringTrigrams()
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{Key: "trigrams", Value: bson.D{{Key: "$all", Value: trigrams}}},
+			{Key: "$and", Value: bson.A{mongoNodeFilter(query.Root)}},
+		}, nil
+	}
+
+	terms, rest := query.TextTerms()
+
+	filter := bson.D{}
+	conditions := bson.A{}
+	for i, term := range terms {
+		if i == 0 {
+			phrase := `"` + strings.ReplaceAll(term, `"`, " ") + `"`
+			filter = append(filter, bson.E{Key: "$text", Value: bson.D{{Key: "$search", Value: phrase}}})
+		}
+		// The text index also matches other forms of the word, like "timeouts"
+		conditions = append(conditions, bson.D{{Key: "log_line", Value: bson.D{
+			{Key: "$regex", Value: wholeWordPattern(term)},
+			{Key: "$options", Value: "i"},
+		}}})
+	}
+	if rest != nil {
+		conditions = append(conditions, mongoNodeFilter(rest))
+	}
+
+	if len(conditions) > 0 {
+		// Wrapped in $and, the page filter can already have an $or
+		filter = append(filter, bson.E{Key: "$and", Value: conditions})
+	}
+	return filter, nil
+}
+
+// wholeWordPattern matches term when it isn't part of a longer word.
+func wholeWordPattern(term string) string {
+	pattern := regexp.QuoteMeta(term)
+	first, _ := utf8.DecodeRuneInString(term)
+	if isWordRune(first) {
+		pattern = `(?:^|[^\p{L}\p{N}])` + pattern
+	}
+	last, _ := utf8.DecodeLastRuneInString(term)
+	if isWordRune(last) {
+		pattern += `(?:$|[^\p{L}\p{N}])`
+	}
+	return pattern
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+func mongoNodeFilter(node logquery.Node) bson.D {
+	switch node := node.(type) {
+	case logquery.And:
+		return bson.D{{Key: "$and", Value: mongoNodeFilters(node.Terms)}}
+	case logquery.Or:
+		return bson.D{{Key: "$or", Value: mongoNodeFilters(node.Terms)}}
+	case logquery.Not:
+		return bson.D{{Key: "$nor", Value: bson.A{mongoNodeFilter(node.Term)}}}
+	case logquery.Text:
+		return bson.D{{Key: "log_line", Value: bson.D{
+			{Key: "$regex", Value: regexp.QuoteMeta(node.Value)},
+			{Key: "$options", Value: "i"},
+		}}}
+	case logquery.Regex:
+		return bson.D{{Key: mongoQueryField(node.Field), Value: bson.D{{Key: "$regex", Value: node.Pattern}}}}
+	case logquery.Compare:
+		return mongoCompareFilter(node)
+	}
+	return bson.D{}
+}
+
+func mongoNodeFilters(nodes []logquery.Node) bson.A {
+	filters := make(bson.A, len(nodes))
+	for i, node := range nodes {
+		filters[i] = mongoNodeFilter(node)
+	}
+	return filters
+}
+
+var mongoOperators = map[logquery.Operator]string{
+	logquery.OpGreater:      "$gt",
+	logquery.OpGreaterEqual: "$gte",
+	logquery.OpLess:         "$lt",
+	logquery.OpLessEqual:    "$lte",
+}
+
+func mongoCompareFilter(node logquery.Compare) bson.D {
+	key := mongoQueryField(node.Field)
+	_, custom := node.Field.Custom()
+
+	if operator, ok := mongoOperators[node.Op]; ok {
+		return bson.D{{Key: key, Value: bson.D{{Key: operator, Value: *node.Number}}}}
+	}
+	if custom && node.Number != nil {
+		// Extracted fields can hold the number or its text
+		return bson.D{{Key: key, Value: bson.D{{Key: "$in", Value: bson.A{node.Value, *node.Number}}}}}
+	}
+	return bson.D{{Key: key, Value: node.Value}}
+}
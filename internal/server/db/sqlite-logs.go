@@ -0,0 +1,488 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
+	"github.com/markojerkic/svarog/internal/server/types"
+	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SqliteLogService stores log lines in an embedded SQLite database, for small
+// deployments that don't want to run MongoDB for logs. Messages are indexed
+// with FTS5 when the driver is built with the sqlite_fts5 tag, and with FTS4
+// otherwise.
+type SqliteLogService struct {
+	db            *sql.DB
+	wsLogRenderer *websocket.WsLogLineRenderer
+
+	stopBackfill context.CancelFunc
+	backfillDone chan struct{}
+}
+
+var _ LogService = &SqliteLogService{}
+
+const sqliteLogColumns = "id, project_id, client_id, instance_id, timestamp, sequence_number, log_line, level, fields, trace_id, span_id, stream, stream_seq"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS log_lines (
+	rowid           INTEGER PRIMARY KEY,
+	id              TEXT    NOT NULL UNIQUE,
+	project_id      TEXT    NOT NULL,
+	client_id       TEXT    NOT NULL,
+	instance_id     TEXT    NOT NULL,
+	timestamp       INTEGER NOT NULL,
+	sequence_number INTEGER NOT NULL,
+	log_line        TEXT    NOT NULL,
+	level           TEXT    NOT NULL DEFAULT '',
+	fields          TEXT,
+	trace_id        TEXT    NOT NULL DEFAULT '',
+	span_id         TEXT    NOT NULL DEFAULT '',
+	stream          TEXT    NOT NULL DEFAULT '',
+	stream_seq      INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS log_lines_client_time ON log_lines (project_id, client_id, timestamp DESC, sequence_number DESC);
+CREATE INDEX IF NOT EXISTS log_lines_client_instance ON log_lines (project_id, client_id, instance_id);
+CREATE INDEX IF NOT EXISTS log_lines_time ON log_lines (timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS log_lines_stream_seq ON log_lines (project_id, stream, stream_seq) WHERE stream_seq > 0;
+CREATE TABLE IF NOT EXISTS log_line_trigrams (
+	trigram TEXT    NOT NULL,
+	line    INTEGER NOT NULL,
+	PRIMARY KEY (trigram, line)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS migrations (
+	name    TEXT    PRIMARY KEY,
+	done_at INTEGER NOT NULL
+);
+`
+
+// NewSqliteLogService opens, and creates when missing, the database at path.
+func NewSqliteLogService(path string, wsLogRenderer *websocket.WsLogLineRenderer) (*SqliteLogService, error) {
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating SQLite schema: %w", err)
+	}
+	if err := createFullTextIndex(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	backfillCtx, stopBackfill := context.WithCancel(context.Background())
+	service := &SqliteLogService{
+		db:            db,
+		wsLogRenderer: wsLogRenderer,
+		stopBackfill:  stopBackfill,
+		backfillDone:  make(chan struct{}),
+	}
+	go func() {
+		defer close(service.backfillDone)
+		service.backfillTrigramsOnce(backfillCtx)
+	}()
+
+	return service, nil
+}
+
+// createFullTextIndex creates the message index. It stores its own copy of the
+// messages, so lines are indexed and deleted the same way with FTS4 and FTS5.
+func createFullTextIndex(db *sql.DB) error {
+	_, err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS log_lines_fts USING fts5(log_line)")
+	if err == nil {
+		return nil
+	}
+	if !strings.Contains(err.Error(), "no such module: fts5") {
+		return fmt.Errorf("error creating full text index: %w", err)
+	}
+
+	slog.Warn("SQLite was built without FTS5, using FTS4 for search")
+	if _, err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS log_lines_fts USING fts4(log_line, tokenize=unicode61)"); err != nil {
+		return fmt.Errorf("error creating full text index: %w", err)
+	}
+	return nil
+}
+
+// Ping checks the database can be queried, for the health checks.
+func (self *SqliteLogService) Ping(ctx context.Context) error {
+	return self.db.PingContext(ctx)
+}
+
+// Close closes the database, called after the last lines were saved.
+func (self *SqliteLogService) Close(ctx context.Context) error {
+	self.stopBackfill()
+	select {
+	case <-self.backfillDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return self.db.Close()
+}
+
+// SaveLogs implements LogService.
+func (self *SqliteLogService) SaveLogs(ctx context.Context, logs []types.StoredLog) error {
+	tx, err := self.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertLine, err := tx.PrepareContext(ctx, "INSERT OR IGNORE INTO log_lines ("+sqliteLogColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertLine.Close()
+	insertText, err := tx.PrepareContext(ctx, "INSERT INTO log_lines_fts (rowid, log_line) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertText.Close()
+	insertTrigram, err := tx.PrepareContext(ctx, "INSERT OR IGNORE INTO log_line_trigrams (trigram, line) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertTrigram.Close()
+
+	saved := make([]bool, len(logs))
+	duplicates := 0
+	for i := range logs {
+		if logs[i].ID.IsZero() {
+			logs[i].ID = primitive.NewObjectID()
+		}
+
+		var fields sql.NullString
+		if len(logs[i].Fields) > 0 {
+			data, err := json.Marshal(logs[i].Fields)
+			if err != nil {
+				return err
+			}
+			fields = sql.NullString{String: string(data), Valid: true}
+		}
+
+		result, err := insertLine.ExecContext(ctx,
+			logs[i].ID.Hex(),
+			logs[i].Client.ProjectId,
+			logs[i].Client.ClientId,
+			logs[i].Client.InstanceId,
+			logs[i].Timestamp.UnixMilli(),
+			logs[i].SequenceNumber,
+			logs[i].LogLine,
+			logs[i].Level,
+			fields,
+			logs[i].TraceId,
+			logs[i].SpanId,
+			logs[i].Stream,
+			int64(logs[i].StreamSequence),
+		)
+		if err != nil {
+			slog.Error("Error saving logs", "error", err)
+			return err
+		}
+
+		// Ignored when the stream sequence is already stored
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			duplicates++
+			continue
+		}
+		rowId, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := insertText.ExecContext(ctx, rowId, logs[i].LogLine); err != nil {
+			return err
+		}
+		for _, trigram := range logquery.Trigrams(logs[i].LogLine) {
+			if _, err := insertTrigram.ExecContext(ctx, trigram, rowId); err != nil {
+				return err
+			}
+		}
+		saved[i] = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error saving logs", "error", err)
+		return err
+	}
+	if duplicates > 0 {
+		slog.Debug("Skipped already stored log lines", "count", duplicates)
+	}
+
+	for i := range logs {
+		if saved[i] {
+			self.wsLogRenderer.Render(ctx, logs[i])
+		}
+	}
+
+	return nil
+}
+
+// GetLogs implements LogService.
+func (self *SqliteLogService) GetLogs(ctx context.Context, req LogPageRequest) (LogPage, error) {
+	where, args := self.createFilter(ctx, req)
+
+	// One more line tells whether there are newer lines
+	limit := req.PageSize
+	ascending := req.Cursor != nil && !req.Cursor.IsBackward
+	if ascending {
+		limit++
+	}
+	logs, err := self.queryLogs(ctx, where, args, limit, ascending)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	return newLogPage(logs, req), nil
+}
+
+// SearchLogs implements LogService. Searches are stopped after searchTimeout.
+func (self *SqliteLogService) SearchLogs(ctx context.Context, req SearchRequest) (SearchPage, error) {
+	searchCtx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+
+	page, err := self.searchLogs(searchCtx, req)
+	if err != nil && searchCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return SearchPage{}, ErrSearchTimeout
+	}
+	return page, err
+}
+
+func (self *SqliteLogService) searchLogs(ctx context.Context, req SearchRequest) (SearchPage, error) {
+	slog.Debug("Getting logs for client", "projectId", req.ProjectId, "clientId", req.ClientId)
+	start := time.Now()
+	defer func() {
+		metrics.SearchDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	parsedQuery, err := logquery.Parse(req.Query)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	if parsedQuery.IsEmpty() {
+		return newSearchPage([]types.StoredLog{}, req), nil
+	}
+	queryWhere, queryArgs, err := self.createQueryFilter(parsedQuery, req.Mode)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	pageRequest := req.LogPageRequest
+	pageRequest.LogLineId = nil
+	where, args := self.createFilter(ctx, pageRequest)
+	where = append(where, queryWhere...)
+	args = append(args, queryArgs...)
+
+	// One more line tells whether there are more matches
+	ascending := req.Cursor != nil && !req.Cursor.IsBackward
+	logs, err := self.queryLogs(ctx, where, args, req.PageSize+1, ascending)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	page := newSearchPage(logs, req)
+
+	if req.CountLimit > 0 {
+		pageRequest.Cursor = nil
+		countWhere, countArgs := self.createFilter(ctx, pageRequest)
+		countWhere = append(countWhere, queryWhere...)
+		countArgs = append(append(countArgs, queryArgs...), req.CountLimit+1)
+
+		var count int64
+		err := self.db.QueryRowContext(ctx,
+			"SELECT count(*) FROM (SELECT 1 FROM log_lines WHERE "+strings.Join(countWhere, " AND ")+" LIMIT ?)",
+			countArgs...,
+		).Scan(&count)
+		if err != nil {
+			return SearchPage{}, err
+		}
+		page.Count = min(count, req.CountLimit)
+		page.CountLimited = count > req.CountLimit
+	}
+
+	return page, nil
+}
+
+// GetInstances implements LogService.
+func (self *SqliteLogService) GetInstances(ctx context.Context, projectId string, clientId string) ([]string, error) {
+	rows, err := self.db.QueryContext(ctx, "SELECT DISTINCT instance_id FROM log_lines WHERE project_id = ? AND client_id = ?", projectId, clientId)
+	if err != nil {
+		return []string{}, err
+	}
+	defer rows.Close()
+
+	instances := []string{}
+	for rows.Next() {
+		var instance string
+		if err := rows.Scan(&instance); err != nil {
+			return []string{}, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, rows.Err()
+}
+
+// DeleteLogBeforeTimestamp implements LogService.
+func (self *SqliteLogService) DeleteLogBeforeTimestamp(ctx context.Context, timestamp time.Time) error {
+	tx, err := self.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM log_lines_fts WHERE rowid IN (SELECT rowid FROM log_lines WHERE timestamp <= ?)", timestamp.UnixMilli()); err != nil {
+		slog.Error("Error deleting logs", "error", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM log_line_trigrams WHERE line IN (SELECT rowid FROM log_lines WHERE timestamp <= ?)", timestamp.UnixMilli()); err != nil {
+		slog.Error("Error deleting logs", "error", err)
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM log_lines WHERE timestamp <= ?", timestamp.UnixMilli())
+	if err != nil {
+		slog.Error("Error deleting logs", "error", err)
+		return err
+	}
+	deleted, _ := result.RowsAffected()
+	slog.Debug("Deleting logs before timestamp", "timestamp", timestamp, "deleted", deleted)
+
+	return tx.Commit()
+}
+
+// createFilter is the SQLite version of createFilter, returning the conditions
+// of the WHERE clause and their arguments.
+func (self *SqliteLogService) createFilter(ctx context.Context, req LogPageRequest) ([]string, []any) {
+	where := []string{"project_id = ?", "client_id = ?"}
+	args := []any{req.ProjectId, req.ClientId}
+
+	if req.Cursor != nil && req.Cursor.Timestamp.UnixMilli() > 0 {
+		slog.Debug("Adding timestamp cursor", "cursor", *req.Cursor)
+
+		direction := "<"
+		if !req.Cursor.IsBackward {
+			direction = ">"
+		}
+		timestamp := req.Cursor.Timestamp.UnixMilli()
+		where = append(where, fmt.Sprintf("(timestamp %s ? OR (timestamp = ? AND sequence_number %s ?))", direction, direction))
+		args = append(args, timestamp, timestamp, req.Cursor.SequenceNumber)
+
+	} else if req.LogLineId != nil {
+		// Find page of data where logLineId is the newest line
+		slog.Debug("Adding log line id cursor", "logLineId", *req.LogLineId)
+		var timestamp int64
+		var sequenceNumber int
+		err := self.db.QueryRowContext(ctx,
+			"SELECT timestamp, sequence_number FROM log_lines WHERE id = ? AND project_id = ? AND client_id = ?",
+			*req.LogLineId, req.ProjectId, req.ClientId,
+		).Scan(&timestamp, &sequenceNumber)
+		if err != nil {
+			slog.Error("Failed to create filter for logLineId", "error", err)
+		} else {
+			where = append(where, "(timestamp < ? OR (timestamp = ? AND sequence_number <= ?))")
+			args = append(args, timestamp, timestamp, sequenceNumber)
+		}
+	}
+
+	if req.Instances != nil {
+		placeholders := make([]string, len(*req.Instances))
+		for i, instance := range *req.Instances {
+			placeholders[i] = "?"
+			args = append(args, instance)
+		}
+		where = append(where, fmt.Sprintf("instance_id IN (%s)", strings.Join(placeholders, ", ")))
+	}
+
+	if req.From != nil {
+		where = append(where, "timestamp >= ?")
+		args = append(args, req.From.UnixMilli())
+	}
+	if req.To != nil {
+		where = append(where, "timestamp <= ?")
+		args = append(args, req.To.UnixMilli())
+	}
+
+	return where, args
+}
+
+// queryLogs returns the lines from newest to oldest, or from oldest to newest
+// when ascending is set.
+func (self *SqliteLogService) queryLogs(ctx context.Context, where []string, args []any, limit int64, ascending bool) ([]types.StoredLog, error) {
+	order := " ORDER BY timestamp DESC, sequence_number DESC, rowid DESC"
+	if ascending {
+		order = " ORDER BY timestamp ASC, sequence_number ASC, rowid ASC"
+	}
+	query := "SELECT " + sqliteLogColumns + " FROM log_lines WHERE " + strings.Join(where, " AND ") + order
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := self.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.Error("Error getting logs", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []types.StoredLog{}
+	for rows.Next() {
+		log, err := scanStoredLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+func scanStoredLog(rows *sql.Rows) (types.StoredLog, error) {
+	var (
+		log            types.StoredLog
+		id             string
+		timestamp      int64
+		fields         sql.NullString
+		streamSequence int64
+	)
+	err := rows.Scan(
+		&id,
+		&log.Client.ProjectId,
+		&log.Client.ClientId,
+		&log.Client.InstanceId,
+		&timestamp,
+		&log.SequenceNumber,
+		&log.LogLine,
+		&log.Level,
+		&fields,
+		&log.TraceId,
+		&log.SpanId,
+		&log.Stream,
+		&streamSequence,
+	)
+	if err != nil {
+		return log, err
+	}
+
+	if log.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return log, err
+	}
+	log.Timestamp = time.UnixMilli(timestamp)
+	log.StreamSequence = uint64(streamSequence)
+	if fields.Valid {
+		if err := json.Unmarshal([]byte(fields.String), &log.Fields); err != nil {
+			return log, err
+		}
+	}
+
+	return log, nil
+}
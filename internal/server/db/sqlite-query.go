@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the SQLite driver with a REGEXP function for /regex/ terms
+// of search queries.
+const sqliteDriver = "sqlite3_svarog"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", sqliteRegexp, true)
+		},
+	})
+}
+
+var sqliteRegexps sync.Map
+
+// sqliteRegexp implements "value REGEXP pattern". Only text values match,
+// like MongoDB's $regex.
+func sqliteRegexp(pattern string, value any) (bool, error) {
+	text, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+
+	cached, ok := sqliteRegexps.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		cached, _ = sqliteRegexps.LoadOrStore(pattern, compiled)
+	}
+	return cached.(*regexp.Regexp).MatchString(text), nil
+}
+
+var sqliteQueryColumns = map[logquery.Field]string{
+	logquery.FieldMessage:  "log_line",
+	logquery.FieldLevel:    "level",
+	logquery.FieldInstance: "instance_id",
+	logquery.FieldClient:   "client_id",
+	logquery.FieldTrace:    "trace_id",
+	logquery.FieldSpan:     "span_id",
+}
+
+// sqliteQueryColumn returns the expression of a field and its arguments.
+func sqliteQueryColumn(field logquery.Field) (string, []any) {
+	if key, ok := field.Custom(); ok {
+		return "json_extract(fields, ?)", []any{`$."` + key + `"`}
+	}
+	return sqliteQueryColumns[field], nil
+}
+
+// createQueryFilter compiles a search query to conditions of the WHERE clause.
+// Words and phrases every line must contain are looked up in the full text
+// index, or the trigrams of the query in the trigram index in substring mode,
+// and the rest of the query filters the lines found.
+func (self *SqliteLogService) createQueryFilter(query *logquery.Query, mode SearchMode) ([]string, []any, error) {
+	if mode == SearchSubstring {
+		trigrams, err := query.SubstringTrigrams()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		placeholders := make([]string, len(trigrams))
+		args := make([]any, 0, len(trigrams)+1)
+		for i, trigram := range trigrams {
+			placeholders[i] = "?"
+			args = append(args, trigram)
+		}
+		args = append(args, len(trigrams))
+		condition, conditionArgs := sqliteNodeFilter(query.Root)
+
+		return []string{
+			fmt.Sprintf("rowid IN (SELECT line FROM log_line_trigrams WHERE trigram IN (%s) GROUP BY line HAVING count(*) = ?)", strings.Join(placeholders, ", ")),
+			condition,
+		}, append(args, conditionArgs...), nil
+	}
+
+	terms, rest := query.TextTerms()
+
+	where := []string{}
+	args := []any{}
+	if len(terms) > 0 {
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		where = append(where, "rowid IN (SELECT rowid FROM log_lines_fts WHERE log_lines_fts MATCH ?)")
+		args = append(args, strings.Join(quoted, " "))
+	}
+	if rest != nil {
+		condition, conditionArgs := sqliteNodeFilter(rest)
+		where = append(where, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	return where, args, nil
+}
+
+func sqliteNodeFilter(node logquery.Node) (string, []any) {
+	switch node := node.(type) {
+	case logquery.And:
+		return sqliteNodeFilters(node.Terms, " AND ")
+	case logquery.Or:
+		return sqliteNodeFilters(node.Terms, " OR ")
+	case logquery.Not:
+		// Missing fields don't match, so their negation does
+		condition, args := sqliteNodeFilter(node.Term)
+		return "NOT coalesce(" + condition + ", 0)", args
+	case logquery.Text:
+		return `log_line LIKE ? ESCAPE '\'`, []any{"%" + escapeLike(node.Value) + "%"}
+	case logquery.Regex:
+		column, args := sqliteQueryColumn(node.Field)
+		return column + " REGEXP ?", append(args, node.Pattern)
+	case logquery.Compare:
+		return sqliteCompareFilter(node)
+	}
+	return "1", nil
+}
+
+func sqliteNodeFilters(nodes []logquery.Node, separator string) (string, []any) {
+	conditions := make([]string, len(nodes))
+	args := []any{}
+	for i, node := range nodes {
+		condition, nodeArgs := sqliteNodeFilter(node)
+		conditions[i] = condition
+		args = append(args, nodeArgs...)
+	}
+	return "(" + strings.Join(conditions, separator) + ")", args
+}
+
+func sqliteCompareFilter(node logquery.Compare) (string, []any) {
+	column, args := sqliteQueryColumn(node.Field)
+	_, custom := node.Field.Custom()
+
+	switch node.Op {
+	case logquery.OpMatch, logquery.OpEqual:
+		if custom && node.Number != nil {
+			// Extracted fields can hold the number or its text
+			return column + " IN (?, ?)", append(args, node.Value, *node.Number)
+		}
+		return column + " = ?", append(args, node.Value)
+	default:
+		// SQLite orders all numbers before text, only numbers are compared
+		condition := fmt.Sprintf("(typeof(%s) IN ('integer', 'real') AND %s %s ?)", column, column, node.Op)
+		return condition, append(append(args, args...), *node.Number)
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trigramMigration marks the lines stored before substring search as indexed.
+const trigramMigration = "line_trigrams"
+
+const trigramBackfillBatch = 500
+
+// backfillTrigramsOnce indexes the lines stored before substring search
+// unless that was done before. Substring search misses those lines until it
+// is done.
+func (self *MongoLogService) backfillTrigramsOnce(ctx context.Context) {
+	done, err := self.migrations.CountDocuments(ctx, bson.M{"_id": trigramMigration})
+	if err != nil {
+		slog.Error("Failed to check trigram backfill", "error", err)
+		return
+	}
+	if done > 0 {
+		return
+	}
+
+	if err := self.BackfillTrigrams(ctx); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Failed to backfill trigrams", "error", err)
+		}
+		return
+	}
+	_, err = self.migrations.InsertOne(ctx, bson.M{"_id": trigramMigration, "done_at": time.Now()})
+	if err != nil {
+		slog.Error("Failed to mark trigram backfill as done", "error", err)
+	}
+}
+
+// BackfillTrigrams stores the trigrams of the lines saved without them.
+func (self *MongoLogService) BackfillTrigrams(ctx context.Context) error {
+	start := time.Now()
+	cursor, err := self.logCollection.Find(ctx,
+		bson.M{"trigrams": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"log_line": 1}).SetBatchSize(trigramBackfillBatch))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	backfilled := 0
+	updates := make([]mongo.WriteModel, 0, trigramBackfillBatch)
+	flush := func() error {
+		if len(updates) == 0 {
+			return nil
+		}
+		_, err := self.logCollection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+		backfilled += len(updates)
+		updates = updates[:0]
+		return err
+	}
+
+	for cursor.Next(ctx) {
+		var line struct {
+			ID      primitive.ObjectID `bson:"_id"`
+			LogLine string             `bson:"log_line"`
+		}
+		if err := cursor.Decode(&line); err != nil {
+			return err
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": line.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"trigrams": logquery.Trigrams(line.LogLine)}}))
+		if len(updates) == trigramBackfillBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if backfilled > 0 {
+		slog.Info("Backfilled trigrams", "lines", backfilled, "took", time.Since(start))
+	}
+	return nil
+}
+
+// backfillTrigramsOnce indexes the lines stored before substring search
+// unless that was done before. Substring search misses those lines until it
+// is done.
+func (self *SqliteLogService) backfillTrigramsOnce(ctx context.Context) {
+	var done int
+	err := self.db.QueryRowContext(ctx, "SELECT count(*) FROM migrations WHERE name = ?", trigramMigration).Scan(&done)
+	if err != nil {
+		slog.Error("Failed to check trigram backfill", "error", err)
+		return
+	}
+	if done > 0 {
+		return
+	}
+
+	if err := self.BackfillTrigrams(ctx); err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Failed to backfill trigrams", "error", err)
+		}
+		return
+	}
+	_, err = self.db.ExecContext(ctx, "INSERT OR IGNORE INTO migrations (name, done_at) VALUES (?, ?)", trigramMigration, time.Now().UnixMilli())
+	if err != nil {
+		slog.Error("Failed to mark trigram backfill as done", "error", err)
+	}
+}
+
+// BackfillTrigrams stores the trigrams of every line. Lines already indexed
+// are left as they are, so it is safe to run while lines are saved.
+func (self *SqliteLogService) BackfillTrigrams(ctx context.Context) error {
+	start := time.Now()
+	lastRowId := int64(0)
+	backfilled := 0
+	for {
+		indexed, err := self.backfillTrigramBatch(ctx, &lastRowId)
+		if err != nil {
+			return err
+		}
+		if indexed == 0 {
+			break
+		}
+		backfilled += indexed
+	}
+
+	slog.Info("Backfilled trigrams", "lines", backfilled, "took", time.Since(start))
+	return nil
+}
+
+// backfillTrigramBatch indexes the batch of lines after lastRowId in one
+// transaction, so saving lines only waits for one batch.
+func (self *SqliteLogService) backfillTrigramBatch(ctx context.Context, lastRowId *int64) (int, error) {
+	tx, err := self.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT rowid, log_line FROM log_lines WHERE rowid > ? ORDER BY rowid LIMIT ?", *lastRowId, trigramBackfillBatch)
+	if err != nil {
+		return 0, err
+	}
+	lines := map[int64]string{}
+	for rows.Next() {
+		var rowId int64
+		var line string
+		if err := rows.Scan(&rowId, &line); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		lines[rowId] = line
+		*lastRowId = max(*lastRowId, rowId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	insertTrigram, err := tx.PrepareContext(ctx, "INSERT OR IGNORE INTO log_line_trigrams (trigram, line) VALUES (?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer insertTrigram.Close()
+	for rowId, line := range lines {
+		for _, trigram := range logquery.Trigrams(line) {
+			if _, err := insertTrigram.ExecContext(ctx, trigram, rowId); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(lines), tx.Commit()
+}
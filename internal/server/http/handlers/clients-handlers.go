@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/http/htmx"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+type ClientsRouter struct {
+	projectsService projects.ProjectsService
+}
+
+func (r *ClientsRouter) getClientsPage(c echo.Context) error {
+	project, err := r.projectsService.GetProject(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		slog.Error("Error fetching project", "error", err)
+		if err.Error() == projects.ErrProjectNotFound {
+			return c.JSON(404, types.ApiError{Message: "Project not found"})
+		}
+		return c.JSON(500, types.ApiError{Message: "Error getting project"})
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(200, project)
+	}
+
+	return utils.Render(c, http.StatusOK, admin.ClientsPage(admin.ClientsPageProps{Project: project}))
+}
+
+func (r *ClientsRouter) approveClient(c echo.Context) error {
+	return r.setClientStatus(c, projects.ClientApproved)
+}
+
+func (r *ClientsRouter) rejectClient(c echo.Context) error {
+	return r.setClientStatus(c, projects.ClientRejected)
+}
+
+func (r *ClientsRouter) setClientStatus(c echo.Context, status projects.ClientStatus) error {
+	projectId := c.Param("id")
+
+	var form types.ClientActionForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+	if err := c.Validate(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	var err error
+	if status == projects.ClientApproved {
+		err = r.projectsService.ApproveClient(c.Request().Context(), projectId, form.ClientId)
+	} else {
+		err = r.projectsService.RejectClient(c.Request().Context(), projectId, form.ClientId)
+	}
+	if err != nil {
+		if err.Error() == projects.ErrProjectNotFound {
+			return c.JSON(404, types.ApiError{Message: "Project not found"})
+		}
+		slog.Error("Error updating client", "error", err, "status", status)
+		htmx.AddErrorToast(c, "Failed to update client")
+		return c.JSON(500, types.ApiError{Message: "Error updating client"})
+	}
+
+	row := admin.ClientRowProps{ProjectID: projectId, ClientID: form.ClientId, Status: status}
+	if wantsJSON(c) {
+		return c.JSON(200, row)
+	}
+
+	if status == projects.ClientApproved {
+		htmx.AddSuccessToast(c, "Client approved")
+	} else {
+		htmx.AddSuccessToast(c, "Client rejected")
+	}
+	return utils.Render(c, http.StatusOK, admin.ClientTableRow(row))
+}
+
+func (r *ClientsRouter) setClientApproval(c echo.Context) error {
+	projectId := c.Param("id")
+
+	var form types.ClientApprovalForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	if err := r.projectsService.SetRequireClientApproval(c.Request().Context(), projectId, form.Required); err != nil {
+		if err.Error() == projects.ErrProjectNotFound {
+			return c.JSON(404, types.ApiError{Message: "Project not found"})
+		}
+		slog.Error("Error updating client approval", "error", err)
+		htmx.AddErrorToast(c, "Failed to update client approval")
+		return c.JSON(500, types.ApiError{Message: "Error updating client approval"})
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(200, form)
+	}
+
+	if form.Required {
+		htmx.AddSuccessToast(c, "New clients now need approval")
+	} else {
+		htmx.AddSuccessToast(c, "New clients are registered automatically")
+	}
+	return utils.Render(c, http.StatusOK, admin.ClientApprovalToggle(projectId, form.Required))
+}
+
+// NewClientsRouter lists the clients of a project and approves or rejects
+// clients registered through project-wide credentials.
+func NewClientsRouter(projectsService projects.ProjectsService, e *echo.Group) *ClientsRouter {
+	router := &ClientsRouter{projectsService}
+
+	if router.projectsService == nil {
+		panic("No projectsService")
+	}
+
+	group := e.Group("/projects/:id/clients")
+	group.GET("", router.getClientsPage)
+	group.POST("/approve", router.approveClient)
+	group.POST("/reject", router.rejectClient)
+	group.POST("/approval", router.setClientApproval)
+
+	return router
+}
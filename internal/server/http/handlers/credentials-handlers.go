@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/server/http/htmx"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+type CredentialsRouter struct {
+	registry        serverauth.CredentialRegistry
+	revoker         *serverauth.AccountRevoker
+	projectsService projects.ProjectsService
+}
+
+func (r *CredentialsRouter) getCredentialsPage(c echo.Context) error {
+	projectId := c.Param("id")
+	project, err := r.projectsService.GetProject(c.Request().Context(), projectId)
+	if err != nil {
+		slog.Error("Error fetching project", "error", err)
+		if err.Error() == projects.ErrProjectNotFound {
+			return c.JSON(404, types.ApiError{Message: "Project not found"})
+		}
+		return c.JSON(500, types.ApiError{Message: "Error getting project"})
+	}
+
+	credentials, err := r.registry.GetCredentials(c.Request().Context(), projectId)
+	if err != nil {
+		slog.Error("Error fetching credentials", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting credentials"})
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(200, credentials)
+	}
+
+	return utils.Render(c, http.StatusOK, admin.CredentialsPage(admin.CredentialsPageProps{
+		Project:           project,
+		Credentials:       credentials,
+		RevocationEnabled: r.revoker != nil,
+	}))
+}
+
+func (r *CredentialsRouter) revokeCredential(c echo.Context) error {
+	id := c.Param("id")
+
+	var credential serverauth.IssuedCredential
+	var err error
+	if r.revoker != nil {
+		credential, err = r.revoker.Revoke(c.Request().Context(), id)
+	} else {
+		credential, err = r.registry.Revoke(c.Request().Context(), id)
+	}
+
+	if err != nil && credential.ID.IsZero() {
+		if err.Error() == serverauth.ErrCredentialNotFound {
+			return c.JSON(404, types.ApiError{Message: "Credential not found"})
+		}
+		slog.Error("Error revoking credential", "error", err)
+		htmx.AddErrorToast(c, "Failed to revoke credential")
+		return c.JSON(500, types.ApiError{Message: "Error revoking credential"})
+	}
+
+	switch {
+	case err != nil:
+		slog.Error("Error pushing revocation to NATS", "error", err)
+		htmx.AddErrorToast(c, "Credential revoked, but the NATS server could not be updated")
+	case r.revoker == nil:
+		htmx.AddSuccessToast(c, "Credential marked as revoked, it stays valid until it expires")
+	default:
+		htmx.AddSuccessToast(c, "Credential revoked")
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(200, credential)
+	}
+	return utils.Render(c, http.StatusOK, admin.CredentialTableRow(credential))
+}
+
+// NewCredentialsRouter lists and revokes issued NATS credentials. Without a
+// revoker, revocations are only recorded and the credentials keep working
+// until they expire.
+func NewCredentialsRouter(
+	registry serverauth.CredentialRegistry,
+	revoker *serverauth.AccountRevoker,
+	projectsService projects.ProjectsService,
+	e *echo.Group,
+) *CredentialsRouter {
+	router := &CredentialsRouter{registry, revoker, projectsService}
+
+	if router.registry == nil {
+		panic("No credential registry")
+	}
+
+	e.GET("/projects/:id/credentials", router.getCredentialsPage)
+	e.POST("/credentials/:id/revoke", router.revokeCredential)
+
+	return router
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	customMiddleware "github.com/markojerkic/svarog/internal/server/http/middleware"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+)
+
+// elasticVersion is the Elasticsearch version reported to shippers, they
+// pick their request format by it.
+const elasticVersion = "8.11.0"
+
+type ElasticRouter struct {
+	receiver *ingest.Receiver
+}
+
+type elasticBulkResponse struct {
+	Took   int64                    `json:"took"`
+	Errors bool                     `json:"errors"`
+	Items  []ingest.ElasticBulkItem `json:"items"`
+}
+
+// elasticError is the error body Elasticsearch clients expect.
+func elasticError(c echo.Context, status int, errorType string, reason string) error {
+	return c.JSON(status, map[string]any{
+		"error": map[string]any{
+			"type":   errorType,
+			"reason": reason,
+			"root_cause": []map[string]string{
+				{"type": errorType, "reason": reason},
+			},
+		},
+		"status": status,
+	})
+}
+
+func (r *ElasticRouter) info(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"name":         "svarog",
+		"cluster_name": "svarog",
+		"version": map[string]any{
+			"number":                              elasticVersion,
+			"build_flavor":                        "default",
+			"minimum_wire_compatibility_version":  "7.17.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+func (r *ElasticRouter) bulk(c echo.Context) error {
+	start := time.Now()
+	credential := c.Get("ingestCredential").(serverauth.IssuedCredential)
+
+	body, err := readIngestBody(c.Request())
+	if err != nil {
+		return elasticError(c, http.StatusBadRequest, "parse_exception", err.Error())
+	}
+
+	lines, items, err := ingest.ElasticBulkToLogLines(body, c.Param("index"), credential.ProjectId, credential.ClientId)
+	if err != nil {
+		return elasticError(c, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+	}
+
+	if _, err := r.receiver.Receive(c.Request().Context(), lines); err != nil {
+		slog.Error("Error receiving Elasticsearch bulk", "project", credential.ProjectId, "error", err)
+		// Shippers retry the whole request on 429, lines published before the
+		// error are stored twice
+		return elasticError(c, http.StatusTooManyRequests, "es_rejected_execution_exception", "Error receiving logs")
+	}
+
+	response := elasticBulkResponse{
+		Took:  time.Since(start).Milliseconds(),
+		Items: items,
+	}
+	for _, item := range items {
+		if item.Error != "" {
+			response.Errors = true
+			break
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// elasticProductHeader marks responses as coming from Elasticsearch, the
+// official clients refuse to talk to servers without it.
+func elasticProductHeader(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("X-Elastic-Product", "Elasticsearch")
+		return next(c)
+	}
+}
+
+// NewElasticRouter registers a minimal Elasticsearch API under /elastic, so
+// Fluent Bit, Logstash and Filebeat can ship to svarog with an ingest token.
+func NewElasticRouter(tokenService *serverauth.IngestTokenService, receiver *ingest.Receiver, e *echo.Echo) *ElasticRouter {
+	if tokenService == nil {
+		panic("No IngestTokenService")
+	}
+	if receiver == nil {
+		panic("No Receiver")
+	}
+
+	router := &ElasticRouter{receiver}
+
+	group := e.Group("/elastic", elasticProductHeader, customMiddleware.IngestTokenMiddleware(tokenService))
+	group.GET("", router.info)
+	group.HEAD("", router.info)
+	group.POST("/_bulk", router.bulk)
+	group.PUT("/_bulk", router.bulk)
+	group.POST("/:index/_bulk", router.bulk)
+	group.PUT("/:index/_bulk", router.bulk)
+
+	return router
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/health"
+)
+
+type HealthRouter struct {
+	healthService *health.HealthService
+}
+
+func (h *HealthRouter) liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": health.StatusOk})
+}
+
+func (h *HealthRouter) readiness(c echo.Context) error {
+	report := h.healthService.Ready(c.Request().Context())
+	if report.Status != health.StatusOk {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// NewHealthRouter registers the probes directly on echo so they bypass the session middleware.
+func NewHealthRouter(healthService *health.HealthService, e *echo.Echo) *HealthRouter {
+	router := &HealthRouter{healthService}
+
+	e.GET("/healthz", router.liveness)
+	e.GET("/readyz", router.readiness)
+
+	return router
+}
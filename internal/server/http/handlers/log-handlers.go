@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
 	"time"
 
 	"log/slog"
 
 	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/logquery"
 	"github.com/markojerkic/svarog/internal/server/db"
 	"github.com/markojerkic/svarog/internal/server/types"
 	"github.com/markojerkic/svarog/internal/server/ui/pages"
@@ -29,6 +33,40 @@ type LogsRouter struct {
 
 var DEFAULT_PAGE_SIZE = int64(300)
 
+// SEARCH_COUNT_LIMIT is how many matches of a search are counted
+var SEARCH_COUNT_LIMIT = int64(10000)
+
+// SearchResult is a page of matches of the search API. Newer and Older are
+// the query parameters of the next pages.
+type SearchResult struct {
+	Logs         []LogLine     `json:"logs"`
+	Count        int64         `json:"count"`
+	CountLimited bool          `json:"countLimited"`
+	Newer        *SearchCursor `json:"newer,omitempty"`
+	Older        *SearchCursor `json:"older,omitempty"`
+}
+
+type SearchCursor struct {
+	CursorTime           int64  `json:"cursorTime"`
+	CursorSequenceNumber int    `json:"cursorSequenceNumber"`
+	Direction            string `json:"direction"`
+}
+
+func newSearchCursor(cursor *db.LastCursor) *SearchCursor {
+	if cursor == nil {
+		return nil
+	}
+	direction := "forward"
+	if cursor.IsBackward {
+		direction = "backward"
+	}
+	return &SearchCursor{
+		CursorTime:           cursor.Timestamp.UnixMilli(),
+		CursorSequenceNumber: cursor.SequenceNumber,
+		Direction:            direction,
+	}
+}
+
 type LogsByClientBinding struct {
 	ProjectId            string    `param:"projectId"`
 	ClientId             string    `param:"clientId"`
@@ -37,6 +75,60 @@ type LogsByClientBinding struct {
 	Direction            *string   `query:"direction"`
 	Instances            *[]string `query:"instance"`
 	LogLineId            *string   `query:"logLine"`
+	// From and To limit the lines to a time range, At jumps to the lines
+	// logged up to an instant and scrolls on to newer lines from there
+	From types.TimeBound `query:"from"`
+	To   types.TimeBound `query:"to"`
+	At   types.TimeBound `query:"at"`
+	// Search is a logquery query, the page shows the matching lines
+	Search string        `query:"search"`
+	Mode   db.SearchMode `query:"mode"`
+	// Hit is the position of the match the view jumped to
+	Hit int `query:"hit"`
+}
+
+func (params LogsByClientBinding) timeRange() (db.TimeRange, error) {
+	return newTimeRange(params.From, params.To)
+}
+
+// cursor is the cursor of the requested page. Without one, jumping to At
+// starts at the lines logged up to the instant.
+func (params LogsByClientBinding) cursor() *db.LastCursor {
+	if params.CursorTime != nil && params.CursorSequenceNumber != nil {
+		return &db.LastCursor{
+			Timestamp:      time.UnixMilli(*params.CursorTime),
+			SequenceNumber: *params.CursorSequenceNumber,
+			IsBackward:     params.Direction == nil || *params.Direction == "backward",
+		}
+	}
+	if params.At.Valid && params.LogLineId == nil {
+		return params.atCursor(true)
+	}
+	return nil
+}
+
+// atCursor is a cursor past every line logged at the At instant, backward
+// it loads the lines up to the instant and forward the lines after it.
+func (params LogsByClientBinding) atCursor(isBackward bool) *db.LastCursor {
+	return &db.LastCursor{
+		Timestamp:      params.At.Time,
+		SequenceNumber: math.MaxInt,
+		IsBackward:     isBackward,
+	}
+}
+
+// jumpedTo reports whether the page starts at At rather than at a cursor.
+func (params LogsByClientBinding) jumpedTo() bool {
+	return params.At.Valid && params.LogLineId == nil &&
+		(params.CursorTime == nil || params.CursorSequenceNumber == nil)
+}
+
+func newTimeRange(from types.TimeBound, to types.TimeBound) (db.TimeRange, error) {
+	timeRange := db.TimeRange{From: from.Ptr(), To: to.Ptr()}
+	if timeRange.From != nil && timeRange.To != nil && timeRange.From.After(*timeRange.To) {
+		return timeRange, errors.New("from must be before to")
+	}
+	return timeRange, nil
 }
 
 func (self *LogsRouter) instancesByClientHandler(c echo.Context) error {
@@ -66,32 +158,76 @@ func (self *LogsRouter) logsByClientHandler(c echo.Context) error {
 
 	slog.Debug("Get logs by client", "params", params)
 
-	var nextCursor *db.LastCursor
-	if params.CursorTime != nil && params.CursorSequenceNumber != nil {
-		nextCursor = &db.LastCursor{
-			Timestamp:      time.UnixMilli(*params.CursorTime),
-			SequenceNumber: *params.CursorSequenceNumber,
-			IsBackward:     *params.Direction == "backward",
-		}
+	timeRange, err := params.timeRange()
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+	if !params.Mode.IsValid() {
+		return c.JSON(400, "Unknown search mode")
 	}
 
-	logPage, err := self.logService.GetLogs(c.Request().Context(), db.LogPageRequest{
-		ProjectId: params.ProjectId,
-		ClientId:  params.ClientId,
-		Instances: params.Instances,
-		PageSize:  DEFAULT_PAGE_SIZE,
-		LogLineId: params.LogLineId,
-		Cursor:    nextCursor,
-	})
+	// The first page has no cursor or jumped to At
+	firstPage := params.CursorTime == nil || params.CursorSequenceNumber == nil
+	nextCursor := params.cursor()
 
-	if err != nil {
+	ctx := c.Request().Context()
+	var logPage db.LogPage
+	var navigator *pages.SearchNavigator
+	if params.Search != "" && params.LogLineId == nil {
+		// Only the matching lines, counted on the first page
+		req := params.searchRequest(nextCursor, timeRange, DEFAULT_PAGE_SIZE)
+		if firstPage {
+			req.CountLimit = SEARCH_COUNT_LIMIT
+		}
+		var searchPage db.SearchPage
+		searchPage, err = self.logService.SearchLogs(ctx, req)
+		logPage = searchPage.LogPage
+		if err == nil && firstPage {
+			navigator = &pages.SearchNavigator{Count: searchPage.Count, CountLimited: searchPage.CountLimited}
+			if len(logPage.Logs) > 0 {
+				navigator.Older = &logPage.Logs[0]
+			}
+		}
+	} else {
+		logPage, err = self.logService.GetLogs(ctx, db.LogPageRequest{
+			ProjectId: params.ProjectId,
+			ClientId:  params.ClientId,
+			Instances: params.Instances,
+			PageSize:  DEFAULT_PAGE_SIZE,
+			LogLineId: params.LogLineId,
+			Cursor:    nextCursor,
+			TimeRange: timeRange,
+		})
+		if err == nil && params.Search != "" && firstPage {
+			navigator, err = self.searchNavigator(ctx, params, timeRange, logPage)
+		}
+	}
+	if err == nil && params.jumpedTo() {
+		// The lines logged after the instant are newer than the page
+		logPage.ForwardCursor = params.atCursor(false)
+	}
+
+	var syntaxErr *logquery.SyntaxError
+	timedOut := errors.Is(err, db.ErrSearchTimeout)
+	if err != nil && !timedOut && !errors.As(err, &syntaxErr) {
 		return err
 	}
 
 	props := pages.LogsPageProps{
-		LogPage:   logPage,
-		ClientId:  params.ClientId,
-		ProjectId: params.ProjectId,
+		LogPage:        logPage,
+		ClientId:       params.ClientId,
+		ProjectId:      params.ProjectId,
+		From:           params.From,
+		To:             params.To,
+		At:             params.At,
+		Search:         params.Search,
+		SearchMode:     params.Mode,
+		SearchError:    syntaxErr,
+		SearchTimedOut: timedOut,
+		Navigator:      navigator,
+	}
+	if params.LogLineId != nil {
+		props.HighlightId = *params.LogLineId
 	}
 	if params.Instances != nil && len(*params.Instances) > 0 {
 		instances := (*params.Instances)
@@ -99,20 +235,71 @@ func (self *LogsRouter) logsByClientHandler(c echo.Context) error {
 	}
 
 	isHx := c.Request().Header.Get("HX-Request") == "true"
-	if isHx && nextCursor != nil {
+	if isHx && !firstPage {
 		return utils.Render(c, http.StatusOK, pages.LogPageLines(props))
 	}
 
 	return utils.Render(c, http.StatusOK, pages.LogsPage(props))
 }
 
-type SearchLogsByClientBinding struct {
-	LogsByClientBinding
-	Search string `query:"search"`
+func (params LogsByClientBinding) searchRequest(cursor *db.LastCursor, timeRange db.TimeRange, pageSize int64) db.SearchRequest {
+	return db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{
+			ProjectId: params.ProjectId,
+			ClientId:  params.ClientId,
+			Instances: params.Instances,
+			PageSize:  pageSize,
+			Cursor:    cursor,
+			TimeRange: timeRange,
+		},
+		Query: params.Search,
+		Mode:  params.Mode,
+	}
+}
+
+// searchNavigator finds the matches next to the line the view jumped to,
+// which is the newest line of the page.
+func (self *LogsRouter) searchNavigator(ctx context.Context, params LogsByClientBinding, timeRange db.TimeRange, logPage db.LogPage) (*pages.SearchNavigator, error) {
+	if len(logPage.Logs) == 0 || logPage.Logs[0].ID.Hex() != *params.LogLineId {
+		return nil, nil
+	}
+	hit := logPage.Logs[0]
+
+	newerRequest := params.searchRequest(&db.LastCursor{
+		Timestamp:      hit.Timestamp,
+		SequenceNumber: hit.SequenceNumber,
+		IsBackward:     false,
+	}, timeRange, 1)
+	newerRequest.CountLimit = SEARCH_COUNT_LIMIT
+	newer, err := self.logService.SearchLogs(ctx, newerRequest)
+	if err != nil {
+		return nil, err
+	}
+	older, err := self.logService.SearchLogs(ctx, params.searchRequest(&db.LastCursor{
+		Timestamp:      hit.Timestamp,
+		SequenceNumber: hit.SequenceNumber,
+		IsBackward:     true,
+	}, timeRange, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	navigator := &pages.SearchNavigator{
+		Count:        newer.Count,
+		CountLimited: newer.CountLimited,
+		Hit:          params.Hit,
+	}
+	if len(newer.Logs) > 0 {
+		navigator.Newer = &newer.Logs[0]
+	}
+	if len(older.Logs) > 0 {
+		navigator.Older = &older.Logs[0]
+	}
+	return navigator, nil
 }
 
 func (self *LogsRouter) searchLogs(c echo.Context) error {
-	var params SearchLogsByClientBinding
+	var params LogsByClientBinding
 
 	err := c.Bind(&params)
 	if err != nil {
@@ -120,25 +307,33 @@ func (self *LogsRouter) searchLogs(c echo.Context) error {
 		return c.JSON(400, "Bad request")
 	}
 
-	var nextCursor db.LastCursor
-	if params.CursorTime != nil && params.CursorSequenceNumber != nil {
-		nextCursor = db.LastCursor{
-			Timestamp:      time.UnixMilli(*params.CursorTime),
-			SequenceNumber: *params.CursorSequenceNumber,
-			IsBackward:     *params.Direction == "backward",
-		}
+	timeRange, err := params.timeRange()
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+	if !params.Mode.IsValid() {
+		return c.JSON(400, "Unknown search mode")
 	}
 
-	slog.Debug("next", "cursor", nextCursor)
-	logs, err := self.logService.SearchLogs(c.Request().Context(), params.Search, params.ProjectId, params.ClientId, params.Instances, DEFAULT_PAGE_SIZE, &nextCursor)
+	req := params.searchRequest(params.cursor(), timeRange, DEFAULT_PAGE_SIZE)
+	req.CountLimit = SEARCH_COUNT_LIMIT
+	page, err := self.logService.SearchLogs(c.Request().Context(), req)
 
+	var syntaxErr *logquery.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return c.JSON(400, syntaxErr)
+	}
+	if errors.Is(err, db.ErrSearchTimeout) {
+		return c.JSON(504, err.Error())
+	}
 	if err != nil {
 		return err
 	}
 
-	logsLen := len(logs)
+	// Oldest line first
+	logsLen := len(page.Logs)
 	mappedLogs := make([]LogLine, logsLen)
-	for i, log := range logs {
+	for i, log := range page.Logs {
 		mappedLogs[logsLen-i-1] = LogLine{
 			log.ID.Hex(),
 			log.Timestamp.UnixMilli(),
@@ -148,7 +343,13 @@ func (self *LogsRouter) searchLogs(c echo.Context) error {
 		}
 	}
 
-	return c.JSON(200, mappedLogs)
+	return c.JSON(200, SearchResult{
+		Logs:         mappedLogs,
+		Count:        page.Count,
+		CountLimited: page.CountLimited,
+		Newer:        newSearchCursor(page.ForwardCursor),
+		Older:        newSearchCursor(page.BackwardCursor),
+	})
 }
 
 func NewLogsRouter(logService db.LogService, e *echo.Group) *LogsRouter {
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	customMiddleware "github.com/markojerkic/svarog/internal/server/http/middleware"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/internal/server/types"
+)
+
+type LokiRouter struct {
+	receiver *ingest.Receiver
+}
+
+func (r *LokiRouter) push(c echo.Context) error {
+	credential := c.Get("ingestCredential").(serverauth.IssuedCredential)
+	useJSON := strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+	body, err := readIngestBody(c.Request())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ApiError{Message: err.Error()})
+	}
+
+	request, err := ingest.DecodeLokiPush(body, useJSON, maxIngestBodySize)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ApiError{Message: err.Error()})
+	}
+
+	lines := ingest.LokiPushToLogLines(request, credential.ProjectId, credential.ClientId)
+	if _, err := r.receiver.Receive(c.Request().Context(), lines); err != nil {
+		slog.Error("Error receiving Loki push", "project", credential.ProjectId, "error", err)
+		// Shippers retry on 5xx, lines published before the error are stored twice.
+		return c.JSON(http.StatusServiceUnavailable, types.ApiError{Message: "Error receiving logs"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// NewLokiRouter registers the Loki push endpoint directly on echo, so
+// Promtail, the Grafana agent, Vector and Fluent Bit can ship logs with an
+// ingest token.
+func NewLokiRouter(tokenService *serverauth.IngestTokenService, receiver *ingest.Receiver, e *echo.Echo) *LokiRouter {
+	if tokenService == nil {
+		panic("No IngestTokenService")
+	}
+	if receiver == nil {
+		panic("No Receiver")
+	}
+
+	router := &LokiRouter{receiver}
+
+	e.POST("/loki/api/v1/push", router.push, customMiddleware.IngestTokenMiddleware(tokenService))
+
+	return router
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	customMiddleware "github.com/markojerkic/svarog/internal/server/http/middleware"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/internal/server/types"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otlpProtobufContentType = "application/x-protobuf"
+	// maxIngestBodySize limits the decompressed body of ingest requests.
+	maxIngestBodySize = 16 << 20
+)
+
+type OtlpRouter struct {
+	receiver *ingest.Receiver
+}
+
+func (r *OtlpRouter) exportLogs(c echo.Context) error {
+	credential := c.Get("ingestCredential").(serverauth.IssuedCredential)
+	useJSON := strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+	body, err := readIngestBody(c.Request())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ApiError{Message: err.Error()})
+	}
+
+	var request collogspb.ExportLogsServiceRequest
+	if useJSON {
+		err = protojson.Unmarshal(body, &request)
+	} else {
+		err = proto.Unmarshal(body, &request)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, types.ApiError{Message: "Invalid OTLP logs request"})
+	}
+
+	lines := ingest.OtlpToLogLines(&request, credential.ProjectId, credential.ClientId)
+	accepted, err := r.receiver.Receive(c.Request().Context(), lines)
+	if err != nil {
+		slog.Error("Error receiving OTLP logs", "project", credential.ProjectId, "error", err)
+		// Exporters retry on 503, lines published before the error are stored twice.
+		return c.JSON(http.StatusServiceUnavailable, types.ApiError{Message: "Error receiving logs"})
+	}
+
+	response := &collogspb.ExportLogsServiceResponse{}
+	if rejected := len(lines) - accepted; rejected > 0 {
+		response.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: int64(rejected),
+			ErrorMessage:       "log records were dropped because the client is not approved",
+		}
+	}
+
+	if useJSON {
+		encoded, err := protojson.Marshal(response)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, encoded)
+	}
+
+	encoded, err := proto.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return c.Blob(http.StatusOK, otlpProtobufContentType, encoded)
+}
+
+// readIngestBody reads the request body, decompressing gzip encoded bodies.
+func readIngestBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	switch r.Header.Get(echo.HeaderContentEncoding) {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, errors.New("Invalid gzip body")
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	default:
+		return nil, fmt.Errorf("Unsupported content encoding %s", r.Header.Get(echo.HeaderContentEncoding))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxIngestBodySize+1))
+	if err != nil {
+		return nil, errors.New("Error reading body")
+	}
+	if len(body) > maxIngestBodySize {
+		return nil, errors.New("Body is too large")
+	}
+	return body, nil
+}
+
+// NewOtlpRouter registers the OTLP/HTTP logs endpoint directly on echo, it is
+// authenticated with ingest tokens instead of sessions.
+func NewOtlpRouter(tokenService *serverauth.IngestTokenService, receiver *ingest.Receiver, e *echo.Echo) *OtlpRouter {
+	if tokenService == nil {
+		panic("No IngestTokenService")
+	}
+	if receiver == nil {
+		panic("No Receiver")
+	}
+
+	router := &OtlpRouter{receiver}
+
+	e.POST("/v1/logs", router.exportLogs, customMiddleware.IngestTokenMiddleware(tokenService))
+
+	return router
+}
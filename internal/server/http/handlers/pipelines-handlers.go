@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/http/htmx"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+type PipelinesRouter struct {
+	pipelinesService  pipelines.PipelinesService
+	projectsService   projects.ProjectsService
+	pipelineProcessor *pipelines.Processor
+}
+
+func (p *PipelinesRouter) renderPage(c echo.Context, status int, projectId string, form admin.PipelineFormProps) error {
+	project, err := p.projectsService.GetProject(c.Request().Context(), projectId)
+	if err != nil {
+		slog.Error("Error fetching project", "error", err)
+		if err.Error() == projects.ErrProjectNotFound {
+			return c.JSON(404, types.ApiError{Message: "Project not found"})
+		}
+		return c.JSON(500, types.ApiError{Message: "Error getting project"})
+	}
+
+	projectPipelines, err := p.pipelinesService.GetPipelines(c.Request().Context(), projectId)
+	if err != nil {
+		slog.Error("Error fetching pipelines", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting pipelines"})
+	}
+
+	form.ProjectID = projectId
+	form.Clients = project.Clients
+
+	return utils.Render(c, status, admin.PipelinesPage(admin.PipelinesPageProps{
+		Project:   project,
+		Pipelines: projectPipelines,
+		Form:      form,
+	}))
+}
+
+func (p *PipelinesRouter) getPipelinesPage(c echo.Context) error {
+	projectId := c.Param("id")
+	if projectId == "" {
+		return c.JSON(400, types.ApiError{Message: "Project ID is required"})
+	}
+
+	return p.renderPage(c, http.StatusOK, projectId, admin.PipelineFormProps{
+		Value: types.PipelineForm{ClientId: pipelines.AllClients, Enabled: true, Stages: "[]"},
+	})
+}
+
+func (p *PipelinesRouter) getEditPipelineForm(c echo.Context) error {
+	pipeline, err := p.pipelinesService.GetPipeline(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		slog.Error("Error fetching pipeline", "error", err)
+		if err.Error() == pipelines.ErrPipelineNotFound {
+			return c.JSON(404, types.ApiError{Message: "Pipeline not found"})
+		}
+		return c.JSON(500, types.ApiError{Message: "Error getting pipeline"})
+	}
+
+	stages, err := json.MarshalIndent(pipeline.Stages, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	project, err := p.projectsService.GetProject(c.Request().Context(), pipeline.ProjectId)
+	if err != nil {
+		return c.JSON(404, types.ApiError{Message: "Project not found"})
+	}
+
+	return utils.Render(c, http.StatusOK, admin.PipelineForm(admin.PipelineFormProps{
+		ProjectID: pipeline.ProjectId,
+		Clients:   project.Clients,
+		Value: types.PipelineForm{
+			ID:        pipeline.ID.Hex(),
+			ProjectId: pipeline.ProjectId,
+			ClientId:  pipeline.ClientId,
+			Name:      pipeline.Name,
+			Enabled:   pipeline.Enabled,
+			Stages:    string(stages),
+		},
+	}))
+}
+
+func (p *PipelinesRouter) savePipeline(c echo.Context) error {
+	var form types.PipelineForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	if err := c.Validate(&form); err != nil {
+		if apiErr, ok := err.(types.ApiError); ok {
+			return p.renderPage(c, http.StatusBadRequest, form.ProjectId, admin.PipelineFormProps{
+				ApiError: apiErr,
+				Value:    form,
+			})
+		}
+		return err
+	}
+
+	if _, err := p.pipelinesService.SavePipeline(c.Request().Context(), form); err != nil {
+		slog.Error("Error saving pipeline", "error", err)
+		apiErr := types.NewApiError("Error saving pipeline")
+		apiErr.Fields["stages"] = err.Error()
+		return p.renderPage(c, http.StatusBadRequest, form.ProjectId, admin.PipelineFormProps{
+			ApiError: apiErr,
+			Value:    form,
+		})
+	}
+
+	if p.pipelineProcessor != nil {
+		p.pipelineProcessor.Invalidate(form.ProjectId)
+	}
+
+	if form.ID != "" {
+		htmx.AddSuccessToast(c, "Pipeline updated")
+	} else {
+		htmx.AddSuccessToast(c, "Pipeline created")
+	}
+
+	return p.renderPage(c, http.StatusOK, form.ProjectId, admin.PipelineFormProps{
+		Value: types.PipelineForm{ClientId: pipelines.AllClients, Enabled: true, Stages: "[]"},
+	})
+}
+
+func (p *PipelinesRouter) deletePipeline(c echo.Context) error {
+	id := c.Param("id")
+	pipeline, err := p.pipelinesService.GetPipeline(c.Request().Context(), id)
+	if err != nil {
+		return c.JSON(404, types.ApiError{Message: "Pipeline not found"})
+	}
+
+	if err := p.pipelinesService.DeletePipeline(c.Request().Context(), id); err != nil {
+		slog.Error("Error deleting pipeline", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error deleting pipeline"})
+	}
+
+	if p.pipelineProcessor != nil {
+		p.pipelineProcessor.Invalidate(pipeline.ProjectId)
+	}
+
+	htmx.AddSuccessToast(c, "Pipeline deleted")
+	return c.HTML(200, "")
+}
+
+func (p *PipelinesRouter) previewPipeline(c echo.Context) error {
+	var form types.PipelinePreviewForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	stages, err := pipelines.ParseStages(form.Stages)
+	if err != nil {
+		return utils.Render(c, http.StatusOK, admin.PipelinePreview(admin.PipelinePreviewProps{Error: err.Error()}))
+	}
+
+	compiled, err := pipelines.Compile(pipelines.Pipeline{Stages: stages})
+	if err != nil {
+		return utils.Render(c, http.StatusOK, admin.PipelinePreview(admin.PipelinePreviewProps{Error: err.Error()}))
+	}
+
+	result := pipelines.Result{Message: form.Sample}
+	compiled.Process(&result)
+
+	fields, err := json.MarshalIndent(result.Fields, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return utils.Render(c, http.StatusOK, admin.PipelinePreview(admin.PipelinePreviewProps{
+		Result: &result,
+		Fields: string(fields),
+	}))
+}
+
+func NewPipelinesRouter(
+	pipelinesService pipelines.PipelinesService,
+	projectsService projects.ProjectsService,
+	pipelineProcessor *pipelines.Processor,
+	e *echo.Group,
+) *PipelinesRouter {
+	router := &PipelinesRouter{pipelinesService, projectsService, pipelineProcessor}
+
+	if router.pipelinesService == nil {
+		panic("No pipelinesService")
+	}
+
+	e.GET("/projects/:id/pipelines", router.getPipelinesPage)
+
+	group := e.Group("/pipelines")
+	group.GET("/:id/edit", router.getEditPipelineForm)
+	group.POST("", router.savePipeline)
+	group.POST("/preview", router.previewPipeline)
+	group.DELETE("/:id", router.deletePipeline)
+
+	return router
+}
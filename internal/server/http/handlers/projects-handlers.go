@@ -7,8 +7,11 @@ import (
 	"log/slog"
 
 	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/auth"
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
 	"github.com/markojerkic/svarog/internal/lib/projects"
 	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/lib/snippets"
 	"github.com/markojerkic/svarog/internal/server/http/htmx"
 	"github.com/markojerkic/svarog/internal/server/types"
 	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
@@ -18,6 +21,9 @@ import (
 type ProjectsRouter struct {
 	projectsService  projects.ProjectsService
 	natsCredsService serverauth.NatsCredentialService
+	ingestTokens     *serverauth.IngestTokenService
+	natsConn         *natsconn.NatsConnection
+	snippetGenerator *snippets.Generator
 }
 
 func (p *ProjectsRouter) getProjects(c echo.Context) error {
@@ -141,6 +147,13 @@ func (p *ProjectsRouter) createProject(c echo.Context) error {
 		}))
 	}
 
+	if createProjectForm.ID == "" {
+		if err := p.natsConn.EnsureProjectStream(c.Request().Context(), project.ID.Hex()); err != nil {
+			slog.Error("Error creating project stream", "error", err)
+			htmx.AddErrorToast(c, "Project created, but its stream could not be created")
+		}
+	}
+
 	htmx.CloseDialog(c)
 	if createProjectForm.ID != "" {
 		htmx.AddSuccessToast(c, "Project updated")
@@ -172,6 +185,10 @@ func (p *ProjectsRouter) deleteProject(c echo.Context) error {
 		return c.JSON(500, types.ApiError{Message: "Error deleting project"})
 	}
 
+	if err := p.natsConn.DeleteProjectStream(c.Request().Context(), id); err != nil {
+		slog.Error("Error deleting project stream", "error", err)
+	}
+
 	htmx.AddSuccessToast(c, "Project deleted")
 	return c.HTML(200, "")
 }
@@ -206,6 +223,54 @@ func (p *ProjectsRouter) createProjectConnString(c echo.Context) error {
 		return c.JSON(400, err)
 	}
 
+	if user, ok := c.Get("user").(*auth.LoggedInUser); ok {
+		request.IssuedBy = user.Username
+	}
+
+	if request.Scope == serverauth.CredentialScopeTail {
+		tailCreds, err := p.natsCredsService.GenerateTailCreds(c.Request().Context(), request)
+		if err != nil {
+			htmx.AddErrorToast(c, "Failed to generate credentials")
+			return c.JSON(500, types.ApiError{Message: "Error generating credentials"})
+		}
+		if wantsJSON(c) {
+			return c.JSON(200, tailCreds)
+		}
+
+		htmx.Reswap(c, htmx.ReswapProps{
+			Swap:   "innerHTML",
+			Target: "#connection-string-form-container",
+		})
+		htmx.AddSuccessToast(c, "Live tail credentials generated")
+		return utils.Render(c, http.StatusOK, admin.TailCredentials(tailCreds))
+	}
+
+	if request.Scope == serverauth.CredentialScopeIngest {
+		if p.ingestTokens == nil {
+			htmx.AddErrorToast(c, "HTTP ingest is not enabled")
+			return c.JSON(400, types.ApiError{Message: "HTTP ingest is not enabled"})
+		}
+		token, err := p.ingestTokens.Generate(c.Request().Context(), request)
+		if err != nil {
+			slog.Error("Error generating ingest token", "error", err)
+			htmx.AddErrorToast(c, "Failed to generate ingest token")
+			return c.JSON(500, types.ApiError{Message: "Error generating ingest token"})
+		}
+		if wantsJSON(c) {
+			return c.JSON(200, token)
+		}
+
+		htmx.Reswap(c, htmx.ReswapProps{
+			Swap:   "innerHTML",
+			Target: "#connection-string-form-container",
+		})
+		htmx.AddSuccessToast(c, "Ingest token generated")
+		return utils.Render(c, http.StatusOK, admin.IngestTokenResult(admin.IngestTokenResultProps{
+			Token:     token,
+			ServerUrl: c.Scheme() + "://" + c.Request().Host,
+		}))
+	}
+
 	creds, err := p.natsCredsService.GenerateConnString(c.Request().Context(), request)
 	if err != nil {
 		htmx.AddErrorToast(c, "Failed to generate credentials")
@@ -213,22 +278,49 @@ func (p *ProjectsRouter) createProjectConnString(c echo.Context) error {
 	}
 
 	connString := creds.GetConnString()
-	slog.Debug("Generated connection string", "connString", connString)
 
-	htmx.CloseDialog(c)
+	deploySnippets, err := p.snippetGenerator.Render(creds, request.ClientID)
+	if err != nil {
+		slog.Error("Error rendering deployment snippets", "error", err)
+		htmx.AddErrorToast(c, "Failed to render deployment snippets")
+		return c.JSON(500, types.ApiError{Message: "Error rendering deployment snippets"})
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(200, connStringResponse{ConnString: connString, Snippets: deploySnippets})
+	}
+	if c.Request().Header.Get("HX-Request") != "true" {
+		return c.String(200, connString)
+	}
+
+	htmx.Reswap(c, htmx.ReswapProps{
+		Swap:   "innerHTML",
+		Target: "#connection-string-form-container",
+	})
 	htmx.AddSuccessToast(c, "Connection string generated and copied to clipboard")
 
 	c.Response().Header().Set("HX-Trigger-After-Swap", fmt.Sprintf(`{"copyToClipboard":"%s"}`, connString))
 
-	return c.String(200, connString)
+	return utils.Render(c, http.StatusOK, admin.ConnectionStringResult(admin.ConnectionStringResultProps{
+		ConnString: connString,
+		Snippets:   deploySnippets,
+	}))
+}
+
+type connStringResponse struct {
+	ConnString string             `json:"connString"`
+	Snippets   []snippets.Snippet `json:"snippets"`
 }
 
 func NewProjectsRouter(
 	projectsService projects.ProjectsService,
 	natsCredsService serverauth.NatsCredentialService,
+	ingestTokens *serverauth.IngestTokenService,
+	natsConn *natsconn.NatsConnection,
+	snippetGenerator *snippets.Generator,
 	e *echo.Group,
 ) *ProjectsRouter {
-	router := &ProjectsRouter{projectsService, natsCredsService}
+	router := &ProjectsRouter{projectsService, natsCredsService, ingestTokens, natsConn, snippetGenerator}
 
 	if router.projectsService == nil {
 		panic("No projectsService")
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/http/htmx"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+// replayTimeLayout matches the value of a datetime-local input.
+const replayTimeLayout = "2006-01-02T15:04"
+
+type ReplayRouter struct {
+	replayService   *ingest.ReplayService
+	projectsService projects.ProjectsService
+}
+
+// wantsJSON reports whether the request comes from an API client rather than htmx.
+func wantsJSON(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON)
+}
+
+func (r *ReplayRouter) renderForm(c echo.Context, status int, form admin.ReplayFormProps) error {
+	projectList, err := r.projectsService.GetProjects(c.Request().Context())
+	if err != nil {
+		slog.Error("Error fetching projects", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting projects"})
+	}
+	form.Projects = projectList
+
+	return utils.Render(c, status, admin.ReplayForm(form))
+}
+
+func (r *ReplayRouter) getReplayPage(c echo.Context) error {
+	projectList, err := r.projectsService.GetProjects(c.Request().Context())
+	if err != nil {
+		slog.Error("Error fetching projects", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting projects"})
+	}
+
+	return utils.Render(c, http.StatusOK, admin.ReplayPage(admin.ReplayPageProps{
+		Projects: projectList,
+		Jobs:     r.replayService.List(),
+		Form:     admin.ReplayFormProps{Projects: projectList},
+	}))
+}
+
+func (r *ReplayRouter) startReplay(c echo.Context) error {
+	var form types.ReplayForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	if err := c.Validate(&form); err != nil {
+		if apiErr, ok := err.(types.ApiError); ok {
+			if wantsJSON(c) {
+				return c.JSON(400, apiErr)
+			}
+			return r.renderForm(c, http.StatusBadRequest, admin.ReplayFormProps{ApiError: apiErr, Value: form})
+		}
+		return err
+	}
+
+	request := ingest.ReplayRequest{
+		ProjectId:     form.ProjectId,
+		ClientId:      form.ClientId,
+		StartSequence: form.StartSequence,
+	}
+	if form.From != "" {
+		startTime, err := parseReplayTime(form.From)
+		if err != nil {
+			apiErr := types.NewApiError("Invalid start time")
+			apiErr.Fields["from"] = "Invalid start time"
+			if wantsJSON(c) {
+				return c.JSON(400, apiErr)
+			}
+			return r.renderForm(c, http.StatusBadRequest, admin.ReplayFormProps{ApiError: apiErr, Value: form})
+		}
+		request.StartTime = startTime
+	}
+
+	job, err := r.replayService.Start(c.Request().Context(), request)
+	if err != nil {
+		slog.Error("Error starting replay", "error", err)
+		if wantsJSON(c) {
+			return c.JSON(500, types.ApiError{Message: "Error starting replay"})
+		}
+		return r.renderForm(c, http.StatusInternalServerError, admin.ReplayFormProps{
+			ApiError: types.NewApiError("Error starting replay: " + err.Error()),
+			Value:    form,
+		})
+	}
+
+	if wantsJSON(c) {
+		return c.JSON(http.StatusAccepted, job)
+	}
+
+	htmx.AddSuccessToast(c, "Replay started")
+	return r.renderForm(c, http.StatusOK, admin.ReplayFormProps{})
+}
+
+// parseReplayTime accepts both RFC 3339 timestamps sent by API clients and
+// the local time sent by the form.
+func parseReplayTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	return time.ParseInLocation(replayTimeLayout, value, time.Local)
+}
+
+func (r *ReplayRouter) getJobs(c echo.Context) error {
+	jobs := r.replayService.List()
+	if wantsJSON(c) {
+		return c.JSON(http.StatusOK, jobs)
+	}
+	return utils.Render(c, http.StatusOK, admin.ReplayJobs(jobs))
+}
+
+func (r *ReplayRouter) getJob(c echo.Context) error {
+	job, err := r.replayService.Get(c.Param("id"))
+	if err != nil {
+		return c.JSON(404, types.ApiError{Message: "Replay job not found"})
+	}
+	return c.JSON(http.StatusOK, job)
+}
+
+func (r *ReplayRouter) cancelJob(c echo.Context) error {
+	if err := r.replayService.Cancel(c.Param("id")); err != nil {
+		if err.Error() == ingest.ErrReplayNotFound {
+			return c.JSON(404, types.ApiError{Message: "Replay job not found"})
+		}
+		return c.JSON(500, types.ApiError{Message: "Error cancelling replay"})
+	}
+
+	if wantsJSON(c) {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	htmx.AddSuccessToast(c, "Replay cancelled")
+	return utils.Render(c, http.StatusOK, admin.ReplayJobs(r.replayService.List()))
+}
+
+func NewReplayRouter(
+	replayService *ingest.ReplayService,
+	projectsService projects.ProjectsService,
+	e *echo.Group,
+) *ReplayRouter {
+	router := &ReplayRouter{replayService, projectsService}
+
+	if router.replayService == nil {
+		panic("No replayService")
+	}
+
+	group := e.Group("/replay")
+	group.GET("", router.getReplayPage)
+	group.POST("", router.startReplay)
+	group.GET("/jobs", router.getJobs)
+	group.GET("/jobs/:id", router.getJob)
+	group.POST("/:id/cancel", router.cancelJob)
+
+	return router
+}
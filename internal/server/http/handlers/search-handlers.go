@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+// SearchRouter searches all clients of one or more projects.
+type SearchRouter struct {
+	logService      db.LogService
+	projectsService projects.ProjectsService
+}
+
+// MergedCursorBinding is the cursor of pages merging the lines of several
+// clients, the line a page ends with and its client.
+type MergedCursorBinding struct {
+	CursorTime           *int64  `query:"cursorTime"`
+	CursorSequenceNumber *int    `query:"cursorSequenceNumber"`
+	CursorProject        string  `query:"cursorProject"`
+	CursorClient         string  `query:"cursorClient"`
+	Direction            *string `query:"direction"`
+}
+
+type ProjectSearchBinding struct {
+	// Projects are searched across all their clients
+	Projects []string        `query:"project"`
+	Search   string          `query:"search"`
+	Mode     db.SearchMode   `query:"mode"`
+	From     types.TimeBound `query:"from"`
+	To       types.TimeBound `query:"to"`
+	MergedCursorBinding
+}
+
+func (params MergedCursorBinding) cursor() *db.MergedCursor {
+	if params.CursorTime == nil || params.CursorSequenceNumber == nil {
+		return nil
+	}
+	return &db.MergedCursor{
+		LastCursor: db.LastCursor{
+			Timestamp:      time.UnixMilli(*params.CursorTime),
+			SequenceNumber: *params.CursorSequenceNumber,
+			IsBackward:     params.Direction == nil || *params.Direction == "backward",
+		},
+		ProjectId: params.CursorProject,
+		ClientId:  params.CursorClient,
+	}
+}
+
+// ProjectSearchResult is a page of matches of the project search API, Newer
+// and Older are the query parameters of the next pages.
+type ProjectSearchResult struct {
+	Logs         []LogLine            `json:"logs"`
+	Count        int64                `json:"count"`
+	CountLimited bool                 `json:"countLimited"`
+	Newer        *ProjectSearchCursor `json:"newer,omitempty"`
+	Older        *ProjectSearchCursor `json:"older,omitempty"`
+}
+
+type ProjectSearchCursor struct {
+	SearchCursor
+	CursorProject string `json:"cursorProject"`
+	CursorClient  string `json:"cursorClient"`
+}
+
+func newProjectSearchCursor(cursor *db.MergedCursor) *ProjectSearchCursor {
+	if cursor == nil {
+		return nil
+	}
+	return &ProjectSearchCursor{
+		SearchCursor:  *newSearchCursor(&cursor.LastCursor),
+		CursorProject: cursor.ProjectId,
+		CursorClient:  cursor.ClientId,
+	}
+}
+
+// searchSources are the clients of the searched projects.
+func searchSources(searched []projects.Project) []db.LogSource {
+	sources := []db.LogSource{}
+	for _, project := range searched {
+		for _, client := range project.Clients {
+			sources = append(sources, db.LogSource{ProjectId: project.ID.Hex(), ClientId: client})
+		}
+	}
+	return sources
+}
+
+func (self *SearchRouter) search(c echo.Context) error {
+	var params ProjectSearchBinding
+	if err := c.Bind(&params); err != nil {
+		slog.Error("Bindings for project search not correct", "error", err)
+		return c.JSON(400, "Bad request")
+	}
+
+	timeRange, err := newTimeRange(params.From, params.To)
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+	if !params.Mode.IsValid() {
+		return c.JSON(400, "Unknown search mode")
+	}
+
+	if wantsJSON(c) {
+		return self.searchApi(c, params, timeRange)
+	}
+
+	projectList, err := self.projectsService.GetProjects(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	searched := []projects.Project{}
+	for _, project := range projectList {
+		if slices.Contains(params.Projects, project.ID.Hex()) {
+			searched = append(searched, project)
+		}
+	}
+	if len(searched) < len(params.Projects) {
+		return c.JSON(400, "Unknown project")
+	}
+
+	props := pages.SearchPageProps{
+		Projects:   projectList,
+		Selected:   params.Projects,
+		Search:     params.Search,
+		SearchMode: params.Mode,
+		From:       params.From,
+		To:         params.To,
+	}
+	if params.Search != "" && len(searched) > 0 {
+		cursor := params.cursor()
+		req := db.MergedSearchRequest{
+			Sources:   searchSources(searched),
+			PageSize:  DEFAULT_PAGE_SIZE,
+			Cursor:    cursor,
+			TimeRange: timeRange,
+			Query:     params.Search,
+			Mode:      params.Mode,
+		}
+		if cursor == nil {
+			req.CountLimit = SEARCH_COUNT_LIMIT
+		}
+
+		page, err := db.SearchSources(c.Request().Context(), self.logService, req)
+		var syntaxErr *logquery.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			props.SearchError = syntaxErr
+		} else if errors.Is(err, db.ErrSearchTimeout) {
+			props.SearchTimedOut = true
+		} else if err != nil {
+			return err
+		}
+		props.Results = &page
+
+		if c.Request().Header.Get("HX-Request") == "true" && cursor != nil {
+			return utils.Render(c, http.StatusOK, pages.SearchResultLines(props))
+		}
+	}
+
+	return utils.Render(c, http.StatusOK, pages.SearchPage(props))
+}
+
+// searchApi answers the search with JSON, the lines are oldest first like the
+// client search API.
+func (self *SearchRouter) searchApi(c echo.Context, params ProjectSearchBinding, timeRange db.TimeRange) error {
+	if len(params.Projects) == 0 {
+		return c.JSON(400, "No project")
+	}
+
+	searched := make([]projects.Project, len(params.Projects))
+	for i, projectId := range params.Projects {
+		project, err := self.projectsService.GetProject(c.Request().Context(), projectId)
+		if err != nil {
+			return c.JSON(400, "Unknown project")
+		}
+		searched[i] = project
+	}
+
+	page, err := db.SearchSources(c.Request().Context(), self.logService, db.MergedSearchRequest{
+		Sources:    searchSources(searched),
+		PageSize:   DEFAULT_PAGE_SIZE,
+		Cursor:     params.cursor(),
+		TimeRange:  timeRange,
+		Query:      params.Search,
+		Mode:       params.Mode,
+		CountLimit: SEARCH_COUNT_LIMIT,
+	})
+	var syntaxErr *logquery.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return c.JSON(400, syntaxErr)
+	}
+	if errors.Is(err, db.ErrSearchTimeout) {
+		return c.JSON(504, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	logsLen := len(page.Logs)
+	mappedLogs := make([]LogLine, logsLen)
+	for i, log := range page.Logs {
+		mappedLogs[logsLen-i-1] = LogLine{
+			log.ID.Hex(),
+			log.Timestamp.UnixMilli(),
+			log.LogLine,
+			log.SequenceNumber,
+			log.Client,
+		}
+	}
+
+	return c.JSON(200, ProjectSearchResult{
+		Logs:         mappedLogs,
+		Count:        page.Count,
+		CountLimited: page.CountLimited,
+		Newer:        newProjectSearchCursor(page.ForwardCursor),
+		Older:        newProjectSearchCursor(page.BackwardCursor),
+	})
+}
+
+func NewSearchRouter(logService db.LogService, projectsService projects.ProjectsService, e *echo.Group) *SearchRouter {
+	router := &SearchRouter{logService, projectsService}
+
+	e.GET("/search", router.search)
+
+	return router
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/http/htmx"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/pages/admin"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+type StreamsRouter struct {
+	natsConn        *natsconn.NatsConnection
+	projectsService projects.ProjectsService
+}
+
+// streamRows pairs every stream with the name of the project it belongs to.
+func (s *StreamsRouter) streamRows(c echo.Context) ([]admin.StreamRow, error) {
+	states, err := s.natsConn.StreamStates(c.Request().Context())
+	if err != nil {
+		return nil, err
+	}
+
+	projectNames := map[string]string{}
+	if s.natsConn.PerProjectStreams() {
+		projectList, err := s.projectsService.GetProjects(c.Request().Context())
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projectList {
+			projectNames[s.natsConn.StreamName(project.ID.Hex())] = project.Name
+		}
+	}
+
+	rows := make([]admin.StreamRow, len(states))
+	for i, state := range states {
+		rows[i] = admin.StreamRow{State: state, ProjectName: projectNames[state.Name]}
+	}
+	return rows, nil
+}
+
+func (s *StreamsRouter) findStreamRow(c echo.Context) (admin.StreamRow, bool, error) {
+	rows, err := s.streamRows(c)
+	if err != nil {
+		return admin.StreamRow{}, false, err
+	}
+
+	index := slices.IndexFunc(rows, func(row admin.StreamRow) bool {
+		return row.State.Name == c.Param("name")
+	})
+	if index == -1 {
+		return admin.StreamRow{}, false, nil
+	}
+	return rows[index], true, nil
+}
+
+func (s *StreamsRouter) getStreamsPage(c echo.Context) error {
+	rows, err := s.streamRows(c)
+	if err != nil {
+		slog.Error("Error fetching streams", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting streams"})
+	}
+
+	if wantsJSON(c) {
+		states := make([]natsconn.StreamState, len(rows))
+		for i, row := range rows {
+			states[i] = row.State
+		}
+		return c.JSON(http.StatusOK, states)
+	}
+
+	return utils.Render(c, http.StatusOK, admin.StreamsPage(admin.StreamsPageProps{
+		Streams:    rows,
+		PerProject: s.natsConn.PerProjectStreams(),
+	}))
+}
+
+func (s *StreamsRouter) getStreamRow(c echo.Context) error {
+	row, found, err := s.findStreamRow(c)
+	if err != nil {
+		slog.Error("Error fetching stream", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting stream"})
+	}
+	if !found {
+		return c.JSON(404, types.ApiError{Message: "Stream not found"})
+	}
+
+	return utils.Render(c, http.StatusOK, admin.StreamTableRow(row))
+}
+
+func (s *StreamsRouter) getEditLimitsForm(c echo.Context) error {
+	row, found, err := s.findStreamRow(c)
+	if err != nil {
+		slog.Error("Error fetching stream", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting stream"})
+	}
+	if !found {
+		return c.JSON(404, types.ApiError{Message: "Stream not found"})
+	}
+
+	return utils.Render(c, http.StatusOK, admin.StreamLimitsRow(admin.StreamLimitsRowProps{
+		Row: row,
+		Value: types.StreamLimitsForm{
+			MaxMegabytes: row.State.MaxBytes / (1024 * 1024),
+			MaxAgeHours:  int64(row.State.MaxAge / time.Hour),
+		},
+	}))
+}
+
+func (s *StreamsRouter) updateLimits(c echo.Context) error {
+	var form types.StreamLimitsForm
+	if err := c.Bind(&form); err != nil {
+		return c.JSON(400, err)
+	}
+
+	row, found, err := s.findStreamRow(c)
+	if err != nil {
+		slog.Error("Error fetching stream", "error", err)
+		return c.JSON(500, types.ApiError{Message: "Error getting stream"})
+	}
+	if !found {
+		return c.JSON(404, types.ApiError{Message: "Stream not found"})
+	}
+
+	if err := c.Validate(&form); err != nil {
+		if apiErr, ok := err.(types.ApiError); ok {
+			return utils.Render(c, http.StatusBadRequest, admin.StreamLimitsRow(admin.StreamLimitsRowProps{
+				Row:      row,
+				ApiError: apiErr,
+				Value:    form,
+			}))
+		}
+		return err
+	}
+
+	err = s.natsConn.UpdateStreamLimits(
+		c.Request().Context(),
+		row.State.Name,
+		form.MaxMegabytes*1024*1024,
+		time.Duration(form.MaxAgeHours)*time.Hour,
+	)
+	if err != nil {
+		slog.Error("Error updating stream limits", "error", err)
+		return utils.Render(c, http.StatusInternalServerError, admin.StreamLimitsRow(admin.StreamLimitsRowProps{
+			Row:      row,
+			ApiError: types.NewApiError("Error updating stream limits: " + err.Error()),
+			Value:    form,
+		}))
+	}
+
+	htmx.AddSuccessToast(c, "Stream limits updated")
+	return s.getStreamRow(c)
+}
+
+func NewStreamsRouter(
+	natsConn *natsconn.NatsConnection,
+	projectsService projects.ProjectsService,
+	e *echo.Group,
+) *StreamsRouter {
+	router := &StreamsRouter{natsConn, projectsService}
+
+	if router.natsConn == nil {
+		panic("No natsConn")
+	}
+
+	group := e.Group("/streams")
+	group.GET("", router.getStreamsPage)
+	group.GET("/:name", router.getStreamRow)
+	group.GET("/:name/edit", router.getEditLimitsForm)
+	group.POST("/:name", router.updateLimits)
+
+	return router
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/internal/server/ui/components/logs"
+	"github.com/markojerkic/svarog/internal/server/ui/pages"
+	"github.com/markojerkic/svarog/internal/server/ui/utils"
+)
+
+// TimelineRouter shows the lines of several clients interleaved by time.
+type TimelineRouter struct {
+	logService      db.LogService
+	projectsService projects.ProjectsService
+}
+
+type TimelineBinding struct {
+	// Clients are "<projectId>/<clientId>"
+	Clients []string `query:"client"`
+	// Instances limit their client to some of its instances, they are
+	// "<projectId>/<clientId>/<instanceId>"
+	Instances []string        `query:"instance"`
+	From      types.TimeBound `query:"from"`
+	To        types.TimeBound `query:"to"`
+	MergedCursorBinding
+}
+
+// sources are the clients of the timeline with their instance filters.
+func (params TimelineBinding) sources() ([]db.LogSource, error) {
+	sources := []db.LogSource{}
+	indexes := map[string]int{}
+	for _, client := range params.Clients {
+		projectId, clientId, ok := strings.Cut(client, "/")
+		if !ok || projectId == "" || clientId == "" {
+			return nil, fmt.Errorf("invalid client %q, expected <project>/<client>", client)
+		}
+		if _, ok := indexes[client]; ok {
+			continue
+		}
+		indexes[client] = len(sources)
+		sources = append(sources, db.LogSource{ProjectId: projectId, ClientId: clientId})
+	}
+
+	for _, instance := range params.Instances {
+		projectId, rest, _ := strings.Cut(instance, "/")
+		clientId, instanceId, ok := strings.Cut(rest, "/")
+		i, known := indexes[projectId+"/"+clientId]
+		if !ok || !known {
+			return nil, fmt.Errorf("instance %q isn't of a client of the timeline", instance)
+		}
+		if sources[i].Instances == nil {
+			sources[i].Instances = &[]string{}
+		}
+		*sources[i].Instances = append(*sources[i].Instances, instanceId)
+	}
+
+	return sources, nil
+}
+
+// timelineLineRenderer renders the live lines of the sources for the timeline,
+// skipping lines of instances filtered out.
+func timelineLineRenderer(sources []db.LogSource) func(line []byte) []byte {
+	return func(line []byte) []byte {
+		var liveLine types.LiveLogLine
+		if err := json.Unmarshal(line, &liveLine); err != nil {
+			slog.Error("Failed to unmarshal live log line", "error", err)
+			return nil
+		}
+
+		included := slices.ContainsFunc(sources, func(source db.LogSource) bool {
+			return source.ProjectId == liveLine.ProjectId &&
+				source.ClientId == liveLine.ClientId &&
+				(source.Instances == nil || slices.Contains(*source.Instances, liveLine.InstanceId))
+		})
+		if !included {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		err := logs.OobSwapLogLine(logs.LogLineProps{LogLine: liveLine.StoredLog(), ShowSource: true}).Render(context.Background(), &buf)
+		if err != nil {
+			slog.Error("Failed to render timeline line", "error", err)
+			return nil
+		}
+		return buf.Bytes()
+	}
+}
+
+func (self *TimelineRouter) timelineHandler(c echo.Context) error {
+	var params TimelineBinding
+	if err := c.Bind(&params); err != nil {
+		slog.Error("Bindings for timeline not correct", "error", err)
+		return c.JSON(400, "Bad request")
+	}
+
+	sources, err := params.sources()
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+	timeRange, err := newTimeRange(params.From, params.To)
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+
+	cursor := params.cursor()
+	props := pages.TimelinePageProps{
+		Clients:   params.Clients,
+		Instances: params.Instances,
+		From:      params.From,
+		To:        params.To,
+	}
+	if len(sources) > 0 {
+		props.LogPage, err = db.GetSourcesLogs(c.Request().Context(), self.logService, db.MergedLogsRequest{
+			Sources:   sources,
+			PageSize:  DEFAULT_PAGE_SIZE,
+			Cursor:    cursor,
+			TimeRange: timeRange,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Request().Header.Get("HX-Request") == "true" && cursor != nil {
+		return utils.Render(c, http.StatusOK, pages.TimelineLines(props))
+	}
+
+	props.Projects, err = self.projectsService.GetProjects(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return utils.Render(c, http.StatusOK, pages.TimelinePage(props))
+}
+
+func NewTimelineRouter(logService db.LogService, projectsService projects.ProjectsService, e *echo.Group) *TimelineRouter {
+	router := &TimelineRouter{logService, projectsService}
+
+	e.GET("/timeline", router.timelineHandler)
+
+	return router
+}
@@ -8,6 +8,7 @@ import (
 
 	gorillaWs "github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
 	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
 	"github.com/nats-io/nats.go"
 )
@@ -23,8 +24,12 @@ type WsConnection struct {
 	wsConnection *gorillaWs.Conn
 	wsHub        *websocket.WatchHub
 	lines        chan []byte
-	natsSub      *nats.Subscription
+	natsSubs     []*nats.Subscription
 	closeOnce    sync.Once
+	// render turns a published line into the message written to the socket,
+	// lines it returns nil for are skipped. Lines are written as they are
+	// published without it.
+	render func(line []byte) []byte
 }
 
 type WsMessageType string
@@ -38,9 +43,12 @@ const (
 
 func (self *WsConnection) closeSubscription() {
 	self.closeOnce.Do(func() {
-		self.natsSub.Unsubscribe()
-		self.natsSub.Drain()
+		for _, natsSub := range self.natsSubs {
+			natsSub.Unsubscribe()
+			natsSub.Drain()
+		}
 		close(self.lines)
+		metrics.WebSocketSubscribers.Dec()
 	})
 	self.wsConnection.Close()
 }
@@ -48,6 +56,12 @@ func (self *WsConnection) closeSubscription() {
 func (self *WsConnection) writePipe(wsWaitGroup *sync.WaitGroup) {
 	defer wsWaitGroup.Done()
 	for renderedLogLine := range self.lines {
+		if self.render != nil {
+			renderedLogLine = self.render(renderedLogLine)
+			if renderedLogLine == nil {
+				continue
+			}
+		}
 		err := self.wsConnection.WriteMessage(gorillaWs.TextMessage, renderedLogLine)
 		if err != nil {
 			slog.Error("Error writing WS message", "error", err)
@@ -87,18 +101,60 @@ func (self *WsRouter) connectionHandler(c echo.Context) error {
 		return err
 	}
 
+	return self.serve(c, &WsConnection{
+		clientId: clientId,
+		wsHub:    self.wsHub,
+		lines:    lines,
+		natsSubs: []*nats.Subscription{subscription},
+	})
+}
+
+// timelineHandler follows the lines of all clients of a timeline, rendering
+// them labeled with their client.
+func (self *WsRouter) timelineHandler(c echo.Context) error {
+	var params TimelineBinding
+	if err := c.Bind(&params); err != nil {
+		return c.JSON(400, "Bad request")
+	}
+	sources, err := params.sources()
+	if err != nil {
+		return c.JSON(400, err.Error())
+	}
+
+	lines := make(chan []byte, 100)
+	subscriptions := make([]*nats.Subscription, 0, len(sources))
+	for _, source := range sources {
+		subscription, err := self.wsHub.SubscribeLive(source.ProjectId, source.ClientId, lines)
+		if err != nil {
+			for _, subscription := range subscriptions {
+				subscription.Unsubscribe()
+			}
+			return err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return self.serve(c, &WsConnection{
+		clientId: "timeline",
+		wsHub:    self.wsHub,
+		lines:    lines,
+		natsSubs: subscriptions,
+		render:   timelineLineRenderer(sources),
+	})
+}
+
+// serve upgrades the request and pipes the subscribed lines to the socket.
+func (self *WsRouter) serve(c echo.Context, wsConnection *WsConnection) error {
 	conn, err := wsUpgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
+		for _, natsSub := range wsConnection.natsSubs {
+			natsSub.Unsubscribe()
+		}
 		return err
 	}
+	wsConnection.wsConnection = conn
 
-	wsConnection := &WsConnection{
-		clientId:     clientId,
-		wsConnection: conn,
-		wsHub:        self.wsHub,
-		lines:        lines,
-		natsSub:      subscription,
-	}
+	metrics.WebSocketSubscribers.Inc()
 
 	wsWaitGroup := &sync.WaitGroup{}
 	wsWaitGroup.Add(2)
@@ -123,6 +179,7 @@ func NewWsConnectionRouter(hub *websocket.WatchHub, parentRouter *echo.Group) *W
 		api:          api,
 	}
 
+	api.GET("/timeline", router.timelineHandler)
 	api.GET("/:projectId/:clientId", router.connectionHandler)
 	slog.Info("Created WS connection router")
 
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"log/slog"
+)
+
+// IngestTokenMiddleware authenticates shippers with an ingest token sent as a
+// bearer token, an Elasticsearch API key, or as the password of basic auth for
+// shippers that only support that. The token's credential is stored as "ingestCredential".
+func IngestTokenMiddleware(tokenService *serverauth.IngestTokenService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := ingestToken(c.Request())
+			if token == "" {
+				return c.JSON(http.StatusUnauthorized, types.ApiError{Message: "Ingest token is required"})
+			}
+
+			credential, err := tokenService.Authenticate(c.Request().Context(), token)
+			if err != nil {
+				if err.Error() == serverauth.ErrInvalidIngestToken {
+					return c.JSON(http.StatusUnauthorized, types.ApiError{Message: "Invalid ingest token"})
+				}
+				slog.Error("Error authenticating ingest token", "error", err)
+				return c.JSON(http.StatusInternalServerError, types.ApiError{Message: "Error authenticating ingest token"})
+			}
+
+			c.Set("ingestCredential", credential)
+			return next(c)
+		}
+	}
+}
+
+func ingestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	// Elasticsearch shippers send "ApiKey base64(id:key)", the token is the key
+	if apiKey, ok := strings.CutPrefix(r.Header.Get("Authorization"), "ApiKey "); ok {
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(apiKey)); err == nil {
+			if _, key, ok := strings.Cut(string(decoded), ":"); ok {
+				return key
+			}
+		}
+		return strings.TrimSpace(apiKey)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
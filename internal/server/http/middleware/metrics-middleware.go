@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/metrics"
+)
+
+// MetricsMiddleware records request counts and latency per route template,
+// so path parameters don't blow up label cardinality.
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			status := c.Response().Status
+			if httpErr, ok := err.(*echo.HTTPError); ok {
+				status = httpErr.Code
+			}
+
+			route := c.Path()
+			method := c.Request().Method
+			metrics.HttpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+			metrics.HttpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
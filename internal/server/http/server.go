@@ -14,11 +14,16 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/markojerkic/svarog/internal/lib/auth"
 	"github.com/markojerkic/svarog/internal/lib/files"
+	"github.com/markojerkic/svarog/internal/lib/health"
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
 	"github.com/markojerkic/svarog/internal/lib/projects"
 	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/lib/snippets"
 	"github.com/markojerkic/svarog/internal/server/db"
 	"github.com/markojerkic/svarog/internal/server/http/handlers"
 	customMiddleware "github.com/markojerkic/svarog/internal/server/http/middleware"
+	"github.com/markojerkic/svarog/internal/server/ingest"
 	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
 )
 
@@ -29,6 +34,16 @@ type HttpServer struct {
 	filesService          files.FileService
 	projectsService       projects.ProjectsService
 	natsCredentialService *serverauth.NatsCredentialService
+	credentialRegistry    serverauth.CredentialRegistry
+	accountRevoker        *serverauth.AccountRevoker
+	natsConn              *natsconn.NatsConnection
+	snippetGenerator      *snippets.Generator
+	pipelinesService      pipelines.PipelinesService
+	pipelineProcessor     *pipelines.Processor
+	healthService         *health.HealthService
+	replayService         *ingest.ReplayService
+	ingestTokenService    *serverauth.IngestTokenService
+	receiver              *ingest.Receiver
 	watchHub              *websocket.WatchHub
 
 	serverPort int
@@ -42,6 +57,16 @@ type HttpServerOptions struct {
 	FilesService          files.FileService
 	ProjectsService       projects.ProjectsService
 	NatsCredentialService *serverauth.NatsCredentialService
+	CredentialRegistry    serverauth.CredentialRegistry
+	AccountRevoker        *serverauth.AccountRevoker
+	NatsConnection        *natsconn.NatsConnection
+	SnippetGenerator      *snippets.Generator
+	PipelinesService      pipelines.PipelinesService
+	PipelineProcessor     *pipelines.Processor
+	HealthService         *health.HealthService
+	ReplayService         *ingest.ReplayService
+	IngestTokenService    *serverauth.IngestTokenService
+	Receiver              *ingest.Receiver
 	WatchHub              *websocket.WatchHub
 
 	ServerPort int
@@ -52,6 +77,12 @@ func (self *HttpServer) Start() error {
 	self.echo = e
 	e.Validator = &Validator{validator: validator.New()}
 	e.HTTPErrorHandler = customMiddleware.CustomHTTPErrorHandler
+	e.Use(customMiddleware.MetricsMiddleware())
+
+	handlers.NewHealthRouter(self.healthService, e)
+	handlers.NewOtlpRouter(self.ingestTokenService, self.receiver, e)
+	handlers.NewLokiRouter(self.ingestTokenService, self.receiver, e)
+	handlers.NewElasticRouter(self.ingestTokenService, self.receiver, e)
 
 	sessionMiddleware := session.MiddlewareWithConfig(session.Config{
 		Store: self.sessionStore,
@@ -65,9 +96,16 @@ func (self *HttpServer) Start() error {
 	adminApi := e.Group("/admin", sessionMiddleware, customMiddleware.AuthContextMiddleware(self.authService), customMiddleware.RequiresRoleMiddleware(auth.ADMIN))
 
 	handlers.NewHomeHandler(privateApi, self.projectsService)
-	handlers.NewProjectsRouter(self.projectsService, *self.natsCredentialService, adminApi)
+	handlers.NewProjectsRouter(self.projectsService, *self.natsCredentialService, self.ingestTokenService, self.natsConn, self.snippetGenerator, adminApi)
+	handlers.NewCredentialsRouter(self.credentialRegistry, self.accountRevoker, self.projectsService, adminApi)
+	handlers.NewClientsRouter(self.projectsService, adminApi)
+	handlers.NewStreamsRouter(self.natsConn, self.projectsService, adminApi)
+	handlers.NewPipelinesRouter(self.pipelinesService, self.projectsService, self.pipelineProcessor, adminApi)
+	handlers.NewReplayRouter(self.replayService, self.projectsService, adminApi)
 	handlers.NewAuthRouter(self.authService, privateApi, publicApi)
 	handlers.NewLogsRouter(self.logService, privateApi)
+	handlers.NewSearchRouter(self.logService, self.projectsService, privateApi)
+	handlers.NewTimelineRouter(self.logService, self.projectsService, privateApi)
 	handlers.NewWsConnectionRouter(self.watchHub, privateApi)
 
 	e.Static("/assets", "internal/server/ui/assets")
@@ -104,6 +142,16 @@ func NewServer(options HttpServerOptions) *HttpServer {
 		filesService:          options.FilesService,
 		projectsService:       options.ProjectsService,
 		natsCredentialService: options.NatsCredentialService,
+		credentialRegistry:    options.CredentialRegistry,
+		accountRevoker:        options.AccountRevoker,
+		natsConn:              options.NatsConnection,
+		snippetGenerator:      options.SnippetGenerator,
+		pipelinesService:      options.PipelinesService,
+		pipelineProcessor:     options.PipelineProcessor,
+		healthService:         options.HealthService,
+		replayService:         options.ReplayService,
+		ingestTokenService:    options.IngestTokenService,
+		receiver:              options.Receiver,
 		watchHub:              options.WatchHub,
 	}
 
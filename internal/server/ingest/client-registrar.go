@@ -0,0 +1,58 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/projects"
+)
+
+// clientStatusTTL is how long a client's status is cached, so approving or
+// rejecting a client takes effect within this interval.
+const clientStatusTTL = 30 * time.Second
+
+type cachedClientStatus struct {
+	status    projects.ClientStatus
+	expiresAt time.Time
+}
+
+// ClientRegistrar registers clients publishing with project-wide credentials
+// the first time their log lines are ingested.
+type ClientRegistrar struct {
+	projectsService projects.ProjectsService
+
+	mutex    sync.Mutex
+	statuses map[string]cachedClientStatus
+}
+
+func NewClientRegistrar(projectsService projects.ProjectsService) *ClientRegistrar {
+	return &ClientRegistrar{
+		projectsService: projectsService,
+		statuses:        map[string]cachedClientStatus{},
+	}
+}
+
+// Status registers the client if it's new and returns whether it's approved,
+// pending or rejected.
+func (r *ClientRegistrar) Status(ctx context.Context, projectId, clientId string) (projects.ClientStatus, error) {
+	key := projectId + "." + clientId
+
+	r.mutex.Lock()
+	cached, ok := r.statuses[key]
+	r.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.status, nil
+	}
+
+	status, err := r.projectsService.RegisterClient(ctx, projectId, clientId)
+	if err != nil {
+		return "", err
+	}
+
+	r.mutex.Lock()
+	r.statuses[key] = cachedClientStatus{status: status, expiresAt: time.Now().Add(clientStatusTTL)}
+	r.mutex.Unlock()
+
+	return status, nil
+}
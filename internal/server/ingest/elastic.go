@@ -0,0 +1,298 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Document fields picking the message, instance and level of bulk documents,
+// dotted paths are looked up both as flat keys and in nested objects.
+var (
+	elasticMessageFields  = []string{"message", "log", "msg"}
+	elasticInstanceFields = []string{"host.name", "host.hostname", "hostname", "kubernetes.pod.name", "container.name"}
+	elasticLevelFields    = []string{"log.level", "level", "severity"}
+)
+
+// elasticIndexDateSuffix matches the date of daily indices like logs-2026.10.19
+var elasticIndexDateSuffix = regexp.MustCompile(`[-_.]\d{4}[-.]\d{2}[-.]\d{2}$`)
+
+// ElasticBulkItem is the result of a single bulk action, marshalled into the
+// "items" of the Elasticsearch response.
+type ElasticBulkItem struct {
+	Action string
+	Index  string
+	Id     string
+	Status int
+	Error  string
+}
+
+func (item ElasticBulkItem) MarshalJSON() ([]byte, error) {
+	result := map[string]any{
+		"_index": item.Index,
+		"_id":    item.Id,
+		"status": item.Status,
+	}
+	if item.Error != "" {
+		result["error"] = map[string]string{
+			"type":   "illegal_argument_exception",
+			"reason": item.Error,
+		}
+	} else {
+		result["result"] = "created"
+		result["_version"] = 1
+	}
+	return json.Marshal(map[string]any{item.Action: result})
+}
+
+type elasticAction struct {
+	Index string `json:"_index"`
+	Id    string `json:"_id"`
+}
+
+// ElasticBulkToLogLines parses an NDJSON bulk body. Index and create actions
+// become log lines of projectId, other actions fail with an item error.
+// defaultIndex is the index of the URL, used when an action has none.
+// clientId "*" uses the index name, without a date suffix, as the client.
+func ElasticBulkToLogLines(body []byte, defaultIndex string, projectId string, clientId string) ([]db.LogLineWithHost, []ElasticBulkItem, error) {
+	lines := []db.LogLineWithHost{}
+	items := []ElasticBulkItem{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), len(body)+1)
+
+	for scanner.Scan() {
+		actionLine := bytes.TrimSpace(scanner.Bytes())
+		if len(actionLine) == 0 {
+			continue
+		}
+
+		var action map[string]elasticAction
+		if err := json.Unmarshal(actionLine, &action); err != nil || len(action) != 1 {
+			return nil, nil, fmt.Errorf("malformed action/metadata line [%d]", len(items)+1)
+		}
+
+		for name, metadata := range action {
+			item := ElasticBulkItem{Action: name, Index: metadata.Index, Id: metadata.Id}
+			if item.Index == "" {
+				item.Index = defaultIndex
+			}
+			if item.Id == "" {
+				item.Id = primitive.NewObjectID().Hex()
+			}
+
+			if name == "delete" {
+				item.Status = 400
+				item.Error = "svarog only supports the index and create actions"
+				items = append(items, item)
+				continue
+			}
+
+			// Every other action is followed by a source line
+			if !scanner.Scan() {
+				return nil, nil, fmt.Errorf("action [%s] is missing its source", name)
+			}
+			if name != "index" && name != "create" {
+				item.Status = 400
+				item.Error = "svarog only supports the index and create actions"
+				items = append(items, item)
+				continue
+			}
+			if item.Index == "" {
+				item.Status = 400
+				item.Error = "index is missing"
+				items = append(items, item)
+				continue
+			}
+
+			var document map[string]any
+			decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+			decoder.UseNumber()
+			if err := decoder.Decode(&document); err != nil {
+				item.Status = 400
+				item.Error = "failed to parse the document: " + err.Error()
+				items = append(items, item)
+				continue
+			}
+
+			client := clientId
+			if client == "*" || client == "" {
+				client = indexClient(item.Index)
+			}
+
+			line := elasticDocumentToLogLine(document, projectId, client)
+			line.Sequence = len(lines)
+			lines = append(lines, line)
+
+			item.Status = 201
+			items = append(items, item)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return lines, items, nil
+}
+
+func elasticDocumentToLogLine(document map[string]any, projectId string, clientId string) db.LogLineWithHost {
+	message, _ := takeDocumentString(document, elasticMessageFields)
+	instance, ok := documentString(document, elasticInstanceFields)
+	if !ok {
+		instance = clientId
+	}
+	level, _ := takeDocumentString(document, elasticLevelFields)
+
+	timestamp := time.Now()
+	if value, ok := document["@timestamp"]; ok {
+		if parsed, ok := parseElasticTimestamp(value); ok {
+			timestamp = parsed
+			delete(document, "@timestamp")
+		}
+	}
+
+	line := db.LogLineWithHost{
+		LogLine: &rpc.LogLine{
+			Message:    message,
+			Timestamp:  timestamp,
+			InstanceId: instance,
+		},
+		ProjectId: projectId,
+		ClientId:  clientId,
+		Hostname:  instance,
+		Level:     pipelines.NormalizeLevel(level),
+	}
+
+	if traceId, ok := takeDocumentString(document, []string{"trace.id"}); ok {
+		line.TraceId = traceId
+	}
+	if spanId, ok := takeDocumentString(document, []string{"span.id"}); ok {
+		line.SpanId = spanId
+	}
+
+	fields := documentFields(document)
+	if len(fields) > 0 {
+		line.Fields = fields
+	}
+	return line
+}
+
+// indexClient is the client of an index, daily indices of the same
+// client share it.
+func indexClient(index string) string {
+	client := elasticIndexDateSuffix.ReplaceAllString(index, "")
+	return strings.ReplaceAll(client, ".", "-")
+}
+
+func parseElasticTimestamp(value any) (time.Time, bool) {
+	switch value := value.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"} {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, true
+			}
+		}
+		if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.UnixMilli(millis), true
+		}
+	case json.Number:
+		if millis, err := value.Int64(); err == nil {
+			return time.UnixMilli(millis), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// documentString returns the first string set at one of the dotted paths.
+func documentString(document map[string]any, paths []string) (string, bool) {
+	for _, path := range paths {
+		if value, ok := document[path].(string); ok && value != "" {
+			return value, true
+		}
+
+		current := document
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			if i == len(keys)-1 {
+				if value, ok := current[key].(string); ok && value != "" {
+					return value, true
+				}
+				break
+			}
+			nested, ok := current[key].(map[string]any)
+			if !ok {
+				break
+			}
+			current = nested
+		}
+	}
+	return "", false
+}
+
+// takeDocumentString is documentString that also removes the value, so it
+// isn't stored twice.
+func takeDocumentString(document map[string]any, paths []string) (string, bool) {
+	for _, path := range paths {
+		value, ok := documentString(document, []string{path})
+		if !ok {
+			continue
+		}
+
+		if flatValue, flat := document[path].(string); flat && flatValue == value {
+			delete(document, path)
+			return value, true
+		}
+		keys := strings.Split(path, ".")
+		current := document
+		for _, key := range keys[:len(keys)-1] {
+			current = current[key].(map[string]any)
+		}
+		delete(current, keys[len(keys)-1])
+		return value, true
+	}
+	return "", false
+}
+
+// documentFields stores the rest of the document as fields, dots in keys are
+// replaced by underscores and numbers keep their JSON type.
+func documentFields(document map[string]any) map[string]any {
+	fields := map[string]any{}
+	for key, value := range document {
+		if nested, ok := value.(map[string]any); ok && len(nested) == 0 {
+			continue
+		}
+		fields[strings.ReplaceAll(key, ".", "_")] = documentValue(value)
+	}
+	return fields
+}
+
+func documentValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		return documentFields(value)
+	case []any:
+		values := make([]any, len(value))
+		for i, item := range value {
+			values[i] = documentValue(item)
+		}
+		return values
+	case json.Number:
+		if integer, err := value.Int64(); err == nil {
+			return integer
+		}
+		float, _ := value.Float64()
+		return float
+	default:
+		return value
+	}
+}
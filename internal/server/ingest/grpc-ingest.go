@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats.go/jetstream"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcIngestServer accepts log lines over gRPC for clients that can't reach
+// NATS and publishes them to the same JetStream subjects NATS clients use, so
+// they are consumed by the IngestService like any other line.
+type GrpcIngestServer struct {
+	natsConn *natsconn.NatsConnection
+	verifier *serverauth.CredentialVerifier
+}
+
+var _ rpc.LogIngestServer = &GrpcIngestServer{}
+
+func NewGrpcIngestServer(natsConn *natsconn.NatsConnection, verifier *serverauth.CredentialVerifier) *GrpcIngestServer {
+	if natsConn == nil {
+		panic("No natsConn")
+	}
+	if verifier == nil {
+		panic("No credential verifier")
+	}
+
+	return &GrpcIngestServer{natsConn: natsConn, verifier: verifier}
+}
+
+// Push authenticates the stream with the client's NATS credentials, then
+// publishes every received batch and acks it once JetStream stored it.
+func (g *GrpcIngestServer) Push(stream grpc.BidiStreamingServer[rpc.LogBatch, rpc.PushAck]) error {
+	ctx := stream.Context()
+
+	claims, subject, err := g.authenticate(ctx)
+	if err != nil {
+		slog.Warn("Rejected gRPC ingest stream", "err", err)
+		return err
+	}
+	slog.Debug("gRPC ingest stream opened", "subject", subject, "user", claims.Subject)
+
+	// Batch ids are only unique within a stream, the prefix keeps message ids
+	// of different streams apart
+	msgIdPrefix := rand.Text()
+
+	lastRevokedCheck := time.Now()
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if time.Since(lastRevokedCheck) > serverauth.RevokedCheckInterval {
+			if err := g.verifier.CheckRevoked(ctx, claims.Subject); err != nil {
+				return status.Error(codes.Unauthenticated, err.Error())
+			}
+			lastRevokedCheck = time.Now()
+		}
+
+		batchSubject := subject
+		if batch.Topic != "" {
+			if !serverauth.CanPublish(claims, batch.Topic) {
+				return status.Error(codes.PermissionDenied, fmt.Sprintf("%s: %s", serverauth.ErrPublishNotAllowed, batch.Topic))
+			}
+			batchSubject = batch.Topic
+		}
+
+		ack := rpc.PushAck{Id: batch.Id}
+		if err := g.publish(ctx, batchSubject, fmt.Sprintf("%s-%d", msgIdPrefix, batch.Id), batch.Lines); err != nil {
+			slog.Error("Failed to publish gRPC batch", "subject", batchSubject, "err", err)
+			ack.Error = err.Error()
+		}
+		if err := stream.Send(&ack); err != nil {
+			return err
+		}
+	}
+}
+
+func (g *GrpcIngestServer) authenticate(ctx context.Context) (*jwt.UserClaims, string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, "", status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	claims, err := g.verifier.Verify(ctx,
+		firstValue(md, rpc.MetadataJWT),
+		firstValue(md, rpc.MetadataNonce),
+		firstValue(md, rpc.MetadataSignature))
+	if err != nil {
+		return nil, "", status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	subject := firstValue(md, rpc.MetadataTopic)
+	if !serverauth.CanPublish(claims, subject) {
+		return nil, "", status.Error(codes.PermissionDenied, fmt.Sprintf("%s: %s", serverauth.ErrPublishNotAllowed, subject))
+	}
+
+	return claims, subject, nil
+}
+
+// publish stores the batch's lines in JetStream. Every line gets a message id,
+// so when a partially published batch is resent the server drops the lines it
+// already stored instead of duplicating them.
+func (g *GrpcIngestServer) publish(ctx context.Context, subject string, batchId string, lines []rpc.LogLine) error {
+	acks := make([]jetstream.PubAckFuture, 0, len(lines))
+	for i, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Errorf("failed to marshal log line: %w", err)
+		}
+		ack, err := g.natsConn.JetStream.PublishAsync(subject, data, jetstream.WithMsgID(fmt.Sprintf("%s-%d", batchId, i)))
+		if err != nil {
+			return err
+		}
+		acks = append(acks, ack)
+	}
+
+	return waitForAcks(ctx, acks)
+}
+
+// waitForAcks returns once JetStream stored every published message, or with
+// the first error.
+func waitForAcks(ctx context.Context, acks []jetstream.PubAckFuture) error {
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -3,82 +3,304 @@ package ingest
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"log/slog"
 
+	"github.com/markojerkic/svarog/internal/lib/metrics"
 	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/lib/projects"
 	"github.com/markojerkic/svarog/internal/rpc"
 	"github.com/markojerkic/svarog/internal/server/db"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-type IngestService struct {
-	ingestCh   chan db.LogLineWithHost
-	natsConn   *natsconn.NatsConnection
+type streamConsumer struct {
+	consumer   jetstream.Consumer
 	consumeCtx jetstream.ConsumeContext
+	// identity is the stream the consumer was created on, see streamIdentity
+	identity string
+}
+
+// streamIdentity names a stream together with its creation time. Stream
+// sequences start again at 1 when a stream is recreated, so stored lines are
+// deduplicated by the identity rather than the stream name.
+func streamIdentity(info *jetstream.StreamInfo) string {
+	return fmt.Sprintf("%s@%d", info.Config.Name, info.Created.UnixNano())
 }
 
-func NewIngestService(ingestCh chan db.LogLineWithHost, natsConn *natsconn.NatsConnection) *IngestService {
+type IngestService struct {
+	ingestCh          chan db.LogLineWithHost
+	natsConn          *natsconn.NatsConnection
+	pipelineProcessor *pipelines.Processor
+	clientRegistrar   *ClientRegistrar
+
+	mutex     sync.Mutex
+	consumers map[string]streamConsumer
+	running   bool
+	draining  bool
+}
+
+// NewIngestService consumes the log streams. pipelineProcessor and
+// clientRegistrar are optional, without a registrar lines of unknown clients
+// are stored without registering the client.
+func NewIngestService(ingestCh chan db.LogLineWithHost, natsConn *natsconn.NatsConnection, pipelineProcessor *pipelines.Processor, clientRegistrar *ClientRegistrar) *IngestService {
 	return &IngestService{
-		ingestCh: ingestCh,
-		natsConn: natsConn,
+		ingestCh:          ingestCh,
+		natsConn:          natsConn,
+		pipelineProcessor: pipelineProcessor,
+		clientRegistrar:   clientRegistrar,
+		consumers:         map[string]streamConsumer{},
 	}
 }
 
+// streamSyncInterval is how often new project streams are picked up.
+const streamSyncInterval = 10 * time.Second
+
 func (i *IngestService) Run(ctx context.Context) error {
-	consumer, err := i.natsConn.JetStream.CreateOrUpdateConsumer(ctx, "LOGS", jetstream.ConsumerConfig{
+	if err := i.syncConsumers(ctx); err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	i.running = true
+	i.mutex.Unlock()
+
+	ticker := time.NewTicker(streamSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Ingest service shutting down")
+			return nil
+		case <-ticker.C:
+			if err := i.syncConsumers(ctx); err != nil {
+				slog.Error("Failed to sync stream consumers", "err", err)
+			}
+		}
+	}
+}
+
+// syncConsumers starts consuming every log stream that isn't consumed yet.
+func (i *IngestService) syncConsumers(ctx context.Context) error {
+	streams, err := i.natsConn.LogStreamNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, stream := range streams {
+		if i.draining {
+			return nil
+		}
+		info, err := i.natsConn.JetStream.Stream(ctx, stream)
+		if err != nil {
+			return fmt.Errorf("failed to get stream %s: %w", stream, err)
+		}
+		identity := streamIdentity(info.CachedInfo())
+
+		if existing, ok := i.consumers[stream]; ok {
+			if existing.identity == identity {
+				continue
+			}
+			// The stream was deleted and created again, its consumer went with it
+			slog.Warn("Stream was recreated, consuming it again", "stream", stream)
+			existing.consumeCtx.Stop()
+			delete(i.consumers, stream)
+		}
+
+		consumer, err := i.consume(ctx, stream, identity)
+		if err != nil {
+			return fmt.Errorf("failed to consume stream %s: %w", stream, err)
+		}
+		i.consumers[stream] = consumer
+		slog.Info("Consuming stream", "stream", stream)
+	}
+
+	return nil
+}
+
+func (i *IngestService) consume(ctx context.Context, stream string, identity string) (streamConsumer, error) {
+	consumer, err := i.natsConn.JetStream.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
 		Durable:       "log-processor",
 		FilterSubject: "logs.>",
 		AckPolicy:     jetstream.AckExplicitPolicy,
 	})
-
 	if err != nil {
-		return err
+		return streamConsumer{}, err
 	}
 
 	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
-		var logLine rpc.LogLine
-		if err := json.Unmarshal(msg.Data(), &logLine); err != nil {
-			slog.Error("Failed to unmarshal log line", "err", err)
-			msg.Nak()
+		line, keep, err := i.toLogLine(ctx, msg, identity)
+		if err != nil {
+			slog.Error("Failed to process log line", "err", err)
+			if errors.Is(err, ErrInvalidLogLine) {
+				msg.Term()
+			} else {
+				msg.Nak()
+			}
 			return
 		}
 
-		if err := logLine.Validate(); err != nil {
-			slog.Error("Invalid log line", "err", err)
-			msg.Term()
-			return
+		metrics.IngestedLines.WithLabelValues(metrics.LineLabels(line.ProjectId, line.ClientId)).Inc()
+		if keep {
+			i.ingestCh <- line
+		} else {
+			metrics.DroppedLines.WithLabelValues(metrics.LineLabels(line.ProjectId, line.ClientId)).Inc()
+		}
+		msg.Ack()
+	}, jetstream.PullMaxMessages(100))
+	if err != nil {
+		return streamConsumer{}, err
+	}
+
+	return streamConsumer{consumer: consumer, consumeCtx: consumeCtx, identity: identity}, nil
+}
+
+var ErrInvalidLogLine = errors.New("invalid log line")
+
+// toLogLine decodes a JetStream message of the stream with the identity and
+// runs it through the parsing pipelines. keep is false when a pipeline dropped
+// the line or the client isn't approved.
+func (i *IngestService) toLogLine(ctx context.Context, msg jetstream.Msg, identity string) (db.LogLineWithHost, bool, error) {
+	var logLine rpc.LogLine
+	if err := json.Unmarshal(msg.Data(), &logLine); err != nil {
+		return db.LogLineWithHost{}, false, fmt.Errorf("failed to unmarshal log line: %w", err)
+	}
+
+	if err := logLine.Validate(); err != nil {
+		return db.LogLineWithHost{}, false, errors.Join(ErrInvalidLogLine, err)
+	}
+
+	subject := msg.Subject()
+	parts := strings.Split(subject, ".")
+	projectId := parts[len(parts)-2]
+	clientId := parts[len(parts)-1]
+
+	line := db.LogLineWithHost{
+		LogLine:   &logLine,
+		ClientId:  clientId,
+		ProjectId: projectId,
+		Hostname:  logLine.InstanceId,
+		Level:     logLine.Level,
+		Fields:    logLine.Fields,
+		TraceId:   logLine.TraceId,
+		SpanId:    logLine.SpanId,
+	}
+
+	if metadata, err := msg.Metadata(); err == nil {
+		line.Stream = identity
+		line.StreamSequence = metadata.Sequence.Stream
+	}
+
+	if i.clientRegistrar != nil {
+		status, err := i.clientRegistrar.Status(ctx, projectId, clientId)
+		if err != nil {
+			if err.Error() == projects.ErrProjectNotFound {
+				return line, false, nil
+			}
+			return line, false, fmt.Errorf("failed to register client: %w", err)
+		}
+		if status != projects.ClientApproved {
+			return line, false, nil
+		}
+	}
+
+	if i.pipelineProcessor != nil {
+		result := i.pipelineProcessor.Process(ctx, projectId, clientId, logLine.Message)
+		if result.Dropped {
+			return line, false, nil
+		}
+		logLine.Message = result.Message
+		if result.Level != "" {
+			line.Level = result.Level
+		}
+		if len(result.Fields) > 0 {
+			if line.Fields == nil {
+				line.Fields = map[string]any{}
+			}
+			for key, value := range result.Fields {
+				line.Fields[key] = value
+			}
 		}
+	}
 
-		subject := msg.Subject()
-		parts := strings.Split(subject, ".")
-		projectId := parts[len(parts)-2]
-		clientId := parts[len(parts)-1]
+	return line, true, nil
+}
 
-		i.ingestCh <- db.LogLineWithHost{
-			LogLine:   &logLine,
-			ClientId:  clientId,
-			ProjectId: projectId,
-			Hostname:  logLine.InstanceId,
+// PendingMessages returns the number of stream messages not yet delivered to the consumers.
+func (i *IngestService) PendingMessages(ctx context.Context) (uint64, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	var pending uint64
+	for _, c := range i.consumers {
+		info, err := c.consumer.Info(ctx)
+		if err != nil {
+			return 0, err
 		}
-		msg.Ack()
-	}, jetstream.PullMaxMessages(100))
+		pending += info.NumPending
+	}
 
-	if err != nil {
-		return err
+	return pending, nil
+}
+
+// Healthy reports whether the ingest consumers are running and reachable.
+func (i *IngestService) Healthy(ctx context.Context) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if !i.running {
+		return errors.New("ingest consumer is not running")
+	}
+
+	for stream, c := range i.consumers {
+		if _, err := c.consumer.Info(ctx); err != nil {
+			return fmt.Errorf("stream %s: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+// Drain stops fetching new messages and waits until the already buffered
+// ones have been handed over to the ingest channel.
+func (i *IngestService) Drain(ctx context.Context) error {
+	i.mutex.Lock()
+	i.draining = true
+	consumers := make([]streamConsumer, 0, len(i.consumers))
+	for _, c := range i.consumers {
+		consumers = append(consumers, c)
 	}
+	i.mutex.Unlock()
 
-	i.consumeCtx = consumeCtx
+	for _, c := range consumers {
+		c.consumeCtx.Drain()
+	}
 
-	// Block until context is cancelled
-	<-ctx.Done()
-	slog.Info("Ingest service shutting down")
+	for _, c := range consumers {
+		select {
+		case <-c.consumeCtx.Closed():
+		case <-ctx.Done():
+			i.Stop()
+			return ctx.Err()
+		}
+	}
 	return nil
 }
 
 func (i *IngestService) Stop() {
-	if i.consumeCtx != nil {
-		i.consumeCtx.Stop()
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for _, c := range i.consumers {
+		c.consumeCtx.Stop()
 	}
 }
@@ -0,0 +1,373 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Stream labels picking the client, instance and level of Loki entries. The
+// client is only taken from the labels when the token is valid for every
+// client of the project.
+var (
+	lokiClientLabels   = []string{"svarog_client", "service_name", "app", "job"}
+	lokiInstanceLabels = []string{"instance", "host", "hostname", "pod", "container"}
+	lokiLevelLabels    = []string{"level", "detected_level", "severity"}
+)
+
+// LokiPushRequest is the body of a Loki push, decoded from either the
+// snappy compressed protobuf or the JSON format.
+type LokiPushRequest struct {
+	Streams []LokiStream
+}
+
+type LokiStream struct {
+	Labels  map[string]string
+	Entries []LokiEntry
+}
+
+type LokiEntry struct {
+	Timestamp time.Time
+	Line      string
+	// Metadata is the structured metadata of the entry.
+	Metadata map[string]string
+}
+
+// DecodeLokiPush decodes a push body, protobuf bodies are snappy compressed
+// as sent by Promtail and the Grafana agent. Bodies decompressing to more than
+// maxSize bytes are rejected before they are decompressed.
+func DecodeLokiPush(body []byte, useJSON bool, maxSize int) (LokiPushRequest, error) {
+	if useJSON {
+		return decodeLokiJSON(body)
+	}
+
+	// The decoded length is taken from the body's header, snappy allocates it
+	// before checking the body holds that much
+	decodedLen, err := snappy.DecodedLen(body)
+	if err != nil {
+		return LokiPushRequest{}, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	if decodedLen > maxSize {
+		return LokiPushRequest{}, errors.New("Body is too large")
+	}
+
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		return LokiPushRequest{}, fmt.Errorf("invalid snappy body: %w", err)
+	}
+	return decodeLokiProto(decoded)
+}
+
+// LokiPushToLogLines converts the streams of a push into log lines of
+// projectId. clientId "*" lets the stream labels pick the client. Labels
+// that don't pick the client, instance or level are stored as fields.
+func LokiPushToLogLines(request LokiPushRequest, projectId string, clientId string) []db.LogLineWithHost {
+	lines := []db.LogLineWithHost{}
+	sequence := 0
+
+	for _, stream := range request.Streams {
+		extraLabels := map[string]string{}
+		for key, value := range stream.Labels {
+			extraLabels[key] = value
+		}
+
+		client := clientId
+		if client == "*" || client == "" {
+			client = takeLabel(extraLabels, lokiClientLabels, "loki")
+		}
+		instance := takeLabel(extraLabels, lokiInstanceLabels, client)
+		level := pipelines.NormalizeLevel(takeLabel(extraLabels, lokiLevelLabels, ""))
+
+		for _, entry := range stream.Entries {
+			fields := map[string]any{}
+			for key, value := range extraLabels {
+				fields[key] = value
+			}
+
+			line := db.LogLineWithHost{
+				LogLine: &rpc.LogLine{
+					Message:    entry.Line,
+					Timestamp:  entry.Timestamp,
+					Sequence:   sequence,
+					InstanceId: instance,
+				},
+				ProjectId: projectId,
+				ClientId:  client,
+				Hostname:  instance,
+				Level:     level,
+			}
+			for key, value := range entry.Metadata {
+				switch key {
+				case "trace_id", "traceID":
+					line.TraceId = value
+				case "span_id", "spanID":
+					line.SpanId = value
+				default:
+					fields[key] = value
+				}
+			}
+			if len(fields) > 0 {
+				line.Fields = fields
+			}
+
+			lines = append(lines, line)
+			sequence++
+		}
+	}
+
+	return lines
+}
+
+// takeLabel returns the first set label of keys and removes it from labels.
+func takeLabel(labels map[string]string, keys []string, fallback string) string {
+	for _, key := range keys {
+		if value := labels[key]; value != "" {
+			delete(labels, key)
+			return value
+		}
+	}
+	return fallback
+}
+
+// ParseLokiLabels parses the label set of a protobuf stream, for example
+// {job="varlogs", host="web-1"}.
+func ParseLokiLabels(value string) (map[string]string, error) {
+	labels := map[string]string{}
+	rest := strings.TrimSpace(value)
+	if !strings.HasPrefix(rest, "{") || !strings.HasSuffix(rest, "}") {
+		return nil, fmt.Errorf("invalid labels %q", value)
+	}
+	rest = strings.TrimSpace(rest[1 : len(rest)-1])
+
+	for rest != "" {
+		name, after, ok := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid labels %q", value)
+		}
+
+		after = strings.TrimSpace(after)
+		quoted, err := strconv.QuotedPrefix(after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of label %s: %w", name, err)
+		}
+		labelValue, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of label %s: %w", name, err)
+		}
+		labels[name] = labelValue
+
+		rest = strings.TrimSpace(after[len(quoted):])
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, ","))
+	}
+
+	return labels, nil
+}
+
+type lokiJSONPush struct {
+	Streams []struct {
+		Stream map[string]string   `json:"stream"`
+		Values [][]json.RawMessage `json:"values"`
+	} `json:"streams"`
+}
+
+func decodeLokiJSON(body []byte) (LokiPushRequest, error) {
+	var push lokiJSONPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return LokiPushRequest{}, fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	request := LokiPushRequest{}
+	for _, jsonStream := range push.Streams {
+		stream := LokiStream{Labels: jsonStream.Stream}
+		if stream.Labels == nil {
+			stream.Labels = map[string]string{}
+		}
+
+		for _, value := range jsonStream.Values {
+			if len(value) < 2 {
+				return LokiPushRequest{}, errors.New("entries must be [timestamp, line]")
+			}
+
+			var timestamp, line string
+			if err := json.Unmarshal(value[0], &timestamp); err != nil {
+				return LokiPushRequest{}, fmt.Errorf("invalid entry timestamp: %w", err)
+			}
+			nanos, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return LokiPushRequest{}, fmt.Errorf("invalid entry timestamp: %w", err)
+			}
+			if err := json.Unmarshal(value[1], &line); err != nil {
+				return LokiPushRequest{}, fmt.Errorf("invalid entry line: %w", err)
+			}
+
+			entry := LokiEntry{Timestamp: time.Unix(0, nanos), Line: line}
+			if len(value) > 2 {
+				if err := json.Unmarshal(value[2], &entry.Metadata); err != nil {
+					return LokiPushRequest{}, fmt.Errorf("invalid entry metadata: %w", err)
+				}
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+
+		request.Streams = append(request.Streams, stream)
+	}
+
+	return request, nil
+}
+
+// decodeLokiProto decodes logproto.PushRequest. The messages are small and
+// stable, so they are read field by field instead of depending on Loki.
+func decodeLokiProto(body []byte) (LokiPushRequest, error) {
+	request := LokiPushRequest{}
+	err := readProtoFields(body, func(number protowire.Number, value []byte) error {
+		if number != 1 {
+			return nil
+		}
+		stream, err := decodeLokiStream(value)
+		if err != nil {
+			return err
+		}
+		request.Streams = append(request.Streams, stream)
+		return nil
+	})
+	return request, err
+}
+
+func decodeLokiStream(body []byte) (LokiStream, error) {
+	stream := LokiStream{}
+	err := readProtoFields(body, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			labels, err := ParseLokiLabels(string(value))
+			if err != nil {
+				return err
+			}
+			stream.Labels = labels
+		case 2:
+			entry, err := decodeLokiEntry(value)
+			if err != nil {
+				return err
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+		return nil
+	})
+	if stream.Labels == nil {
+		stream.Labels = map[string]string{}
+	}
+	return stream, err
+}
+
+func decodeLokiEntry(body []byte) (LokiEntry, error) {
+	entry := LokiEntry{}
+	err := readProtoFields(body, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			timestamp, err := decodeProtoTimestamp(value)
+			if err != nil {
+				return err
+			}
+			entry.Timestamp = timestamp
+		case 2:
+			entry.Line = string(value)
+		case 3:
+			var name, labelValue string
+			err := readProtoFields(value, func(number protowire.Number, value []byte) error {
+				switch number {
+				case 1:
+					name = string(value)
+				case 2:
+					labelValue = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if entry.Metadata == nil {
+				entry.Metadata = map[string]string{}
+			}
+			entry.Metadata[name] = labelValue
+		}
+		return nil
+	})
+	return entry, err
+}
+
+// decodeProtoTimestamp decodes google.protobuf.Timestamp, seconds and nanos
+// are varints instead of length delimited fields.
+func decodeProtoTimestamp(body []byte) (time.Time, error) {
+	var seconds, nanos int64
+	for len(body) > 0 {
+		number, wireType, n := protowire.ConsumeTag(body)
+		if n < 0 {
+			return time.Time{}, protowire.ParseError(n)
+		}
+		body = body[n:]
+
+		if wireType != protowire.VarintType {
+			n = protowire.ConsumeFieldValue(number, wireType, body)
+			if n < 0 {
+				return time.Time{}, protowire.ParseError(n)
+			}
+			body = body[n:]
+			continue
+		}
+
+		value, n := protowire.ConsumeVarint(body)
+		if n < 0 {
+			return time.Time{}, protowire.ParseError(n)
+		}
+		body = body[n:]
+
+		switch number {
+		case 1:
+			seconds = int64(value)
+		case 2:
+			nanos = int64(int32(value))
+		}
+	}
+	return time.Unix(seconds, nanos), nil
+}
+
+// readProtoFields calls onField with the length delimited fields of a
+// message, fields of other wire types are skipped.
+func readProtoFields(body []byte, onField func(number protowire.Number, value []byte) error) error {
+	for len(body) > 0 {
+		number, wireType, n := protowire.ConsumeTag(body)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		body = body[n:]
+
+		if wireType != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(number, wireType, body)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			body = body[n:]
+			continue
+		}
+
+		value, n := protowire.ConsumeBytes(body)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		body = body[n:]
+
+		if err := onField(number, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/db"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// Resource attributes picking the client and instance of OTLP log records.
+// The client is only taken from the resource when the token is valid for
+// every client of the project.
+var (
+	otlpClientAttributes   = []string{"svarog.client.id", "service.name"}
+	otlpInstanceAttributes = []string{"service.instance.id", "host.name", "k8s.pod.name", "container.id"}
+)
+
+// OtlpToLogLines converts an OTLP export request into log lines of projectId.
+// clientId "*" lets the resource attributes pick the client. Attributes of the
+// resource and the record are stored as fields, with dots in their keys
+// replaced by underscores.
+func OtlpToLogLines(request *collogspb.ExportLogsServiceRequest, projectId string, clientId string) []db.LogLineWithHost {
+	lines := []db.LogLineWithHost{}
+	sequence := 0
+
+	for _, resourceLogs := range request.GetResourceLogs() {
+		resourceAttributes := resourceLogs.GetResource().GetAttributes()
+
+		client := clientId
+		if client == "*" || client == "" {
+			client = firstAttribute(resourceAttributes, otlpClientAttributes, "otel")
+		}
+		instance := firstAttribute(resourceAttributes, otlpInstanceAttributes, client)
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			for _, record := range scopeLogs.GetLogRecords() {
+				fields := map[string]any{}
+				addAttributes(fields, resourceAttributes)
+				addAttributes(fields, record.GetAttributes())
+				if scope := scopeLogs.GetScope().GetName(); scope != "" {
+					fields["otel_scope"] = scope
+				}
+
+				line := db.LogLineWithHost{
+					LogLine: &rpc.LogLine{
+						Message:    anyValueString(record.GetBody()),
+						Timestamp:  recordTime(record),
+						Sequence:   sequence,
+						InstanceId: instance,
+					},
+					ProjectId: projectId,
+					ClientId:  client,
+					Hostname:  instance,
+					Level:     severityLevel(record),
+					TraceId:   traceId(record.GetTraceId()),
+					SpanId:    traceId(record.GetSpanId()),
+				}
+				if len(fields) > 0 {
+					line.Fields = fields
+				}
+
+				lines = append(lines, line)
+				sequence++
+			}
+		}
+	}
+
+	return lines
+}
+
+func recordTime(record *logspb.LogRecord) time.Time {
+	if record.GetTimeUnixNano() != 0 {
+		return time.Unix(0, int64(record.GetTimeUnixNano()))
+	}
+	if record.GetObservedTimeUnixNano() != 0 {
+		return time.Unix(0, int64(record.GetObservedTimeUnixNano()))
+	}
+	return time.Now()
+}
+
+// severityLevel prefers the severity text and falls back to the ranges of
+// the OTLP severity numbers.
+func severityLevel(record *logspb.LogRecord) string {
+	if level := pipelines.NormalizeLevel(record.GetSeverityText()); level != "" {
+		return level
+	}
+
+	switch number := record.GetSeverityNumber(); {
+	case number == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+		return ""
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE4:
+		return "trace"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
+		return "debug"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_INFO4:
+		return "info"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_WARN4:
+		return "warn"
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4:
+		return "error"
+	default:
+		return "fatal"
+	}
+}
+
+// traceId hex encodes trace and span ids, empty and all zero ids are unset.
+func traceId(id []byte) string {
+	for _, b := range id {
+		if b != 0 {
+			return hex.EncodeToString(id)
+		}
+	}
+	return ""
+}
+
+func firstAttribute(attributes []*commonpb.KeyValue, keys []string, fallback string) string {
+	for _, key := range keys {
+		for _, attribute := range attributes {
+			if attribute.GetKey() == key {
+				if value := anyValueString(attribute.GetValue()); value != "" {
+					return value
+				}
+			}
+		}
+	}
+	return fallback
+}
+
+func addAttributes(fields map[string]any, attributes []*commonpb.KeyValue) {
+	for _, attribute := range attributes {
+		fields[strings.ReplaceAll(attribute.GetKey(), ".", "_")] = anyValue(attribute.GetValue())
+	}
+}
+
+func anyValue(value *commonpb.AnyValue) any {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]any, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, anyValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := map[string]any{}
+		addAttributes(values, v.KvlistValue.GetValues())
+		return values
+	default:
+		return nil
+	}
+}
+
+// anyValueString is the message of a log body, structured bodies are stored as JSON.
+func anyValueString(value *commonpb.AnyValue) string {
+	switch v := anyValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any, map[string]any:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,79 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/markojerkic/svarog/internal/lib/metrics"
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Receiver publishes log lines received by the HTTP ingest endpoints and the
+// syslog server to the same JetStream subjects NATS clients use, so they are
+// stored, parsed by the pipelines and replayed like any other line.
+type Receiver struct {
+	natsConn        *natsconn.NatsConnection
+	clientRegistrar *ClientRegistrar
+}
+
+// NewReceiver publishes lines with natsConn, clientRegistrar is optional.
+func NewReceiver(natsConn *natsconn.NatsConnection, clientRegistrar *ClientRegistrar) *Receiver {
+	return &Receiver{
+		natsConn:        natsConn,
+		clientRegistrar: clientRegistrar,
+	}
+}
+
+// Receive publishes the lines and returns how many were accepted once
+// JetStream stored all of them. Lines from clients that aren't approved are
+// dropped and not counted.
+func (r *Receiver) Receive(ctx context.Context, lines []db.LogLineWithHost) (int, error) {
+	statuses := map[string]projects.ClientStatus{}
+	acks := make([]jetstream.PubAckFuture, 0, len(lines))
+
+	for _, line := range lines {
+		if r.clientRegistrar != nil {
+			key := line.ProjectId + "." + line.ClientId
+			status, ok := statuses[key]
+			if !ok {
+				var err error
+				status, err = r.clientRegistrar.Status(ctx, line.ProjectId, line.ClientId)
+				if err != nil {
+					return 0, fmt.Errorf("failed to register client: %w", err)
+				}
+				statuses[key] = status
+			}
+			if status != projects.ClientApproved {
+				// These lines never reach the ingest consumer, which counts the others
+				metrics.IngestedLines.WithLabelValues(metrics.LineLabels(line.ProjectId, line.ClientId)).Inc()
+				metrics.DroppedLines.WithLabelValues(metrics.LineLabels(line.ProjectId, line.ClientId)).Inc()
+				continue
+			}
+		}
+
+		logLine := *line.LogLine
+		logLine.Level = line.Level
+		logLine.Fields = line.Fields
+		logLine.TraceId = line.TraceId
+		logLine.SpanId = line.SpanId
+		data, err := json.Marshal(logLine)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal log line: %w", err)
+		}
+
+		ack, err := r.natsConn.JetStream.PublishAsync(fmt.Sprintf("logs.%s.%s", line.ProjectId, line.ClientId), data)
+		if err != nil {
+			return 0, err
+		}
+		acks = append(acks, ack)
+	}
+
+	if err := waitForAcks(ctx, acks); err != nil {
+		return 0, err
+	}
+	return len(acks), nil
+}
@@ -0,0 +1,410 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReplayStatus string
+
+const (
+	ReplayRunning   ReplayStatus = "running"
+	ReplayCompleted ReplayStatus = "completed"
+	ReplayFailed    ReplayStatus = "failed"
+	ReplayCancelled ReplayStatus = "cancelled"
+)
+
+const ErrReplayNotFound = "replay job not found"
+
+const replayBatchSize = 500
+
+// replayJobsLoaded is how many of the latest jobs are loaded on start.
+const replayJobsLoaded = 100
+
+// ReplayRequest selects the stream messages to reprocess. An empty ClientId
+// replays every client of the project. When StartSequence is set it takes
+// precedence over StartTime, and a zero StartTime replays the whole stream.
+type ReplayRequest struct {
+	ProjectId     string    `json:"projectId" bson:"project_id"`
+	ClientId      string    `json:"clientId" bson:"client_id"`
+	StartTime     time.Time `json:"startTime" bson:"start_time"`
+	StartSequence uint64    `json:"startSequence" bson:"start_sequence"`
+}
+
+type ReplayJob struct {
+	ID         string        `json:"id" bson:"_id"`
+	Request    ReplayRequest `json:"request" bson:"request"`
+	Status     ReplayStatus  `json:"status" bson:"status"`
+	Total      uint64        `json:"total" bson:"total"`
+	Processed  uint64        `json:"processed" bson:"processed"`
+	Dropped    uint64        `json:"dropped" bson:"dropped"`
+	Failed     uint64        `json:"failed" bson:"failed"`
+	StartedAt  time.Time     `json:"startedAt" bson:"started_at"`
+	FinishedAt *time.Time    `json:"finishedAt,omitempty" bson:"finished_at,omitempty"`
+	Error      string        `json:"error,omitempty" bson:"error,omitempty"`
+	// Stream is the identity of the replayed stream and LastSequence the last
+	// message replayed, a job interrupted by a restart resumes after it
+	Stream       string `json:"stream" bson:"stream"`
+	LastSequence uint64 `json:"lastSequence" bson:"last_sequence"`
+}
+
+// Progress returns the share of processed messages in percent.
+func (j ReplayJob) Progress() int {
+	if j.Total == 0 {
+		if j.Status == ReplayCompleted {
+			return 100
+		}
+		return 0
+	}
+	return int(j.Processed * 100 / j.Total)
+}
+
+// ReplayService re-reads messages still retained in JetStream and feeds them
+// through the normal ingest path. Lines that are already stored are skipped
+// by the unique stream sequence index on the logs collection. Jobs are stored
+// in MongoDB, jobs still running when the server stops resume on start.
+type ReplayService struct {
+	ingestService *IngestService
+	collection    *mongo.Collection
+
+	mutex    sync.Mutex
+	jobs     map[string]*ReplayJob
+	cancels  map[string]context.CancelFunc
+	stopping bool
+	running  sync.WaitGroup
+}
+
+func NewReplayService(ingestService *IngestService, collection *mongo.Collection) *ReplayService {
+	return &ReplayService{
+		ingestService: ingestService,
+		collection:    collection,
+		jobs:          map[string]*ReplayJob{},
+		cancels:       map[string]context.CancelFunc{},
+	}
+}
+
+func (r *ReplayService) filterSubject(request ReplayRequest) string {
+	if request.ClientId == "" || request.ClientId == "*" {
+		return fmt.Sprintf("logs.%s.*", request.ProjectId)
+	}
+	return fmt.Sprintf("logs.%s.%s", request.ProjectId, request.ClientId)
+}
+
+// consumerConfig delivers the messages of the request, or the messages after
+// the last replayed one when the job is resumed.
+func (r *ReplayService) consumerConfig(job *ReplayJob) jetstream.OrderedConsumerConfig {
+	request := job.Request
+	config := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{r.filterSubject(request)},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	}
+	if job.LastSequence > 0 {
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = job.LastSequence + 1
+	} else if request.StartSequence > 0 {
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = request.StartSequence
+	} else if !request.StartTime.IsZero() {
+		config.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		config.OptStartTime = &request.StartTime
+	}
+	return config
+}
+
+// Start creates an ephemeral consumer for the request and replays it in the background.
+func (r *ReplayService) Start(ctx context.Context, request ReplayRequest) (ReplayJob, error) {
+	if request.ProjectId == "" {
+		return ReplayJob{}, errors.New("project id is required")
+	}
+
+	stream, err := r.ingestService.natsConn.JetStream.Stream(ctx, r.ingestService.natsConn.StreamName(request.ProjectId))
+	if err != nil {
+		return ReplayJob{}, err
+	}
+
+	job := &ReplayJob{
+		ID:        primitive.NewObjectID().Hex(),
+		Request:   request,
+		Status:    ReplayRunning,
+		StartedAt: time.Now(),
+		Stream:    streamIdentity(stream.CachedInfo()),
+	}
+	consumer, err := stream.OrderedConsumer(ctx, r.consumerConfig(job))
+	if err != nil {
+		return ReplayJob{}, err
+	}
+
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		return ReplayJob{}, err
+	}
+	job.Total = info.NumPending
+
+	r.mutex.Lock()
+	r.jobs[job.ID] = job
+	r.mutex.Unlock()
+	r.save(*job)
+
+	slog.Info("Starting replay", "job", job.ID, "subject", r.filterSubject(request), "total", job.Total)
+	r.launch(job, consumer)
+
+	return *job, nil
+}
+
+// Resume loads the latest jobs and continues the ones interrupted by a
+// restart. Jobs whose stream was recreated since fail, their sequence
+// numbers no longer point at the same messages.
+func (r *ReplayService) Resume(ctx context.Context) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetLimit(replayJobsLoaded))
+	if err != nil {
+		return err
+	}
+	var jobs []*ReplayJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	for _, job := range jobs {
+		r.jobs[job.ID] = job
+	}
+	r.mutex.Unlock()
+
+	for _, job := range jobs {
+		if job.Status != ReplayRunning {
+			continue
+		}
+
+		consumer, err := r.resumeConsumer(ctx, job)
+		if err != nil {
+			r.finish(job, err)
+			continue
+		}
+		info, err := consumer.Info(ctx)
+		if err != nil || info.NumPending == 0 {
+			r.finish(job, err)
+			continue
+		}
+		slog.Info("Resuming replay", "job", job.ID, "lastSequence", job.LastSequence)
+		r.launch(job, consumer)
+	}
+	return nil
+}
+
+func (r *ReplayService) resumeConsumer(ctx context.Context, job *ReplayJob) (jetstream.Consumer, error) {
+	stream, err := r.ingestService.natsConn.JetStream.Stream(ctx, r.ingestService.natsConn.StreamName(job.Request.ProjectId))
+	if err != nil {
+		return nil, err
+	}
+	if streamIdentity(stream.CachedInfo()) != job.Stream {
+		return nil, errors.New("stream was recreated since the replay started")
+	}
+	return stream.OrderedConsumer(ctx, r.consumerConfig(job))
+}
+
+func (r *ReplayService) launch(job *ReplayJob, consumer jetstream.Consumer) {
+	jobCtx, cancel := context.WithCancel(context.Background())
+	r.mutex.Lock()
+	if r.stopping {
+		// Left running, the job resumes on the next start
+		r.mutex.Unlock()
+		cancel()
+		r.finish(job, context.Canceled)
+		return
+	}
+	r.cancels[job.ID] = cancel
+	r.running.Add(1)
+	r.mutex.Unlock()
+
+	go func() {
+		defer r.running.Done()
+		r.run(jobCtx, job, consumer)
+	}()
+}
+
+func (r *ReplayService) run(ctx context.Context, job *ReplayJob, consumer jetstream.Consumer) {
+	r.finish(job, r.replay(ctx, job, consumer))
+}
+
+// finish records how the job ended. Jobs cancelled by a shutdown stay
+// running so they resume on the next start.
+func (r *ReplayService) finish(job *ReplayJob, err error) {
+	r.mutex.Lock()
+	delete(r.cancels, job.ID)
+
+	if errors.Is(err, context.Canceled) && r.stopping {
+		snapshot := *job
+		r.mutex.Unlock()
+		r.save(snapshot)
+		slog.Info("Replay interrupted by shutdown", "job", job.ID, "processed", job.Processed)
+		return
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.Status = ReplayCancelled
+	case err != nil:
+		job.Status = ReplayFailed
+		job.Error = err.Error()
+	default:
+		job.Status = ReplayCompleted
+	}
+	snapshot := *job
+	r.mutex.Unlock()
+	r.save(snapshot)
+
+	slog.Info("Replay finished", "job", job.ID, "status", job.Status, "processed", job.Processed, "failed", job.Failed)
+}
+
+// save stores the job. Failing to store it doesn't stop the replay, it only
+// loses progress on a restart.
+func (r *ReplayService) save(job ReplayJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": job.ID}, job, options.Replace().SetUpsert(true))
+	if err != nil {
+		slog.Error("Failed to save replay job", "job", job.ID, "error", err)
+	}
+}
+
+func (r *ReplayService) replay(ctx context.Context, job *ReplayJob, consumer jetstream.Consumer) error {
+	if job.Total == 0 {
+		return nil
+	}
+
+	for {
+		batch, err := consumer.Fetch(replayBatchSize, jetstream.FetchMaxWait(2*time.Second))
+		if err != nil {
+			return err
+		}
+
+		for msg := range batch.Messages() {
+			line, keep, err := r.ingestService.toLogLine(ctx, msg, job.Stream)
+
+			if err == nil && keep {
+				// A select picks randomly among ready cases, so a cancelled
+				// job could still send without this check
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				select {
+				case r.ingestService.ingestCh <- line:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			metadata, metadataErr := msg.Metadata()
+
+			r.mutex.Lock()
+			job.Processed++
+			if err != nil {
+				job.Failed++
+			} else if !keep {
+				job.Dropped++
+			}
+			if metadataErr == nil {
+				job.LastSequence = metadata.Sequence.Stream
+			}
+			r.mutex.Unlock()
+
+			if metadataErr == nil && metadata.NumPending == 0 {
+				return nil
+			}
+		}
+
+		r.mutex.Lock()
+		snapshot := *job
+		r.mutex.Unlock()
+		r.save(snapshot)
+
+		if err := batch.Error(); err != nil && !errors.Is(err, jetstream.ErrNoMessages) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Get returns a snapshot of the job.
+func (r *ReplayService) Get(id string) (ReplayJob, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return ReplayJob{}, errors.New(ErrReplayNotFound)
+	}
+	return *job, nil
+}
+
+// List returns snapshots of the latest jobs, newest first.
+func (r *ReplayService) List() []ReplayJob {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	jobs := make([]ReplayJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, *job)
+	}
+	slices.SortFunc(jobs, func(a, b ReplayJob) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+
+	return jobs
+}
+
+func (r *ReplayService) Cancel(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.jobs[id]; !ok {
+		return errors.New(ErrReplayNotFound)
+	}
+	if cancel, ok := r.cancels[id]; ok {
+		cancel()
+	}
+	return nil
+}
+
+// CancelAll stops every running replay and waits for them to return, or for
+// ctx to be done. It is called on shutdown before the ingest channel stops
+// being consumed, the stopped jobs resume on the next start.
+func (r *ReplayService) CancelAll(ctx context.Context) error {
+	r.mutex.Lock()
+	r.stopping = true
+	for _, cancel := range r.cancels {
+		cancel()
+	}
+	r.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,269 @@
+package ingest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log/slog"
+
+	"github.com/markojerkic/svarog/internal/lib/metrics"
+	"github.com/markojerkic/svarog/internal/lib/syslog"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/db"
+)
+
+// syslogIdleTimeout closes TCP connections that stopped sending.
+const syslogIdleTimeout = 10 * time.Minute
+
+// SyslogServer receives syslog over UDP and TCP (optionally TLS) for devices
+// that can't run a client. Messages are routed to a project and client by
+// the rules and stored through the Receiver.
+type SyslogServer struct {
+	rules    *syslog.Rules
+	receiver *Receiver
+	sequence atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex sync.Mutex
+	// stopping is set by Shutdown, connections stop reading after the
+	// message they are handling
+	stopping    bool
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	connections map[net.Conn]struct{}
+	wg          sync.WaitGroup
+}
+
+func NewSyslogServer(rules *syslog.Rules, receiver *Receiver) *SyslogServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SyslogServer{
+		rules:       rules,
+		receiver:    receiver,
+		ctx:         ctx,
+		cancel:      cancel,
+		connections: map[net.Conn]struct{}{},
+	}
+}
+
+// ListenUDP receives one message per datagram on addr.
+func (s *SyslogServer) ListenUDP(addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for syslog over UDP: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.udpConn = conn
+	s.mutex.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buffer := make([]byte, syslog.MaxMessageSize)
+		for {
+			n, sender, err := conn.ReadFrom(buffer)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Error("Error reading syslog datagram", "error", err)
+				}
+				return
+			}
+			s.handle(buffer[:n], senderIP(sender))
+		}
+	}()
+
+	return conn.LocalAddr(), nil
+}
+
+// ListenTCP receives octet counted or newline framed messages on addr, over
+// TLS when tlsConfig is set.
+func (s *SyslogServer) ListenTCP(addr string, tlsConfig *tls.Config) (net.Addr, error) {
+	var listener net.Listener
+	var err error
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", addr, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for syslog over TCP: %w", err)
+	}
+
+	s.mutex.Lock()
+	s.tcpListener = listener
+	s.mutex.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Error("Error accepting syslog connection", "error", err)
+				}
+				return
+			}
+
+			s.mutex.Lock()
+			s.connections[conn] = struct{}{}
+			s.mutex.Unlock()
+
+			s.wg.Add(1)
+			go s.serveConn(conn)
+		}
+	}()
+
+	return listener.Addr(), nil
+}
+
+func (s *SyslogServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.connections, conn)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	sender := senderIP(conn.RemoteAddr())
+	scanner := syslog.NewFrameScanner(conn)
+	for s.extendReadDeadline(conn) && scanner.Scan() {
+		s.handle(scanner.Bytes(), sender)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		slog.Debug("Syslog connection closed", "sender", sender, "error", err)
+	}
+}
+
+// extendReadDeadline gives conn syslogIdleTimeout to send the next message,
+// unless the server is shutting down. It holds the mutex so it can't
+// overwrite the deadline Shutdown sets.
+func (s *SyslogServer) extendReadDeadline(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+	return true
+}
+
+func (s *SyslogServer) handle(data []byte, sender net.IP) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return
+	}
+
+	message, err := syslog.Parse(data, time.Now())
+	if err != nil {
+		metrics.UnroutedSyslogMessages.Inc()
+		slog.Debug("Invalid syslog message", "sender", sender, "error", err)
+		return
+	}
+
+	projectId, clientId, ok := s.rules.Route(message, sender)
+	if !ok {
+		metrics.UnroutedSyslogMessages.Inc()
+		return
+	}
+
+	line := SyslogToLogLine(message, projectId, clientId, sender)
+	line.Sequence = int(s.sequence.Add(1))
+	if _, err := s.receiver.Receive(s.ctx, []db.LogLineWithHost{line}); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("Error receiving syslog message", "project", projectId, "client", clientId, "error", err)
+	}
+}
+
+// SyslogToLogLine converts a routed message. The hostname is the instance,
+// messages without one use the sender's address. Structured data is stored
+// as "<SD-ID>_<param>" fields.
+func SyslogToLogLine(message syslog.Message, projectId string, clientId string, sender net.IP) db.LogLineWithHost {
+	instance := message.Hostname
+	if instance == "" && sender != nil {
+		instance = sender.String()
+	}
+
+	fields := map[string]any{
+		"syslog_facility": message.Facility,
+	}
+	if message.AppName != "" {
+		fields["syslog_app"] = message.AppName
+	}
+	if message.ProcId != "" {
+		fields["syslog_proc_id"] = message.ProcId
+	}
+	if message.MsgId != "" {
+		fields["syslog_msg_id"] = message.MsgId
+	}
+	for id, params := range message.StructuredData {
+		for name, value := range params {
+			fields[strings.ReplaceAll(id+"_"+name, ".", "_")] = value
+		}
+	}
+
+	return db.LogLineWithHost{
+		LogLine: &rpc.LogLine{
+			Message:    message.Message,
+			Timestamp:  message.Timestamp,
+			InstanceId: instance,
+		},
+		ProjectId: projectId,
+		ClientId:  clientId,
+		Hostname:  instance,
+		Level:     message.Level(),
+		Fields:    fields,
+	}
+}
+
+// Shutdown stops the listeners and waits for open connections to finish
+// the message they are reading.
+func (s *SyslogServer) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.stopping = true
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
+	for conn := range s.connections {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func senderIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
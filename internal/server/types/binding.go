@@ -2,6 +2,8 @@ package types
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -38,3 +40,52 @@ func (nd *NullableDate) UnmarshalParam(param string) error {
 	nd.Valid = true
 	return nil
 }
+
+// TimeBound is a from/to/at query parameter of the logs view. It accepts unix
+// milliseconds, RFC3339 and durations relative to now like "-15m".
+type TimeBound struct {
+	Time  time.Time
+	Valid bool
+	// Relative is set when the bound was given relative to now, so links
+	// can keep following the current time
+	Relative string
+}
+
+var _ echo.BindUnmarshaler = (*TimeBound)(nil)
+
+// UnmarshalParam implements echo's BindUnmarshaler interface.
+func (tb *TimeBound) UnmarshalParam(param string) error {
+	*tb = TimeBound{}
+	if param == "" {
+		return nil
+	}
+
+	if param == "now" {
+		tb.Time = time.Now()
+		tb.Relative = param
+	} else if strings.HasPrefix(param, "-") {
+		ago, err := time.ParseDuration(param)
+		if err != nil || ago >= 0 {
+			return fmt.Errorf("invalid relative time: %s", param)
+		}
+		tb.Time = time.Now().Add(ago)
+		tb.Relative = param
+	} else if millis, err := strconv.ParseInt(param, 10, 64); err == nil {
+		tb.Time = time.UnixMilli(millis)
+	} else if t, err := time.Parse(time.RFC3339, param); err == nil {
+		tb.Time = t
+	} else {
+		return fmt.Errorf("invalid time format: %s", param)
+	}
+
+	tb.Valid = true
+	return nil
+}
+
+// Ptr returns the time, or nil when the bound wasn't set.
+func (tb TimeBound) Ptr() *time.Time {
+	if !tb.Valid {
+		return nil
+	}
+	return &tb.Time
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type ServerEnv struct {
 	MongoUrl       string `env:"MONGO_URL"`
 	GrpcServerPort int    `env:"GPRC_PORT"`
@@ -7,9 +9,60 @@ type ServerEnv struct {
 	HttpServerPort int    `env:"HTTP_SERVER_PORT"`
 	SessionSecret  string `env:"SESSION_SECRET"`
 
+	// LogStorage selects where log lines are stored, "mongo" or "sqlite".
+	// Users, projects and credentials are always stored in MongoDB.
+	LogStorage string `env:"LOG_STORAGE" envDefault:"mongo"`
+	SqlitePath string `env:"SQLITE_PATH" envDefault:"svarog-logs.db"`
+
+	// The gRPC ingest server uses TLS when a certificate is set
+	GrpcTLSCertFile string `env:"GRPC_TLS_CERT_FILE"`
+	GrpcTLSKeyFile  string `env:"GRPC_TLS_KEY_FILE"`
+
+	// The syslog listeners are disabled when their port isn't set, TCP uses
+	// TLS when a certificate is set. Messages are routed by the rules file.
+	SyslogUDPPort     int    `env:"SYSLOG_UDP_PORT"`
+	SyslogTCPPort     int    `env:"SYSLOG_TCP_PORT"`
+	SyslogTLSCertFile string `env:"SYSLOG_TLS_CERT_FILE"`
+	SyslogTLSKeyFile  string `env:"SYSLOG_TLS_KEY_FILE"`
+	SyslogRulesFile   string `env:"SYSLOG_RULES_FILE" envDefault:"syslog-rules.yaml"`
+
+	// Prometheus metrics are served on /metrics of their own port, so they
+	// aren't public with the HTTP server. They're disabled when it isn't set.
+	MetricsPort int `env:"METRICS_PORT"`
+
+	// ClientImage is the client image used in the deployment snippets
+	ClientImage string `env:"SVAROG_CLIENT_IMAGE" envDefault:"markojerkic/svarog-client:latest"`
+
 	NatsPublicAddr     string `env:"NATS_PUBLIC_ADDR"`
 	NatsAddr           string `env:"NATS_ADDR"`
 	NatsAccountSeed    string `env:"NATS_ACCOUNT_SEED"`
 	NatsServerUserJWT  string `env:"NATS_SERVER_USER_JWT"`
 	NatsServerUserSeed string `env:"NATS_SERVER_USER_SEED"`
+
+	// NatsAccountPublicKey is required when NatsAccountSeed is a signing key
+	NatsAccountPublicKey   string `env:"NATS_ACCOUNT_PUBLIC_KEY"`
+	NatsAccountSeedFile    string `env:"NATS_ACCOUNT_SEED_FILE"`
+	NatsServerUserJWTFile  string `env:"NATS_SERVER_USER_JWT_FILE"`
+	NatsServerUserSeedFile string `env:"NATS_SERVER_USER_SEED_FILE"`
+
+	// Needed to revoke credentials, revocations are only stored when unset
+	NatsOperatorSeed       string `env:"NATS_OPERATOR_SEED"`
+	NatsAccountJWT         string `env:"NATS_ACCOUNT_JWT"`
+	NatsSystemUserJWT      string `env:"NATS_SYSTEM_USER_JWT"`
+	NatsSystemUserSeed     string `env:"NATS_SYSTEM_USER_SEED"`
+	NatsOperatorSeedFile   string `env:"NATS_OPERATOR_SEED_FILE"`
+	NatsAccountJWTFile     string `env:"NATS_ACCOUNT_JWT_FILE"`
+	NatsSystemUserJWTFile  string `env:"NATS_SYSTEM_USER_JWT_FILE"`
+	NatsSystemUserSeedFile string `env:"NATS_SYSTEM_USER_SEED_FILE"`
+
+	NatsEmbedded         bool   `env:"NATS_EMBEDDED"`
+	NatsEmbeddedConfig   string `env:"NATS_EMBEDDED_CONFIG" envDefault:"nats-server.conf"`
+	NatsEmbeddedStoreDir string `env:"NATS_EMBEDDED_STORE_DIR"`
+	NatsEmbeddedPort     int    `env:"NATS_EMBEDDED_PORT"`
+
+	NatsStreamPerProject bool          `env:"NATS_STREAM_PER_PROJECT"`
+	NatsStreamMaxBytes   int64         `env:"NATS_STREAM_MAX_BYTES" envDefault:"1073741824"`
+	NatsStreamMaxAge     time.Duration `env:"NATS_STREAM_MAX_AGE" envDefault:"168h"`
+	NatsStreamStorage    string        `env:"NATS_STREAM_STORAGE" envDefault:"file"`
+	NatsStreamReplicas   int           `env:"NATS_STREAM_REPLICAS" envDefault:"1"`
 }
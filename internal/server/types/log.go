@@ -17,6 +17,68 @@ type StoredLog struct {
 	Timestamp      time.Time          `bson:"timestamp"`
 	Client         StoredClient       `bson:"client"`
 	SequenceNumber int                `bson:"sequence_number"`
+	Level          string             `bson:"level,omitempty"`
+	Fields         map[string]any     `bson:"fields,omitempty"`
+	TraceId        string             `bson:"trace_id,omitempty"`
+	SpanId         string             `bson:"span_id,omitempty"`
+	// Stream and StreamSequence identify the JetStream message the line was
+	// ingested from. They are used to skip duplicates when a stream is replayed.
+	// Stream is the stream's name and creation time, as sequences start again
+	// when a stream is recreated.
+	Stream         string `bson:"stream,omitempty"`
+	StreamSequence uint64 `bson:"stream_seq,omitempty"`
+}
+
+// LiveLogLine is the JSON published on live.logs.<project>.<client> for
+// consumers following the live stream outside the browser.
+type LiveLogLine struct {
+	ID             string         `json:"id"`
+	Timestamp      time.Time      `json:"timestamp"`
+	ProjectId      string         `json:"projectId"`
+	ClientId       string         `json:"clientId"`
+	InstanceId     string         `json:"instanceId"`
+	SequenceNumber int            `json:"sequenceNumber"`
+	Level          string         `json:"level,omitempty"`
+	Message        string         `json:"message"`
+	Fields         map[string]any `json:"fields,omitempty"`
+	TraceId        string         `json:"traceId,omitempty"`
+	SpanId         string         `json:"spanId,omitempty"`
+}
+
+func NewLiveLogLine(logLine StoredLog) LiveLogLine {
+	return LiveLogLine{
+		ID:             logLine.ID.Hex(),
+		Timestamp:      logLine.Timestamp,
+		ProjectId:      logLine.Client.ProjectId,
+		ClientId:       logLine.Client.ClientId,
+		InstanceId:     logLine.Client.InstanceId,
+		SequenceNumber: logLine.SequenceNumber,
+		Level:          logLine.Level,
+		Message:        logLine.LogLine,
+		Fields:         logLine.Fields,
+		TraceId:        logLine.TraceId,
+		SpanId:         logLine.SpanId,
+	}
+}
+
+// StoredLog is the line a live line was published for.
+func (l LiveLogLine) StoredLog() StoredLog {
+	id, _ := primitive.ObjectIDFromHex(l.ID)
+	return StoredLog{
+		ID:        id,
+		LogLine:   l.Message,
+		Timestamp: l.Timestamp,
+		Client: StoredClient{
+			ProjectId:  l.ProjectId,
+			ClientId:   l.ClientId,
+			InstanceId: l.InstanceId,
+		},
+		SequenceNumber: l.SequenceNumber,
+		Level:          l.Level,
+		Fields:         l.Fields,
+		TraceId:        l.TraceId,
+		SpanId:         l.SpanId,
+	}
 }
 
 type StoredClient struct {
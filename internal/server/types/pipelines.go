@@ -0,0 +1,15 @@
+package types
+
+type PipelineForm struct {
+	ID        string `json:"id" form:"id"`
+	ProjectId string `json:"projectId" form:"projectId" validate:"required"`
+	ClientId  string `json:"clientId" form:"clientId" validate:"required"`
+	Name      string `json:"name" form:"name" validate:"required,gte=3"`
+	Enabled   bool   `json:"enabled" form:"enabled"`
+	Stages    string `json:"stages" form:"stages"`
+}
+
+type PipelinePreviewForm struct {
+	Stages string `json:"stages" form:"stages"`
+	Sample string `json:"sample" form:"sample" validate:"required"`
+}
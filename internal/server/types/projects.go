@@ -26,3 +26,11 @@ type ProjectDto struct {
 	Clients     []string           `bson:"clients" json:"clients"`
 	StorageSize int64              `json:"storageSize"`
 }
+
+type ClientActionForm struct {
+	ClientId string `json:"clientId" form:"clientId" validate:"required"`
+}
+
+type ClientApprovalForm struct {
+	Required bool `json:"required" form:"required"`
+}
@@ -0,0 +1,8 @@
+package types
+
+type ReplayForm struct {
+	ProjectId     string `json:"projectId" form:"projectId" validate:"required"`
+	ClientId      string `json:"clientId" form:"clientId"`
+	From          string `json:"from" form:"from"`
+	StartSequence uint64 `json:"startSequence" form:"startSequence"`
+}
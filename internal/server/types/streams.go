@@ -0,0 +1,6 @@
+package types
+
+type StreamLimitsForm struct {
+	MaxMegabytes int64 `json:"maxMegabytes" form:"maxMegabytes" validate:"required,gt=0"`
+	MaxAgeHours  int64 `json:"maxAgeHours" form:"maxAgeHours" validate:"required,gt=0"`
+}
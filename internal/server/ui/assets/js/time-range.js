@@ -0,0 +1,32 @@
+// The time range filter uses datetime-local inputs, which have no time zone.
+// Values are shown and sent in the browser's time zone.
+(function () {
+  const pad = (n) => String(n).padStart(2, "0");
+
+  function toInputValue(date) {
+    return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}T${pad(date.getHours())}:${pad(date.getMinutes())}`;
+  }
+
+  function fillInputs() {
+    document.querySelectorAll("[data-time-range] input[data-time-value]").forEach((input) => {
+      const value = input.getAttribute("data-time-value");
+      if (value && !input.value) {
+        input.value = toInputValue(new Date(value));
+      }
+    });
+  }
+
+  document.addEventListener("htmx:configRequest", function (e) {
+    if (!e.target.closest || !e.target.closest("[data-time-range]")) return;
+
+    for (const name of ["from", "to", "at"]) {
+      const value = e.detail.parameters[name];
+      if (typeof value === "string" && /^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(:\d{2})?$/.test(value)) {
+        e.detail.parameters[name] = new Date(value).toISOString();
+      }
+    }
+  });
+
+  document.addEventListener("htmx:afterSettle", fillInputs);
+  fillInputs();
+})();
@@ -20,8 +20,21 @@ func (w *WatchHub) SendLogLine(projectId, clientId string, line []byte) error {
 	return w.conn.Publish(fmt.Sprintf("ws.logs.%s.%s", projectId, clientId), line)
 }
 
+// SendLiveLine publishes the structured line for live tail credentials.
+func (w *WatchHub) SendLiveLine(projectId, clientId string, line []byte) error {
+	return w.conn.Publish(fmt.Sprintf("live.logs.%s.%s", projectId, clientId), line)
+}
+
 func (w *WatchHub) Subscribe(projectId, clientId string, lines chan<- []byte) (*nats.Subscription, error) {
 	return w.conn.Subscribe(fmt.Sprintf("ws.logs.%s.%s", projectId, clientId), func(msg *nats.Msg) {
 		lines <- msg.Data
 	})
 }
+
+// SubscribeLive receives the structured lines of a client, for watchers that
+// render the lines themselves.
+func (w *WatchHub) SubscribeLive(projectId, clientId string, lines chan<- []byte) (*nats.Subscription, error) {
+	return w.conn.Subscribe(fmt.Sprintf("live.logs.%s.%s", projectId, clientId), func(msg *nats.Msg) {
+		lines <- msg.Data
+	})
+}
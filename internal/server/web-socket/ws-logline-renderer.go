@@ -3,6 +3,9 @@ package websocket
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
 
 	"github.com/markojerkic/svarog/internal/server/types"
 	"github.com/markojerkic/svarog/internal/server/ui/components/logs"
@@ -11,6 +14,7 @@ import (
 type WsLogLineRenderer struct {
 	updates  chan types.StoredLog
 	watchHub *WatchHub
+	workers  sync.WaitGroup
 }
 
 const workerCount = 10
@@ -22,7 +26,8 @@ func NewWsLogLineRenderer(watchHub *WatchHub) *WsLogLineRenderer {
 	}
 
 	for range workerCount {
-		go renderer.run(context.Background())
+		renderer.workers.Add(1)
+		go renderer.run()
 	}
 
 	return renderer
@@ -32,14 +37,29 @@ func (w *WsLogLineRenderer) Render(ctx context.Context, logLine types.StoredLog)
 	w.updates <- logLine
 }
 
-func (w *WsLogLineRenderer) run(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case logLine := <-w.updates:
-			w.render(logLine)
-		}
+func (w *WsLogLineRenderer) run() {
+	defer w.workers.Done()
+	for logLine := range w.updates {
+		w.render(logLine)
+	}
+}
+
+// Shutdown stops accepting lines and waits for the queued ones to be published.
+// Render must not be called after Shutdown.
+func (w *WsLogLineRenderer) Shutdown(ctx context.Context) error {
+	close(w.updates)
+
+	done := make(chan struct{})
+	go func() {
+		w.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -47,4 +67,11 @@ func (w *WsLogLineRenderer) render(logLine types.StoredLog) {
 	var buf bytes.Buffer
 	logs.OobSwapLogLine(logs.LogLineProps{LogLine: logLine}).Render(context.Background(), &buf)
 	w.watchHub.SendLogLine(logLine.Client.ProjectId, logLine.Client.ClientId, buf.Bytes())
+
+	live, err := json.Marshal(types.NewLiveLogLine(logLine))
+	if err != nil {
+		slog.Error("Failed to marshal live log line", "error", err)
+		return
+	}
+	w.watchHub.SendLiveLine(logLine.Client.ProjectId, logLine.Client.ClientId, live)
 }
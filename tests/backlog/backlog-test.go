@@ -0,0 +1,57 @@
+package backlog
+
+import (
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/backlog"
+)
+
+func (s *BacklogSuite) TestCountsQueuedLines() {
+	lines := backlog.NewBacklog[int](100)
+	s.Equal(100, lines.Capacity())
+
+	for i := range 3 {
+		lines.AddToBacklog(i)
+	}
+	lines.ForceDump()
+	s.Equal(3, lines.Count(), "forced dumps count their lines, not a full batch")
+
+	lines.AddToBacklog(3)
+	s.Equal(4, lines.Count())
+
+	dumped := <-lines.GetLogs()
+	s.Equal([]int{0, 1, 2}, dumped)
+	lines.Release(len(dumped))
+	s.Equal(1, lines.Count())
+}
+
+func (s *BacklogSuite) TestDumpWaitsForSpace() {
+	lines := backlog.NewBacklog[int](5)
+
+	for i := range 4 {
+		lines.AddToBacklog(i)
+	}
+	lines.ForceDump()
+
+	dumped := make(chan struct{})
+	go func() {
+		lines.AddToBacklog(4)
+		lines.AddToBacklog(5)
+		lines.ForceDump()
+		close(dumped)
+	}()
+
+	select {
+	case <-dumped:
+		s.Fail("dumping over the capacity should wait")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	lines.Release(len(<-lines.GetLogs()))
+	select {
+	case <-dumped:
+	case <-time.After(5 * time.Second):
+		s.Fail("released space should let the dump continue")
+	}
+	s.Equal([]int{4, 5}, <-lines.GetLogs())
+}
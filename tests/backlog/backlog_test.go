@@ -0,0 +1,11 @@
+package backlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestBacklogSuite(t *testing.T) {
+	suite.Run(t, new(BacklogSuite))
+}
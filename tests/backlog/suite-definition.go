@@ -0,0 +1,9 @@
+package backlog
+
+import (
+	"github.com/stretchr/testify/suite"
+)
+
+type BacklogSuite struct {
+	suite.Suite
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/markojerkic/svarog/internal/server/db"
@@ -281,3 +282,61 @@ func (suite *LogsCollectionRepositorySuite) TestCursorWithSameTimestamp() {
 			"Sequence number %d should not appear in both pages", log.SequenceNumber)
 	}
 }
+
+func (suite *LogsCollectionRepositorySuite) TestGetLogsScrollsForwardFromInstant() {
+	t := suite.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	logs := make([]types.StoredLog, 12)
+	for i := range 12 {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "test-client",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        fmt.Sprintf("Log line %d", i),
+		}
+	}
+	assert.NoError(t, suite.logService.SaveLogs(ctx, logs))
+
+	// Jumping to line 5 loads the lines up to it, scrolling forward the newer ones
+	at := baseTime.Add(5 * time.Second)
+	page, err := suite.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "test-client",
+		PageSize:  4,
+		Cursor:    &db.LastCursor{Timestamp: at, SequenceNumber: math.MaxInt, IsBackward: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5, 4, 3, 2}, sequenceNumbers(page.Logs))
+	assert.False(t, page.IsLastPage)
+
+	forward := &db.LastCursor{Timestamp: at, SequenceNumber: math.MaxInt, IsBackward: false}
+	newer, err := suite.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "test-client",
+		PageSize:  4,
+		Cursor:    forward,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{9, 8, 7, 6}, sequenceNumbers(newer.Logs))
+	assert.False(t, newer.IsLastPage)
+	if assert.NotNil(t, newer.ForwardCursor) {
+		assert.Equal(t, 9, newer.ForwardCursor.SequenceNumber)
+	}
+
+	newest, err := suite.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "test-client",
+		PageSize:  4,
+		Cursor:    newer.ForwardCursor,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{11, 10}, sequenceNumbers(newest.Logs))
+	assert.Nil(t, newest.ForwardCursor)
+	assert.True(t, newest.IsLastPage, "reaching the newest line follows new lines")
+}
@@ -7,8 +7,6 @@ import (
 	"github.com/markojerkic/svarog/internal/server/db"
 	"github.com/markojerkic/svarog/internal/server/types"
 	"github.com/stretchr/testify/assert"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"log/slog"
 )
 
@@ -74,11 +72,13 @@ func findLogLine(logs []types.StoredLog, logLineId string) int {
 
 // Find logline with default sorting at index 5_000
 func (suite *LogsCollectionRepositorySuite) findRandomLogLine() (*types.StoredLog, error) {
-	var logLine types.StoredLog
-	sort := bson.D{{Key: "timestamp", Value: -1}, {Key: "sequence_number", Value: -1}}
-	filter := bson.D{
-		{Key: "client.project_id", Value: "test-project"},
+	logPage, err := suite.logService.GetLogs(context.Background(), db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "marko",
+		PageSize:  5_001,
+	})
+	if err != nil {
+		return nil, err
 	}
-	err := suite.logsCollection.FindOne(context.Background(), filter, options.FindOne().SetSort(sort).SetSkip(5_000)).Decode(&logLine)
-	return &logLine, err
+	return &logPage.Logs[5_000], nil
 }
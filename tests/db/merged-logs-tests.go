@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *LogsCollectionRepositorySuite) TestGetSourcesLogsMergesByTime() {
+	t := s.T()
+	ctx := context.Background()
+
+	sources := []db.LogSource{
+		{ProjectId: "shop", ClientId: "api"},
+		{ProjectId: "shop", ClientId: "worker"},
+		{ProjectId: "shop", ClientId: "scheduler"},
+	}
+	s.saveMergedSourceLogs(sources)
+
+	page, err := db.GetSourcesLogs(ctx, s.logService, db.MergedLogsRequest{
+		Sources:  sources,
+		PageSize: 4,
+	})
+	require.NoError(t, err)
+	assert.True(t, page.IsLastPage)
+	require.NotNil(t, page.BackwardCursor)
+
+	seen := mergedLines(page.Logs)
+	for page.BackwardCursor != nil {
+		page, err = db.GetSourcesLogs(ctx, s.logService, db.MergedLogsRequest{
+			Sources:  sources,
+			PageSize: 4,
+			Cursor:   page.BackwardCursor,
+		})
+		require.NoError(t, err)
+		assert.False(t, page.IsLastPage)
+		seen = append(seen, mergedLines(page.Logs)...)
+	}
+	assert.Equal(t, []string{
+		"shop/worker 3", "shop/scheduler 3", "shop/api 3",
+		"shop/worker 2", "shop/scheduler 2", "shop/api 2",
+		"shop/worker 1", "shop/scheduler 1", "shop/api 1",
+		"shop/worker 0", "shop/scheduler 0", "shop/api 0",
+	}, seen, "lines logged at the same instant aren't skipped between pages")
+}
+
+func (s *LogsCollectionRepositorySuite) TestGetSourcesLogsInstances() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := s.saveMergedSourceLogs([]db.LogSource{{ProjectId: "shop", ClientId: "worker"}})
+	require.NoError(t, s.logService.SaveLogs(ctx, []types.StoredLog{
+		{
+			Client:    types.StoredClient{ProjectId: "shop", ClientId: "api", InstanceId: "10.0.0.1"},
+			Timestamp: baseTime,
+			LogLine:   "kept",
+		},
+		{
+			Client:    types.StoredClient{ProjectId: "shop", ClientId: "api", InstanceId: "10.0.0.2"},
+			Timestamp: baseTime,
+			LogLine:   "filtered",
+		},
+	}))
+
+	page, err := db.GetSourcesLogs(ctx, s.logService, db.MergedLogsRequest{
+		Sources: []db.LogSource{
+			{ProjectId: "shop", ClientId: "api", Instances: &[]string{"10.0.0.1"}},
+			{ProjectId: "shop", ClientId: "worker"},
+		},
+		PageSize: 10,
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Logs, 5)
+	assert.Nil(t, page.BackwardCursor)
+	for _, log := range page.Logs {
+		assert.NotEqual(t, "filtered", log.LogLine)
+	}
+}
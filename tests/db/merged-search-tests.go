@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveMergedSourceLogs saves four lines of every source, the lines of all
+// sources are logged at the same instants with the same sequence numbers.
+func (s *LogsCollectionRepositorySuite) saveMergedSourceLogs(sources []db.LogSource) time.Time {
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	logs := []types.StoredLog{}
+	for _, source := range sources {
+		for i := range 4 {
+			logs = append(logs, types.StoredLog{
+				Client: types.StoredClient{
+					ProjectId:  source.ProjectId,
+					ClientId:   source.ClientId,
+					InstanceId: "::1",
+				},
+				Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+				SequenceNumber: i,
+				LogLine:        fmt.Sprintf("request 4bf92f35 step %d", i),
+			})
+		}
+	}
+	require.NoError(s.T(), s.logService.SaveLogs(context.Background(), logs))
+	return baseTime
+}
+
+func mergedLines(logs []types.StoredLog) []string {
+	lines := make([]string, len(logs))
+	for i, log := range logs {
+		lines[i] = fmt.Sprintf("%s/%s %d", log.Client.ProjectId, log.Client.ClientId, log.SequenceNumber)
+	}
+	return lines
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchSourcesMergesByTime() {
+	t := s.T()
+	ctx := context.Background()
+
+	sources := []db.LogSource{
+		{ProjectId: "shop", ClientId: "api"},
+		{ProjectId: "shop", ClientId: "worker"},
+		{ProjectId: "billing", ClientId: "api"},
+	}
+	s.saveMergedSourceLogs(sources)
+
+	page, err := db.SearchSources(ctx, s.logService, db.MergedSearchRequest{
+		Sources:    sources,
+		PageSize:   4,
+		Query:      "4bf92f35",
+		CountLimit: 100,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop/worker 3", "shop/api 3", "billing/api 3", "shop/worker 2"}, mergedLines(page.Logs))
+	assert.Equal(t, int64(12), page.Count)
+	assert.Nil(t, page.ForwardCursor)
+	require.NotNil(t, page.BackwardCursor)
+
+	seen := mergedLines(page.Logs)
+	for page.BackwardCursor != nil {
+		page, err = db.SearchSources(ctx, s.logService, db.MergedSearchRequest{
+			Sources:  sources,
+			PageSize: 4,
+			Cursor:   page.BackwardCursor,
+			Query:    "4bf92f35",
+		})
+		require.NoError(t, err)
+		seen = append(seen, mergedLines(page.Logs)...)
+	}
+	assert.Equal(t, []string{
+		"shop/worker 3", "shop/api 3", "billing/api 3",
+		"shop/worker 2", "shop/api 2", "billing/api 2",
+		"shop/worker 1", "shop/api 1", "billing/api 1",
+		"shop/worker 0", "shop/api 0", "billing/api 0",
+	}, seen, "lines logged at the same instant aren't skipped between pages")
+
+	require.NotNil(t, page.ForwardCursor)
+	newer, err := db.SearchSources(ctx, s.logService, db.MergedSearchRequest{
+		Sources:  sources,
+		PageSize: 4,
+		Cursor:   page.ForwardCursor,
+		Query:    "4bf92f35",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shop/api 2", "billing/api 2", "shop/worker 1", "shop/api 1"}, mergedLines(newer.Logs))
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchSourcesCountLimit() {
+	sources := []db.LogSource{
+		{ProjectId: "shop", ClientId: "api"},
+		{ProjectId: "shop", ClientId: "worker"},
+	}
+	s.saveMergedSourceLogs(sources)
+
+	page, err := db.SearchSources(context.Background(), s.logService, db.MergedSearchRequest{
+		Sources:    sources,
+		PageSize:   10,
+		Query:      "4bf92f35",
+		CountLimit: 6,
+	})
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), page.Logs, 8)
+	assert.Equal(s.T(), int64(6), page.Count)
+	assert.True(s.T(), page.CountLimited)
+}
@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *LogsCollectionRepositorySuite) TestSaveLogsSkipsStoredStreamSequences() {
+	t := s.T()
+
+	lines := make([]types.StoredLog, 10)
+	for i := range lines {
+		lines[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      time.Now(),
+			LogLine:        fmt.Sprintf("Log line %d", i),
+			SequenceNumber: i,
+			Stream:         "LOGS",
+			StreamSequence: uint64(i + 1),
+		}
+	}
+
+	require.NoError(t, s.logService.SaveLogs(context.Background(), lines[:6]))
+	require.NoError(t, s.logService.SaveLogs(context.Background(), lines))
+
+	assert.Equal(t, int64(10), s.countNumberOfLogsInDb())
+}
+
+func (s *LogsCollectionRepositorySuite) TestReplayFromStream() {
+	s.requireNats()
+	t := s.T()
+	ctx := context.Background()
+
+	projectId := "replay-project"
+	for i := 0; i < 20; i++ {
+		data, err := json.Marshal(rpc.LogLine{
+			Message:    fmt.Sprintf("Replayed line %d", i),
+			Timestamp:  time.Now(),
+			Sequence:   i,
+			InstanceId: "::1",
+		})
+		require.NoError(t, err)
+		_, err = s.NatsConn.JetStream.Publish(ctx, fmt.Sprintf("logs.%s.marko", projectId), data)
+		require.NoError(t, err)
+	}
+
+	ingestCh := make(chan db.LogLineWithHost, 100)
+	replayService := ingest.NewReplayService(ingest.NewIngestService(ingestCh, s.NatsConn, nil, nil), s.Collection("replay_jobs"))
+
+	job, err := replayService.Start(ctx, ingest.ReplayRequest{ProjectId: projectId})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), job.Total)
+
+	require.Eventually(t, func() bool {
+		job, err := replayService.Get(job.ID)
+		return err == nil && job.Status == ingest.ReplayCompleted
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// Jobs are stored, a restarted server still lists them
+	require.Eventually(t, func() bool {
+		restarted := ingest.NewReplayService(ingest.NewIngestService(ingestCh, s.NatsConn, nil, nil), s.Collection("replay_jobs"))
+		require.NoError(t, restarted.Resume(ctx))
+		stored, err := restarted.Get(job.ID)
+		return err == nil && stored.Status == ingest.ReplayCompleted && stored.Processed == 20
+	}, 5*time.Second, 100*time.Millisecond)
+
+	require.Len(t, ingestCh, 20)
+	replayed := make([]types.StoredLog, 0, 20)
+	for len(ingestCh) > 0 {
+		line := <-ingestCh
+		assert.NotZero(t, line.StreamSequence)
+		replayed = append(replayed, types.StoredLog{
+			Client:         types.StoredClient{ProjectId: line.ProjectId, ClientId: line.ClientId, InstanceId: line.Hostname},
+			Timestamp:      line.Timestamp,
+			LogLine:        line.Message,
+			SequenceNumber: line.Sequence,
+			Stream:         line.Stream,
+			StreamSequence: line.StreamSequence,
+		})
+	}
+
+	// Replaying the same messages twice must not duplicate lines
+	require.NoError(t, s.logService.SaveLogs(ctx, replayed))
+	require.NoError(t, s.logService.SaveLogs(ctx, replayed))
+	assert.Equal(t, int64(20), s.countNumberOfLogsInDb())
+}
+
+func (s *LogsCollectionRepositorySuite) TestCancelAllWaitsForReplays() {
+	s.requireNats()
+	t := s.T()
+	ctx := context.Background()
+
+	projectId := "cancelled-replay-project"
+	for i := 0; i < 20; i++ {
+		data, err := json.Marshal(rpc.LogLine{
+			Message:    fmt.Sprintf("Cancelled line %d", i),
+			Timestamp:  time.Now(),
+			Sequence:   i,
+			InstanceId: "::1",
+		})
+		require.NoError(t, err)
+		_, err = s.NatsConn.JetStream.Publish(ctx, fmt.Sprintf("logs.%s.marko", projectId), data)
+		require.NoError(t, err)
+	}
+
+	// Nobody consumes the channel, the replay blocks on its first line
+	ingestCh := make(chan db.LogLineWithHost)
+	replayService := ingest.NewReplayService(ingest.NewIngestService(ingestCh, s.NatsConn, nil, nil), s.Collection("replay_jobs"))
+
+	job, err := replayService.Start(ctx, ingest.ReplayRequest{ProjectId: projectId})
+	require.NoError(t, err)
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, replayService.CancelAll(cancelCtx))
+
+	select {
+	case line := <-ingestCh:
+		t.Fatalf("replay sent %q after being cancelled", line.Message)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Interrupted by the shutdown, the job resumes on the next start
+	stopped, err := replayService.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ingest.ReplayRunning, stopped.Status)
+}
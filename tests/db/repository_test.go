@@ -8,5 +8,9 @@ import (
 
 // Run test duite
 func TestRepositorySuite(t *testing.T) {
-	suite.Run(t, new(LogsCollectionRepositorySuite))
+	suite.Run(t, &LogsCollectionRepositorySuite{backend: mongoBackend})
+}
+
+func TestSqliteRepositorySuite(t *testing.T) {
+	suite.Run(t, &LogsCollectionRepositorySuite{backend: sqliteBackend})
 }
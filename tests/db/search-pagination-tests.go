@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveSearchPaginationLogs saves ten lines a second apart, the even ones
+// matching "needle".
+func (s *LogsCollectionRepositorySuite) saveSearchPaginationLogs() {
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	logs := make([]types.StoredLog, 10)
+	for i := range logs {
+		message := "haystack line"
+		if i%2 == 0 {
+			message = "needle line"
+		}
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        message,
+		}
+	}
+	require.NoError(s.T(), s.logService.SaveLogs(context.Background(), logs))
+}
+
+func sequenceNumbers(logs []types.StoredLog) []int {
+	numbers := make([]int, len(logs))
+	for i, log := range logs {
+		numbers[i] = log.SequenceNumber
+	}
+	return numbers
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchPagination() {
+	t := s.T()
+	ctx := context.Background()
+	s.saveSearchPaginationLogs()
+
+	request := func(cursor *db.LastCursor) db.SearchRequest {
+		return db.SearchRequest{
+			LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 2, Cursor: cursor},
+			Query:          "needle",
+			CountLimit:     100,
+		}
+	}
+
+	first, err := s.logService.SearchLogs(ctx, request(nil))
+	require.NoError(t, err)
+	assert.Equal(t, []int{8, 6}, sequenceNumbers(first.Logs))
+	assert.Equal(t, int64(5), first.Count)
+	assert.False(t, first.CountLimited)
+	assert.Nil(t, first.ForwardCursor, "there are no newer matches")
+	require.NotNil(t, first.BackwardCursor)
+
+	second, err := s.logService.SearchLogs(ctx, request(first.BackwardCursor))
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 2}, sequenceNumbers(second.Logs))
+	assert.Equal(t, int64(5), second.Count, "the count doesn't depend on the cursor")
+	require.NotNil(t, second.BackwardCursor)
+	require.NotNil(t, second.ForwardCursor)
+
+	last, err := s.logService.SearchLogs(ctx, request(second.BackwardCursor))
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, sequenceNumbers(last.Logs))
+	assert.Nil(t, last.BackwardCursor, "there are no older matches")
+
+	newer, err := s.logService.SearchLogs(ctx, request(last.ForwardCursor))
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 2}, sequenceNumbers(newer.Logs), "newer matches are still listed newest first")
+	require.NotNil(t, newer.ForwardCursor)
+
+	newest, err := s.logService.SearchLogs(ctx, request(newer.ForwardCursor))
+	require.NoError(t, err)
+	assert.Equal(t, []int{8, 6}, sequenceNumbers(newest.Logs))
+	assert.Nil(t, newest.ForwardCursor)
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchCountLimit() {
+	t := s.T()
+	ctx := context.Background()
+	s.saveSearchPaginationLogs()
+
+	page, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 1},
+		Query:          "needle",
+		CountLimit:     3,
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Logs, 1)
+	assert.Equal(t, int64(3), page.Count)
+	assert.True(t, page.CountLimited)
+
+	page, err = s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 1},
+		Query:          "needle",
+	})
+	require.NoError(t, err)
+	assert.Zero(t, page.Count, "matches aren't counted without a limit")
+	assert.False(t, page.CountLimited)
+}
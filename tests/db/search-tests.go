@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *LogsCollectionRepositorySuite) TestSearchLogs() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	messages := []string{"connection timeout", "request done", "database timeout reached", "request started"}
+	logs := make([]types.StoredLog, len(messages))
+	for i, message := range messages {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        message,
+		}
+	}
+	logs = append(logs, types.StoredLog{
+		Client:    types.StoredClient{ProjectId: "test-project", ClientId: "other", InstanceId: "::1"},
+		Timestamp: baseTime,
+		LogLine:   "other timeout",
+	})
+	require.NoError(t, s.logService.SaveLogs(ctx, logs))
+
+	timeouts, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          "timeout",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	require.Len(t, timeouts.Logs, 2)
+	assert.Equal(t, "database timeout reached", timeouts.Logs[0].LogLine, "newest lines come first")
+	assert.Equal(t, "connection timeout", timeouts.Logs[1].LogLine)
+
+	found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          `"request done"`,
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	require.Len(t, found.Logs, 1)
+	assert.Equal(t, "request done", found.Logs[0].LogLine)
+
+	found, err = s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          "reached OR started",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	assert.Len(t, found.Logs, 2, "any of the words matches")
+
+	found, err = s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          "reached started",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, found.Logs, "all of the words must match")
+
+	found, err = s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10, Cursor: &db.LastCursor{
+			Timestamp:      timeouts.Logs[0].Timestamp,
+			SequenceNumber: timeouts.Logs[0].SequenceNumber,
+			IsBackward:     true,
+		}},
+		Query: "timeout",
+		Mode:  db.SearchWords,
+	})
+	require.NoError(t, err)
+	require.Len(t, found.Logs, 1)
+	assert.Equal(t, "connection timeout", found.Logs[0].LogLine)
+}
+
+func (s *LogsCollectionRepositorySuite) TestDeleteLogsBeforeTimestamp() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	logs := make([]types.StoredLog, 10)
+	for i := range logs {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Minute),
+			SequenceNumber: i,
+			LogLine:        "old line",
+		}
+	}
+	require.NoError(t, s.logService.SaveLogs(ctx, logs))
+
+	require.NoError(t, s.logService.DeleteLogBeforeTimestamp(ctx, baseTime.Add(4*time.Minute)))
+	assert.Equal(t, int64(5), s.countNumberOfLogsInDb())
+
+	found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 20},
+		Query:          "old",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	assert.Len(t, found.Logs, 5)
+	assert.Equal(t, 5, found.Logs[len(found.Logs)-1].SequenceNumber)
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchLogsQueryLanguage() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	lines := []struct {
+		instance string
+		level    string
+		message  string
+		fields   map[string]any
+	}{
+		{"api-1", "info", "GET /health 200", map[string]any{"status": 200}},
+		{"api-2", "error", "GET /orders connection reset", map[string]any{"status": 502}},
+		{"api-2", "error", "GET /health connection reset", map[string]any{"status": 503}},
+		{"api-2", "warn", "POST /orders connection reset", map[string]any{"status": "499"}},
+		{"api-1", "error", "GET /orders timeout", nil},
+	}
+	logs := make([]types.StoredLog, len(lines))
+	for i, line := range lines {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: line.instance,
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        line.message,
+			Level:          line.level,
+			Fields:         line.fields,
+		}
+	}
+	require.NoError(t, s.logService.SaveLogs(ctx, logs))
+
+	search := func(query string) []string {
+		found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+			LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+			Query:          query,
+			Mode:           db.SearchWords,
+		})
+		require.NoError(t, err, query)
+		messages := make([]string, len(found.Logs))
+		for i, log := range found.Logs {
+			messages[i] = log.LogLine
+		}
+		return messages
+	}
+
+	assert.Equal(t, []string{"GET /orders connection reset"},
+		search(`level:error AND instance:api-2 AND "connection reset" AND NOT /health/ AND fields.status>=500`))
+	assert.Equal(t, []string{"GET /orders timeout", "GET /health connection reset", "GET /orders connection reset"},
+		search(`level:ERROR`), "levels are normalized")
+	assert.Equal(t, []string{"POST /orders connection reset", "GET /orders connection reset"},
+		search(`connection NOT health`), "negated words match anywhere in the message")
+	assert.Equal(t, []string{"GET /health connection reset", "GET /orders connection reset"},
+		search(`fields.status>500`), "only numbers are compared")
+	assert.Equal(t, []string{"POST /orders connection reset"},
+		search(`fields.status:499`), "numbers match their text")
+	assert.Equal(t, []string{"GET /orders timeout", "GET /health 200"},
+		search(`instance:api-1 AND (timeout OR /\s200$/)`))
+	assert.Equal(t, []string{"GET /orders timeout"},
+		search(`NOT fields.status:/\d+/ AND NOT fields.status>0`), "lines without the field match negations")
+	assert.Equal(t, []string{"POST /orders connection reset"},
+		search(`message:/^post/ OR message:/^POST/ AND level:warn`))
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchLogsSyntaxError() {
+	_, err := s.logService.SearchLogs(context.Background(), db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          `level:error AND (timeout`,
+		Mode:           db.SearchWords,
+	})
+
+	var syntaxErr *logquery.SyntaxError
+	require.ErrorAs(s.T(), err, &syntaxErr)
+	assert.Equal(s.T(), 24, syntaxErr.Pos)
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchLogsMatchesWholeWords() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	messages := []string{
+		"request timeout error",
+		"request timeouts terror",
+		"error: upstream Timeout",
+	}
+	logs := make([]types.StoredLog, len(messages))
+	for i, message := range messages {
+		logs[i] = types.StoredLog{
+			Client:         types.StoredClient{ProjectId: "test-project", ClientId: "marko", InstanceId: "::1"},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        message,
+		}
+	}
+	require.NoError(t, s.logService.SaveLogs(ctx, logs))
+
+	found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          "timeout error",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	matched := make([]string, len(found.Logs))
+	for i, log := range found.Logs {
+		matched[i] = log.LogLine
+	}
+	assert.Equal(t, []string{"error: upstream Timeout", "request timeout error"}, matched,
+		"every word matches whole words, regardless of case")
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *LogsCollectionRepositorySuite) TestCancelFlushesQueuedLines() {
+	t := s.T()
+
+	numberOfLines := int64(2_500)
+	logIngestChannel := make(chan db.LogLineWithHost, numberOfLines)
+	generateLogLines(logIngestChannel, numberOfLines)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.logServer.Run(ctx, logIngestChannel)
+	}()
+
+	// Cancel while most lines are still waiting in the ingest channel
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		require.FailNow(t, "log server did not stop")
+	}
+
+	assert.Equal(t, numberOfLines, s.countNumberOfLogsInDb())
+	assert.Equal(t, numberOfLines, s.logServer.SavedCount())
+	assert.True(t, s.logServer.IsBacklogEmpty())
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func (s *LogsCollectionRepositorySuite) TestSubstringSearch() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	messages := []string{
+		"GET /v2/orders/8f14e45f-ceea-467f 200",
+		"POST /v2/ordinals 201",
+		"user=a.b@example.com logged in",
+		"GET /v1/orders/c9f0f895 404",
+	}
+	logs := make([]types.StoredLog, len(messages))
+	for i, message := range messages {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Second),
+			SequenceNumber: i,
+			LogLine:        message,
+		}
+	}
+	require.NoError(t, s.logService.SaveLogs(ctx, logs))
+
+	search := func(query string, mode db.SearchMode) []string {
+		found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+			LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+			Query:          query,
+			Mode:           mode,
+		})
+		require.NoError(t, err, query)
+		messages := make([]string, len(found.Logs))
+		for i, log := range found.Logs {
+			messages[i] = log.LogLine
+		}
+		return messages
+	}
+
+	assert.Empty(t, search("/v2/ord", db.SearchWords), "the text index matches whole words")
+	assert.Equal(t, []string{"POST /v2/ordinals 201", "GET /v2/orders/8f14e45f-ceea-467f 200"},
+		search("/v2/ord", db.SearchSubstring))
+	assert.Equal(t, []string{"GET /v2/orders/8f14e45f-ceea-467f 200"},
+		search("45f-CEEA", db.SearchSubstring), "substrings match regardless of case")
+	assert.Equal(t, []string{"user=a.b@example.com logged in"},
+		search(`"a.b@example"`, db.SearchSubstring))
+	assert.Equal(t, []string{"GET /v1/orders/c9f0f895 404", "GET /v2/orders/8f14e45f-ceea-467f 200"},
+		search(`/orders\/[0-9a-f]+/ NOT 201`, db.SearchSubstring))
+	assert.Equal(t, []string{"GET /v1/orders/c9f0f895 404"},
+		search(`orders 40`, db.SearchSubstring), "short words are matched without the index")
+}
+
+func (s *LogsCollectionRepositorySuite) TestSubstringSearchNeedsTrigrams() {
+	for _, query := range []string{"ab", "/.*/", "NOT orders", "abc OR def"} {
+		_, err := s.logService.SearchLogs(context.Background(), db.SearchRequest{
+			LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+			Query:          query,
+			Mode:           db.SearchSubstring,
+		})
+
+		var syntaxErr *logquery.SyntaxError
+		assert.ErrorAs(s.T(), err, &syntaxErr, query)
+	}
+}
+
+func (s *LogsCollectionRepositorySuite) TestDeleteLogsRemovesTrigrams() {
+	if s.backend != sqliteBackend {
+		s.T().Skip("trigrams are stored in the line's document")
+	}
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.logService.SaveLogs(ctx, []types.StoredLog{{
+		Client:    types.StoredClient{ProjectId: "test-project", ClientId: "marko", InstanceId: "::1"},
+		Timestamp: baseTime,
+		LogLine:   "abcdef",
+	}}))
+
+	var count int
+	require.NoError(t, s.sqliteDb.QueryRow("SELECT count(*) FROM log_line_trigrams").Scan(&count))
+	assert.Equal(t, 4, count)
+
+	require.NoError(t, s.logService.DeleteLogBeforeTimestamp(ctx, baseTime))
+	require.NoError(t, s.sqliteDb.QueryRow("SELECT count(*) FROM log_line_trigrams").Scan(&count))
+	assert.Zero(t, count)
+}
+
+func (s *LogsCollectionRepositorySuite) TestSubstringSearchMatchesWholeLines() {
+	t := s.T()
+	ctx := context.Background()
+
+	message := strings.Repeat("padding ", 1000) + "needle-at-the-end"
+	require.NoError(t, s.logService.SaveLogs(ctx, []types.StoredLog{{
+		Client:    types.StoredClient{ProjectId: "test-project", ClientId: "marko", InstanceId: "::1"},
+		Timestamp: time.Now(),
+		LogLine:   message,
+	}}))
+
+	found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+		Query:          "needle-at",
+		Mode:           db.SearchSubstring,
+	})
+	require.NoError(t, err)
+	assert.Len(t, found.Logs, 1, "the end of long lines is indexed")
+}
+
+func (s *LogsCollectionRepositorySuite) TestBackfillTrigrams() {
+	t := s.T()
+	ctx := context.Background()
+
+	require.NoError(t, s.logService.SaveLogs(ctx, []types.StoredLog{{
+		Client:    types.StoredClient{ProjectId: "test-project", ClientId: "marko", InstanceId: "::1"},
+		Timestamp: time.Now(),
+		LogLine:   "stored before substring search",
+	}}))
+
+	// Lines stored before substring search have no trigrams
+	if s.backend == sqliteBackend {
+		_, err := s.sqliteDb.Exec("DELETE FROM log_line_trigrams")
+		require.NoError(t, err)
+	} else {
+		_, err := s.logsCollection.UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"trigrams": ""}})
+		require.NoError(t, err)
+	}
+
+	search := func() int {
+		found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+			LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 10},
+			Query:          "substring",
+			Mode:           db.SearchSubstring,
+		})
+		require.NoError(t, err)
+		return len(found.Logs)
+	}
+	assert.Zero(t, search())
+
+	backfiller, ok := s.logService.(interface {
+		BackfillTrigrams(ctx context.Context) error
+	})
+	require.True(t, ok)
+	require.NoError(t, backfiller.BackfillTrigrams(ctx))
+	assert.Equal(t, 1, search())
+}
@@ -2,10 +2,13 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"time"
 
+	"github.com/markojerkic/svarog/internal/lib/util"
 	"github.com/markojerkic/svarog/internal/rpc"
 	"github.com/markojerkic/svarog/internal/server/db"
 	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
@@ -15,13 +18,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+const (
+	mongoBackend  = "mongo"
+	sqliteBackend = "sqlite"
+)
+
 type LogsCollectionRepositorySuite struct {
 	testutils.BaseSuite
 
-	logService *db.MongoLogService
+	// backend is the LogService implementation the suite runs against
+	backend    string
+	logService db.LogService
 	logServer  db.AggregatingLogServer
 
 	logsCollection *mongo.Collection
+	mongoLogs      *db.MongoLogService
+	sqliteLogs     *db.SqliteLogService
+	sqliteDb       *sql.DB
 	wsLogRenderer  *websocket.WsLogLineRenderer
 
 	logServerContext context.Context
@@ -29,14 +42,32 @@ type LogsCollectionRepositorySuite struct {
 
 // Before all
 func (suite *LogsCollectionRepositorySuite) SetupSuite() {
-	suite.BaseSuite.SetupSuite()
-
 	suite.logServerContext = context.Background()
 
-	suite.wsLogRenderer = suite.WsLogRenderer
-	suite.logService = db.NewLogService(suite.Database, suite.wsLogRenderer)
+	switch suite.backend {
+	case sqliteBackend:
+		// SQLite doesn't need the containers, lines rendered for the
+		// web socket aren't published without a NATS connection
+		util.SetupLogger()
+		suite.wsLogRenderer = websocket.NewWsLogLineRenderer(websocket.NewWatchHub(nil))
+
+		path := filepath.Join(suite.T().TempDir(), "logs.db")
+		sqliteLogs, err := db.NewSqliteLogService(path, suite.wsLogRenderer)
+		suite.Require().NoError(err)
+		suite.sqliteLogs = sqliteLogs
+		suite.logService = sqliteLogs
+
+		suite.sqliteDb, err = sql.Open("sqlite3", path)
+		suite.Require().NoError(err)
+	default:
+		suite.BaseSuite.SetupSuite()
+		suite.wsLogRenderer = suite.WsLogRenderer
+		suite.mongoLogs = db.NewLogService(suite.Database, suite.wsLogRenderer)
+		suite.logService = suite.mongoLogs
+		suite.logsCollection = suite.Collection("log_lines")
+	}
+
 	suite.logServer = db.NewLogServer(suite.logService)
-	suite.logsCollection = suite.Collection("log_lines")
 }
 
 // Before each
@@ -52,11 +83,15 @@ func (suite *LogsCollectionRepositorySuite) SetupTest() {
 // After each
 func (suite *LogsCollectionRepositorySuite) TearDownTest() {
 	slog.Info("Tearing down test")
-	result, err := suite.logsCollection.DeleteMany(context.Background(), bson.M{})
-
-	assert.NoError(suite.T(), err)
+	if suite.backend == sqliteBackend {
+		err := suite.logService.DeleteLogBeforeTimestamp(context.Background(), time.Now().AddDate(100, 0, 0))
+		assert.NoError(suite.T(), err)
+	} else {
+		result, err := suite.logsCollection.DeleteMany(context.Background(), bson.M{})
+		assert.NoError(suite.T(), err)
+		slog.Info("Deleted logs", "count", result.DeletedCount)
+	}
 
-	slog.Info("Deleted logs", "count", result.DeletedCount)
 	num := suite.countNumberOfLogsInDb()
 	if ok := assert.Equal(suite.T(), int64(0), num, "Database teardown not successful"); !ok {
 		suite.T().FailNow()
@@ -67,11 +102,30 @@ func (suite *LogsCollectionRepositorySuite) TearDownTest() {
 // After all
 func (suite *LogsCollectionRepositorySuite) TearDownSuite() {
 	slog.Info("Tearing down suite")
+	if suite.backend == sqliteBackend {
+		suite.sqliteDb.Close()
+		suite.sqliteLogs.Close(context.Background())
+		return
+	}
+	suite.mongoLogs.Close(context.Background())
 	suite.BaseSuite.TearDownSuite()
 }
 
+// requireNats skips tests that need the NATS container.
+func (suite *LogsCollectionRepositorySuite) requireNats() {
+	if suite.NatsConn == nil {
+		suite.T().Skipf("needs NATS, not started for the %s backend", suite.backend)
+	}
+}
+
 func (suite *LogsCollectionRepositorySuite) countNumberOfLogsInDb() int64 {
-	count, err := suite.logsCollection.CountDocuments(context.Background(), bson.D{})
+	var count int64
+	var err error
+	if suite.backend == sqliteBackend {
+		err = suite.sqliteDb.QueryRow("SELECT COUNT(*) FROM log_lines").Scan(&count)
+	} else {
+		count, err = suite.logsCollection.CountDocuments(context.Background(), bson.D{})
+	}
 	if err != nil {
 		panic(fmt.Sprintf("Could not count documents: %v", err))
 	}
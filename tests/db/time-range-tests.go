@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *LogsCollectionRepositorySuite) saveMinuteApartLogs(baseTime time.Time, count int) {
+	logs := make([]types.StoredLog, count)
+	for i := range logs {
+		logs[i] = types.StoredLog{
+			Client: types.StoredClient{
+				ProjectId:  "test-project",
+				ClientId:   "marko",
+				InstanceId: "::1",
+			},
+			Timestamp:      baseTime.Add(time.Duration(i) * time.Minute),
+			SequenceNumber: i,
+			LogLine:        "range line",
+		}
+	}
+	require.NoError(s.T(), s.logService.SaveLogs(context.Background(), logs))
+}
+
+func (s *LogsCollectionRepositorySuite) TestGetLogsInTimeRange() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	s.saveMinuteApartLogs(baseTime, 10)
+
+	from := baseTime.Add(3 * time.Minute)
+	to := baseTime.Add(6 * time.Minute)
+	page, err := s.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "marko",
+		PageSize:  20,
+		TimeRange: db.TimeRange{From: &from, To: &to},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 4, "both bounds are inclusive")
+	assert.Equal(t, 6, page.Logs[0].SequenceNumber)
+	assert.Equal(t, 3, page.Logs[3].SequenceNumber)
+	assert.False(t, page.IsLastPage, "a range ending in the past doesn't follow live lines")
+	assert.Equal(t, &from, page.TimeRange.From)
+
+	page, err = s.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "marko",
+		PageSize:  20,
+		TimeRange: db.TimeRange{From: &from},
+	})
+	require.NoError(t, err)
+	assert.Len(t, page.Logs, 7)
+	assert.True(t, page.IsLastPage)
+}
+
+func (s *LogsCollectionRepositorySuite) TestGetLogsInTimeRangeKeepsRangeWhenPaging() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	s.saveMinuteApartLogs(baseTime, 10)
+
+	from := baseTime.Add(2 * time.Minute)
+	to := baseTime.Add(8 * time.Minute)
+	timeRange := db.TimeRange{From: &from, To: &to}
+	page, err := s.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "marko",
+		PageSize:  4,
+		TimeRange: timeRange,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 4)
+	require.NotNil(t, page.BackwardCursor)
+	assert.Equal(t, 8, page.Logs[0].SequenceNumber)
+
+	path := page.ToPath("test-project", "marko", nil, page.BackwardCursor, "backward")
+	assert.Contains(t, path, "from=")
+	assert.Contains(t, path, "to=")
+
+	page, err = s.logService.GetLogs(ctx, db.LogPageRequest{
+		ProjectId: "test-project",
+		ClientId:  "marko",
+		PageSize:  4,
+		Cursor:    page.BackwardCursor,
+		TimeRange: timeRange,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Logs, 3, "lines before the range aren't loaded")
+	assert.Equal(t, 4, page.Logs[0].SequenceNumber)
+	assert.Equal(t, 2, page.Logs[2].SequenceNumber)
+}
+
+func (s *LogsCollectionRepositorySuite) TestSearchLogsInTimeRange() {
+	t := s.T()
+	ctx := context.Background()
+
+	baseTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	s.saveMinuteApartLogs(baseTime, 10)
+
+	to := baseTime.Add(4 * time.Minute)
+	found, err := s.logService.SearchLogs(ctx, db.SearchRequest{
+		LogPageRequest: db.LogPageRequest{ProjectId: "test-project", ClientId: "marko", PageSize: 20, TimeRange: db.TimeRange{To: &to}},
+		Query:          "range",
+		Mode:           db.SearchWords,
+	})
+	require.NoError(t, err)
+	require.Len(t, found.Logs, 5)
+	assert.Equal(t, 4, found.Logs[0].SequenceNumber)
+}
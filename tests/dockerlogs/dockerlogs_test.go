@@ -0,0 +1,11 @@
+package dockerlogs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDockerLogsSuite(t *testing.T) {
+	suite.Run(t, new(DockerLogsSuite))
+}
@@ -0,0 +1,110 @@
+package dockerlogs
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/markojerkic/svarog/cmd/client/reader"
+)
+
+const containerId = "3f4e8c2a91b7d05e6c1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d5e6f70"
+
+func (s *DockerLogsSuite) TestFollowsExistingContainerFromEnd() {
+	logFile := s.container(containerId, "web", map[string]string{"com.docker.compose.service": "web"})
+	s.write(logFile, "stdout", "before start\n")
+
+	s.start(reader.DockerConfig{})
+	s.write(logFile, "stderr", "\x1b[31mfailed\x1b[0m\n")
+
+	line := s.receive()
+	s.Equal("failed", line.Message)
+	s.Equal("web", line.InstanceId)
+	s.Empty(line.ClientId)
+	s.Equal(0, line.Sequence)
+	s.Equal("stderr", line.Fields["stream"])
+	s.Equal("3f4e8c2a91b7", line.Fields["container_id"])
+	s.Equal("web", line.Fields["container_name"])
+	s.Equal("nginx:1.27", line.Fields["container_image"])
+	s.Equal("web", line.Fields["label_com_docker_compose_service"])
+	s.noMoreLines()
+}
+
+func (s *DockerLogsSuite) TestReadsNewContainerFromStart() {
+	s.start(reader.DockerConfig{})
+
+	logFile := s.container(containerId, "worker", nil)
+	s.write(logFile, "stdout", "first\n", "second\n")
+
+	s.Equal("first", s.receive().Message)
+	s.Equal("second", s.receive().Message)
+}
+
+func (s *DockerLogsSuite) TestJoinsPartialLines() {
+	logFile := s.container(containerId, "web", nil)
+	s.start(reader.DockerConfig{})
+
+	s.write(logFile, "stdout", "a long ", "line\r\n")
+
+	line := s.receive()
+	s.Equal("a long line", line.Message)
+	s.noMoreLines()
+}
+
+func (s *DockerLogsSuite) TestFollowsRotation() {
+	logFile := s.container(containerId, "web", nil)
+	s.start(reader.DockerConfig{})
+
+	s.write(logFile, "stdout", "before rotation\n")
+	s.Equal("before rotation", s.receive().Message)
+
+	s.Require().NoError(os.Rename(logFile, logFile+".1"))
+	s.write(logFile+".1", "stdout", "written while rotating\n")
+	s.Require().NoError(os.WriteFile(logFile, nil, 0o644))
+	s.write(logFile, "stdout", "after rotation\n")
+
+	s.Equal("written while rotating", s.receive().Message)
+	s.Equal("after rotation", s.receive().Message)
+}
+
+func (s *DockerLogsSuite) TestFollowsTruncation() {
+	logFile := s.container(containerId, "web", nil)
+	s.start(reader.DockerConfig{})
+
+	s.write(logFile, "stdout", "a line long enough to be longer than the next\n")
+	s.receive()
+
+	s.Require().NoError(os.Truncate(logFile, 0))
+	s.write(logFile, "stdout", "truncated\n")
+	s.Equal("truncated", s.receive().Message)
+}
+
+func (s *DockerLogsSuite) TestTemplates() {
+	logFile := s.container(containerId, "shop_api_1", map[string]string{"com.docker.compose.service": "api"})
+	s.start(reader.DockerConfig{
+		InstanceTemplate: "{{.Name}}-{{.ShortID}}",
+		ClientTemplate:   `{{index .Labels "com.docker.compose.service"}}`,
+	})
+
+	s.write(logFile, "stdout", "hello\n")
+
+	line := s.receive()
+	s.Equal("shop_api_1-3f4e8c2a91b7", line.InstanceId)
+	s.Equal("api", line.ClientId)
+}
+
+func (s *DockerLogsSuite) TestInvalidTemplate() {
+	_, err := reader.NewDockerReader(reader.DockerConfig{InstanceTemplate: "{{.Name"}, s.output)
+	s.Error(err)
+}
+
+func (s *DockerLogsSuite) TestReadContainerInfo() {
+	s.container(containerId, "web", map[string]string{"env": "prod"})
+
+	info, err := reader.ReadContainerInfo(filepath.Join(s.root, "containers", containerId, "config.v2.json"))
+	s.Require().NoError(err)
+	s.Equal(containerId, info.ID)
+	s.Equal("3f4e8c2a91b7", info.ShortID)
+	s.Equal("web", info.Name)
+	s.Equal("nginx:1.27", info.Image)
+	s.Equal(map[string]string{"env": "prod"}, info.Labels)
+}
@@ -0,0 +1,113 @@
+package dockerlogs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/markojerkic/svarog/cmd/client/reader"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/stretchr/testify/suite"
+)
+
+type DockerLogsSuite struct {
+	suite.Suite
+
+	root   string
+	output chan *rpc.LogLine
+	cancel context.CancelFunc
+	wg     *sync.WaitGroup
+}
+
+// Before each
+func (s *DockerLogsSuite) SetupTest() {
+	s.root = s.T().TempDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.root, "containers"), 0o755))
+	s.output = make(chan *rpc.LogLine, 100)
+	s.cancel = nil
+}
+
+// After each
+func (s *DockerLogsSuite) TearDownTest() {
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+}
+
+// start runs a reader over the suite's Docker root.
+func (s *DockerLogsSuite) start(config reader.DockerConfig) {
+	config.Root = s.root
+	config.PollInterval = 10 * time.Millisecond
+
+	dockerReader, err := reader.NewDockerReader(config, s.output)
+	s.Require().NoError(err)
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg = &sync.WaitGroup{}
+	s.wg.Add(1)
+	go dockerReader.Run(ctx, s.wg)
+}
+
+// container creates the directory, config and an empty log of a container.
+func (s *DockerLogsSuite) container(id string, name string, labels map[string]string) string {
+	dir := filepath.Join(s.root, "containers", id)
+	s.Require().NoError(os.MkdirAll(dir, 0o755))
+
+	config, err := json.Marshal(map[string]any{
+		"ID":   id,
+		"Name": "/" + name,
+		"Config": map[string]any{
+			"Image":  "nginx:1.27",
+			"Labels": labels,
+		},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "config.v2.json"), config, 0o644))
+
+	logFile := filepath.Join(dir, id+"-json.log")
+	s.Require().NoError(os.WriteFile(logFile, nil, 0o644))
+	return logFile
+}
+
+// write appends json-file entries to a container log.
+func (s *DockerLogsSuite) write(logFile string, stream string, messages ...string) {
+	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0o644)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	for _, message := range messages {
+		entry, err := json.Marshal(map[string]string{
+			"log":    message,
+			"stream": stream,
+			"time":   time.Now().UTC().Format(time.RFC3339Nano),
+		})
+		s.Require().NoError(err)
+		_, err = fmt.Fprintf(file, "%s\n", entry)
+		s.Require().NoError(err)
+	}
+}
+
+// receive waits for a line sent by the reader.
+func (s *DockerLogsSuite) receive() *rpc.LogLine {
+	select {
+	case line := <-s.output:
+		return line
+	case <-time.After(5 * time.Second):
+		s.FailNow("no log line received")
+		return nil
+	}
+}
+
+func (s *DockerLogsSuite) noMoreLines() {
+	select {
+	case line := <-s.output:
+		s.Failf("unexpected log line", "%q", line.Message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,58 @@
+package embedded
+
+import (
+	"context"
+	"strings"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/nats-io/nats.go"
+)
+
+func (s *EmbeddedServerSuite) TestInProcessConnectionWithJetStream() {
+	ns, err := natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{
+		ConfigFile: s.configFile,
+		StoreDir:   s.storeDir,
+	})
+	s.Require().NoError(err)
+	defer func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	}()
+
+	conn, err := natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		JWT:             s.userJwt,
+		Seed:            s.userSeed,
+		EnableJetStream: true,
+		JetStreamConfig: natsconn.JetStreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}},
+		InProcessServer: ns,
+	})
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	ack, err := conn.JetStream.Publish(context.Background(), "logs.project.client", []byte(`{}`))
+	s.Require().NoError(err)
+	s.Equal("LOGS", ack.Stream)
+
+	stream, err := conn.JetStream.Stream(context.Background(), "LOGS")
+	s.Require().NoError(err)
+	s.True(strings.HasPrefix(ns.JetStreamConfig().StoreDir, s.storeDir), "store dir should be overridden")
+	s.Equal(uint64(1), stream.CachedInfo().State.Msgs)
+}
+
+func (s *EmbeddedServerSuite) TestClientPortStaysReachable() {
+	ns, err := natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{
+		ConfigFile: s.configFile,
+		StoreDir:   s.storeDir,
+	})
+	s.Require().NoError(err)
+	defer func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	}()
+
+	external, err := nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(s.userJwt, s.userSeed))
+	s.Require().NoError(err)
+	defer external.Close()
+
+	s.True(external.IsConnected())
+}
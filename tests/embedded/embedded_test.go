@@ -0,0 +1,11 @@
+package embedded
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestEmbeddedServerSuite(t *testing.T) {
+	suite.Run(t, new(EmbeddedServerSuite))
+}
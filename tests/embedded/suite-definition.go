@@ -0,0 +1,35 @@
+package embedded
+
+import (
+	"path/filepath"
+
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/stretchr/testify/suite"
+)
+
+type EmbeddedServerSuite struct {
+	suite.Suite
+
+	configFile string
+	storeDir   string
+	userJwt    string
+	userSeed   string
+}
+
+// SetupSuite writes a nats-server.conf the same way nats-setup init does.
+func (s *EmbeddedServerSuite) SetupSuite() {
+	dir := s.T().TempDir()
+	s.storeDir = filepath.Join(dir, "jetstream")
+
+	trust, err := natstrust.Generate()
+	s.Require().NoError(err)
+
+	s.configFile = filepath.Join(dir, "nats-server.conf")
+	s.Require().NoError(trust.WriteServerConfig(s.configFile, natstrust.ServerConfigOptions{
+		Port:     -1,
+		StoreDir: "/data/jetstream",
+	}))
+
+	s.userJwt = trust.ServerUser.JWT
+	s.userSeed = trust.ServerUser.Seed
+}
@@ -0,0 +1,11 @@
+package grpcingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestGrpcIngestSuite(t *testing.T) {
+	suite.Run(t, new(GrpcIngestSuite))
+}
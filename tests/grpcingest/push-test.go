@@ -0,0 +1,229 @@
+package grpcingest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/markojerkic/svarog/cmd/client/config"
+	grpcclient "github.com/markojerkic/svarog/cmd/client/grpc-client"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (s *GrpcIngestSuite) TestClientPushesToJetStream() {
+	token := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(s.creds("api"))))
+	clientConfig, err := config.NewClientConfig("svarog+grpc://" + s.grpcAddr + "/logs.project.api?token=" + token)
+	s.Require().NoError(err)
+	s.Require().True(clientConfig.UsesGrpc())
+
+	lines := make(chan *rpc.LogLine, 1500)
+	for i := range 1200 {
+		lines <- &rpc.LogLine{
+			Message:    fmt.Sprintf("line %d", i),
+			Timestamp:  time.Now(),
+			Sequence:   i,
+			InstanceId: "instance",
+		}
+	}
+	close(lines)
+
+	client := grpcclient.NewGrpcClient(clientConfig, lines)
+	done := make(chan struct{})
+	go func() {
+		client.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		s.FailNow("client did not finish pushing")
+	}
+
+	stream, err := s.natsConn.JetStream.Stream(context.Background(), "LOGS")
+	s.Require().NoError(err)
+	info, err := stream.Info(context.Background())
+	s.Require().NoError(err)
+	s.Equal(uint64(1200), info.State.Msgs, "every line should be acked only after it was stored")
+
+	msg, err := stream.GetMsg(context.Background(), 1)
+	s.Require().NoError(err)
+	s.Equal("logs.project.api", msg.Subject)
+
+	var line rpc.LogLine
+	s.Require().NoError(json.Unmarshal(msg.Data, &line))
+	s.Equal("line 0", line.Message)
+}
+
+func (s *GrpcIngestSuite) TestRejectsTopicOutsideCredentials() {
+	err := s.pushOnce(s.creds("api"), "logs.project.other", true)
+	s.Equal(codes.PermissionDenied, status.Code(err), err)
+
+	err = s.pushOnce(s.creds("*"), "logs.project.worker-1", true)
+	s.NoError(err, "project-wide credentials may publish as any client")
+}
+
+func (s *GrpcIngestSuite) TestRejectsInvalidSignature() {
+	err := s.pushOnce(s.creds("api"), "logs.project.api", false)
+	s.Equal(codes.Unauthenticated, status.Code(err), err)
+}
+
+func (s *GrpcIngestSuite) TestRejectsReplayedNonce() {
+	creds := s.creds("api")
+	nonce := serverauth.NewNonce()
+
+	s.NoError(s.pushWithNonce(creds, "logs.project.api", nonce, true))
+
+	err := s.pushWithNonce(creds, "logs.project.api", nonce, true)
+	s.Equal(codes.Unauthenticated, status.Code(err), err)
+	s.ErrorContains(err, serverauth.ErrNonceReused)
+}
+
+// pushOnce pushes a single batch and returns the stream error, if any.
+func (s *GrpcIngestSuite) pushOnce(creds string, topic string, validSignature bool) error {
+	return s.pushWithNonce(creds, topic, serverauth.NewNonce(), validSignature)
+}
+
+func (s *GrpcIngestSuite) pushWithNonce(creds string, topic string, nonce string, validSignature bool) error {
+	userJwt, seed, err := serverauth.ParseCredsFile(creds)
+	s.Require().NoError(err)
+
+	signedNonce := nonce
+	if !validSignature {
+		signedNonce = "not the nonce"
+	}
+	signature, err := serverauth.SignNonce(seed, signedNonce)
+	s.Require().NoError(err)
+
+	conn, err := grpc.NewClient(s.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		rpc.MetadataJWT, userJwt,
+		rpc.MetadataNonce, nonce,
+		rpc.MetadataSignature, signature,
+		rpc.MetadataTopic, topic,
+	)
+
+	stream, err := rpc.NewLogIngestClient(conn).Push(ctx)
+	s.Require().NoError(err)
+
+	err = stream.Send(&rpc.LogBatch{Id: 1, Lines: []rpc.LogLine{{Message: "hello", Timestamp: time.Now()}}})
+	if err != nil {
+		_, err = stream.Recv()
+		return err
+	}
+
+	ack, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.Equal(uint64(1), ack.Id)
+	s.Empty(ack.Error)
+
+	s.Require().NoError(stream.CloseSend())
+	_, err = stream.Recv()
+	s.ErrorIs(err, io.EOF)
+	return nil
+}
+
+func (s *GrpcIngestSuite) TestClientPublishesLinesAsTheirClient() {
+	token := url.QueryEscape(base64.StdEncoding.EncodeToString([]byte(s.creds("*"))))
+	clientConfig, err := config.NewClientConfig("svarog+grpc://" + s.grpcAddr + "/logs.project.*?token=" + token)
+	s.Require().NoError(err)
+	s.Require().True(clientConfig.IsWildcard())
+	clientConfig.ClientId = "host"
+
+	lines := make(chan *rpc.LogLine, 10)
+	for i, clientId := range []string{"", "web", "web", "db.primary"} {
+		lines <- &rpc.LogLine{
+			Message:    fmt.Sprintf("line %d", i),
+			Timestamp:  time.Now(),
+			Sequence:   i,
+			InstanceId: "instance",
+			ClientId:   clientId,
+		}
+	}
+	close(lines)
+
+	client := grpcclient.NewGrpcClient(clientConfig, lines)
+	done := make(chan struct{})
+	go func() {
+		client.Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		s.FailNow("client did not finish pushing")
+	}
+
+	stream, err := s.natsConn.JetStream.Stream(context.Background(), "LOGS")
+	s.Require().NoError(err)
+
+	subjects := []string{}
+	for sequence := range uint64(4) {
+		msg, err := stream.GetMsg(context.Background(), sequence+1)
+		s.Require().NoError(err)
+		subjects = append(subjects, msg.Subject)
+	}
+	s.Equal([]string{"logs.project.host", "logs.project.web", "logs.project.web", "logs.project.db-primary"}, subjects)
+}
+
+func (s *GrpcIngestSuite) TestResentBatchIsStoredOnce() {
+	userJwt, seed, err := serverauth.ParseCredsFile(s.creds("api"))
+	s.Require().NoError(err)
+	nonce := serverauth.NewNonce()
+	signature, err := serverauth.SignNonce(seed, nonce)
+	s.Require().NoError(err)
+
+	conn, err := grpc.NewClient(s.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		rpc.MetadataJWT, userJwt,
+		rpc.MetadataNonce, nonce,
+		rpc.MetadataSignature, signature,
+		rpc.MetadataTopic, "logs.project.api",
+	)
+
+	stream, err := rpc.NewLogIngestClient(conn).Push(ctx)
+	s.Require().NoError(err)
+
+	// A client resends a batch whose ack reported an error, lines stored
+	// by the first attempt must not be stored again
+	batch := &rpc.LogBatch{Id: 7, Lines: []rpc.LogLine{
+		{Message: "first", Timestamp: time.Now()},
+		{Message: "second", Timestamp: time.Now()},
+	}}
+	for range 2 {
+		s.Require().NoError(stream.Send(batch))
+		ack, err := stream.Recv()
+		s.Require().NoError(err)
+		s.Empty(ack.Error)
+	}
+	s.Require().NoError(stream.CloseSend())
+
+	jsStream, err := s.natsConn.JetStream.Stream(context.Background(), "LOGS")
+	s.Require().NoError(err)
+	info, err := jsStream.Info(context.Background())
+	s.Require().NoError(err)
+	s.Equal(uint64(2), info.State.Msgs)
+}
@@ -0,0 +1,89 @@
+package grpcingest
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/rpc"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+)
+
+type GrpcIngestSuite struct {
+	suite.Suite
+
+	natsServer   *server.Server
+	natsConn     *natsconn.NatsConnection
+	grpcServer   *grpc.Server
+	grpcAddr     string
+	credsService *serverauth.NatsCredentialService
+}
+
+// Before each
+func (s *GrpcIngestSuite) SetupTest() {
+	dir := s.T().TempDir()
+	configFile := filepath.Join(dir, "nats-server.conf")
+
+	trust, err := natstrust.Generate()
+	s.Require().NoError(err)
+	s.Require().NoError(trust.WriteServerConfig(configFile, natstrust.ServerConfigOptions{
+		Port:     -1,
+		StoreDir: filepath.Join(dir, "jetstream"),
+	}))
+
+	s.natsServer, err = natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{ConfigFile: configFile})
+	s.Require().NoError(err)
+
+	s.natsConn, err = natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		JWT:             trust.ServerUser.JWT,
+		Seed:            trust.ServerUser.Seed,
+		EnableJetStream: true,
+		JetStreamConfig: natsconn.JetStreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}},
+		InProcessServer: s.natsServer,
+	})
+	s.Require().NoError(err)
+
+	s.credsService, err = serverauth.NewNatsCredentialService(
+		trust.AccountSigning.Seed,
+		trust.Account.Public,
+		"",
+		&testutils.NoopProjectService{},
+		nil,
+	)
+	s.Require().NoError(err)
+
+	verifier, err := serverauth.NewCredentialVerifier(trust.AccountSigning.Seed, trust.Account.Public, nil)
+	s.Require().NoError(err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.grpcAddr = listener.Addr().String()
+
+	s.grpcServer = grpc.NewServer()
+	rpc.RegisterLogIngestServer(s.grpcServer, ingest.NewGrpcIngestServer(s.natsConn, verifier))
+	go s.grpcServer.Serve(listener)
+}
+
+// After each
+func (s *GrpcIngestSuite) TearDownTest() {
+	s.grpcServer.Stop()
+	s.natsConn.Close()
+	s.natsServer.Shutdown()
+	s.natsServer.WaitForShutdown()
+}
+
+func (s *GrpcIngestSuite) creds(clientId string) string {
+	creds, err := s.credsService.GenerateUserCreds(context.Background(), serverauth.CredentialGenerationRequest{
+		ProjectID: "project",
+		ClientID:  clientId,
+	})
+	s.Require().NoError(err)
+	return creds
+}
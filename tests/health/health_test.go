@@ -0,0 +1,11 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestHealthSuite(t *testing.T) {
+	suite.Run(t, new(HealthSuite))
+}
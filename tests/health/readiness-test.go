@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"errors"
+
+	"github.com/markojerkic/svarog/internal/lib/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *HealthSuite) TestReadyWithHealthyDependencies() {
+	t := s.T()
+
+	service := health.NewHealthService(
+		health.MongoCheck(s.MongoClient),
+		health.NatsCheck(s.NatsConn.Conn),
+		health.StreamCheck(s.NatsConn.JetStream, "LOGS"),
+	)
+
+	report := service.Ready(context.Background())
+	assert.Equal(t, health.StatusOk, report.Status)
+	assert.Len(t, report.Checks, 3)
+	for name, check := range report.Checks {
+		assert.Equal(t, health.StatusOk, check.Status, "check %s failed: %s", name, check.Error)
+	}
+}
+
+func (s *HealthSuite) TestReadyWithMissingStream() {
+	t := s.T()
+
+	service := health.NewHealthService(
+		health.MongoCheck(s.MongoClient),
+		health.StreamCheck(s.NatsConn.JetStream, "DOES_NOT_EXIST"),
+	)
+
+	report := service.Ready(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusOk, report.Checks["mongo"].Status)
+	assert.Equal(t, health.StatusFail, report.Checks["jetstream"].Status)
+	assert.NotEmpty(t, report.Checks["jetstream"].Error)
+}
+
+func (s *HealthSuite) TestStreamsCheckReportsMissingStreams() {
+	t := s.T()
+
+	service := health.NewHealthService(
+		health.StreamsCheck(s.NatsConn.JetStream, func(ctx context.Context) ([]string, error) {
+			return []string{"LOGS", "LOGS_missing-project"}, nil
+		}),
+	)
+
+	report := service.Ready(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Contains(t, report.Checks["jetstream"].Error, "LOGS_missing-project")
+}
+
+func (s *HealthSuite) TestSaturationCheck() {
+	t := s.T()
+
+	used := 0
+	service := health.NewHealthService(
+		health.SaturationCheck("backlog", 0.9, func() int { return used }, 100),
+		health.Check{Name: "custom", Check: func(ctx context.Context) error { return nil }},
+	)
+
+	assert.Equal(t, health.StatusOk, service.Ready(context.Background()).Status)
+
+	used = 95
+	report := service.Ready(context.Background())
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusFail, report.Checks["backlog"].Status)
+	assert.Equal(t, health.StatusOk, report.Checks["custom"].Status)
+
+	service.AddCheck(health.Check{Name: "broken", Check: func(ctx context.Context) error { return errors.New("broken") }})
+	assert.Equal(t, "broken", service.Ready(context.Background()).Checks["broken"].Error)
+}
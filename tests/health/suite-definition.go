@@ -0,0 +1,17 @@
+package health
+
+import (
+	"github.com/markojerkic/svarog/tests/testutils"
+)
+
+type HealthSuite struct {
+	testutils.BaseSuite
+}
+
+func (s *HealthSuite) SetupSuite() {
+	s.BaseSuite.SetupSuite()
+}
+
+func (s *HealthSuite) TearDownSuite() {
+	s.BaseSuite.TearDownSuite()
+}
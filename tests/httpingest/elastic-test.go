@@ -0,0 +1,110 @@
+package httpingest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type elasticBulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]map[string]any `json:"items"`
+}
+
+func (s *HttpIngestSuite) bulk(path string, token string, body string) (int, elasticBulkResponse) {
+	response := s.post(path, token, "application/x-ndjson", []byte(body))
+	s.Equal("Elasticsearch", response.Header().Get("X-Elastic-Product"))
+
+	var bulkResponse elasticBulkResponse
+	if response.Code == http.StatusOK {
+		s.Require().NoError(json.Unmarshal(response.Body.Bytes(), &bulkResponse))
+	}
+	return response.Code, bulkResponse
+}
+
+func (s *HttpIngestSuite) TestElasticBulk() {
+	body := `{"index":{"_index":"api-2026.10.19"}}
+{"@timestamp":"2026-10-19T10:00:00.123Z","message":"request done","log":{"level":"WARN"},"host":{"name":"web-1"},"http":{"status":502},"duration":0.25}
+{"create":{}}
+{"log":"from fluent bit\n","stream":"stderr"}
+{"delete":{"_index":"api","_id":"1"}}
+{"update":{"_index":"api","_id":"2"}}
+{"doc":{"message":"changed"}}
+`
+
+	status, response := s.bulk("/elastic/default-index/_bulk", s.token("api"), body)
+	s.Require().Equal(http.StatusOK, status)
+	s.True(response.Errors)
+	s.Require().Len(response.Items, 4)
+	s.EqualValues(201, response.Items[0]["index"]["status"])
+	s.Equal("api-2026.10.19", response.Items[0]["index"]["_index"])
+	s.NotEmpty(response.Items[0]["index"]["_id"])
+	s.EqualValues(201, response.Items[1]["create"]["status"])
+	s.Equal("default-index", response.Items[1]["create"]["_index"])
+	s.EqualValues(400, response.Items[2]["delete"]["status"])
+	s.EqualValues(400, response.Items[3]["update"]["status"])
+
+	lines := s.received()
+	s.Require().Len(lines, 2)
+
+	line := lines[0]
+	s.Equal("project", line.ProjectId)
+	s.Equal("api", line.ClientId)
+	s.Equal("web-1", line.InstanceId)
+	s.Equal("request done", line.Message)
+	s.Equal("warn", line.Level)
+	s.True(time.Date(2026, 10, 19, 10, 0, 0, 123000000, time.UTC).Equal(line.Timestamp))
+	// Fields are sent to JetStream as JSON, numbers come back as float64
+	s.Equal(map[string]any{"status": float64(502)}, line.Fields["http"])
+	s.Equal(0.25, line.Fields["duration"])
+	s.NotContains(line.Fields, "log", "the level is not stored twice")
+
+	s.Equal("from fluent bit\n", lines[1].Message)
+	s.Equal("stderr", lines[1].Fields["stream"])
+}
+
+func (s *HttpIngestSuite) TestElasticBulkUsesIndexAsClient() {
+	body := `{"index":{"_index":"worker.jobs-2026.10.19"}}
+{"message":"started"}
+`
+
+	status, response := s.bulk("/elastic/_bulk", s.token("*"), body)
+	s.Require().Equal(http.StatusOK, status)
+	s.False(response.Errors)
+
+	lines := s.received()
+	s.Require().Len(lines, 1)
+	s.Equal("worker-jobs", lines[0].ClientId)
+}
+
+func (s *HttpIngestSuite) TestElasticBulkRejectsMalformedBody() {
+	status, _ := s.bulk("/elastic/_bulk", s.token("api"), "not json\n")
+	s.Equal(http.StatusBadRequest, status)
+
+	status, _ = s.bulk("/elastic/_bulk", s.token("api"), `{"index":{"_index":"api"}}`)
+	s.Equal(http.StatusBadRequest, status)
+	s.Empty(s.received())
+}
+
+func (s *HttpIngestSuite) TestElasticApiKeyAndInfo() {
+	apiKey := base64.StdEncoding.EncodeToString([]byte("svarog:" + s.token("api")))
+
+	request := httptest.NewRequest(http.MethodGet, "/elastic", nil)
+	request.Header.Set(echo.HeaderAuthorization, "ApiKey "+apiKey)
+	recorder := httptest.NewRecorder()
+	s.echo.ServeHTTP(recorder, request)
+	s.Require().Equal(http.StatusOK, recorder.Code, recorder.Body.String())
+	s.Contains(recorder.Body.String(), "You Know, for Search")
+
+	request = httptest.NewRequest(http.MethodPost, "/elastic/_bulk", bytes.NewReader([]byte("{\"index\":{\"_index\":\"api\"}}\n{\"message\":\"hi\"}\n")))
+	request.Header.Set(echo.HeaderAuthorization, "ApiKey "+apiKey)
+	recorder = httptest.NewRecorder()
+	s.echo.ServeHTTP(recorder, request)
+	s.Require().Equal(http.StatusOK, recorder.Code, recorder.Body.String())
+	s.Len(s.received(), 1)
+}
@@ -0,0 +1,11 @@
+package httpingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestHttpIngestSuite(t *testing.T) {
+	suite.Run(t, new(HttpIngestSuite))
+}
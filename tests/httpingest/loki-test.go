@@ -0,0 +1,120 @@
+package httpingest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lokiProtoPush encodes a logproto.PushRequest with a single stream the way
+// Promtail sends it.
+func lokiProtoPush(labels string, timestamp time.Time, lines ...string) []byte {
+	var stream []byte
+	stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+	stream = protowire.AppendString(stream, labels)
+
+	for _, line := range lines {
+		var ts []byte
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(timestamp.Unix()))
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(timestamp.Nanosecond()))
+
+		var metadata []byte
+		metadata = protowire.AppendTag(metadata, 1, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, "trace_id")
+		metadata = protowire.AppendTag(metadata, 2, protowire.BytesType)
+		metadata = protowire.AppendString(metadata, "abc123")
+
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, ts)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, line)
+		entry = protowire.AppendTag(entry, 3, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, metadata)
+
+		stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+		stream = protowire.AppendBytes(stream, entry)
+	}
+
+	var push []byte
+	push = protowire.AppendTag(push, 1, protowire.BytesType)
+	push = protowire.AppendBytes(push, stream)
+
+	return snappy.Encode(nil, push)
+}
+
+func (s *HttpIngestSuite) TestLokiProtobufPush() {
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+	body := lokiProtoPush(`{job="varlogs", host="web-1", level="WARNING", filename="/var/log/app.log"}`, timestamp, "first", "second")
+
+	response := s.post("/loki/api/v1/push", s.token("api"), "application/x-protobuf", body)
+	s.Require().Equal(http.StatusNoContent, response.Code, response.Body.String())
+
+	lines := s.received()
+	s.Require().Len(lines, 2)
+	s.Equal("project", lines[0].ProjectId)
+	s.Equal("api", lines[0].ClientId)
+	s.Equal("web-1", lines[0].InstanceId)
+	s.Equal("first", lines[0].Message)
+	s.Equal("second", lines[1].Message)
+	s.Equal("warn", lines[0].Level)
+	s.True(timestamp.Equal(lines[0].Timestamp))
+	s.Equal("abc123", lines[0].TraceId)
+	s.Equal(map[string]any{"job": "varlogs", "filename": "/var/log/app.log"}, lines[0].Fields)
+}
+
+func (s *HttpIngestSuite) TestLokiJSONPushPicksClientFromLabels() {
+	body := []byte(`{"streams":[{"stream":{"service_name":"worker","pod":"worker-7f9"},"values":[["1700000000000000000","started",{"user":"42"}]]}]}`)
+
+	response := s.post("/loki/api/v1/push", s.token("*"), echo.MIMEApplicationJSON, body)
+	s.Require().Equal(http.StatusNoContent, response.Code, response.Body.String())
+
+	lines := s.received()
+	s.Require().Len(lines, 1)
+	s.Equal("worker", lines[0].ClientId)
+	s.Equal("worker-7f9", lines[0].InstanceId)
+	s.Equal("started", lines[0].Message)
+	s.Equal(int64(1700000000), lines[0].Timestamp.Unix())
+	s.Equal(map[string]any{"user": "42"}, lines[0].Fields)
+}
+
+func (s *HttpIngestSuite) TestLokiRejectsInvalidBody() {
+	response := s.post("/loki/api/v1/push", s.token("api"), "application/x-protobuf", []byte("not snappy"))
+	s.Equal(http.StatusBadRequest, response.Code)
+
+	response = s.post("/loki/api/v1/push", "", echo.MIMEApplicationJSON, []byte(`{"streams":[]}`))
+	s.Equal(http.StatusUnauthorized, response.Code)
+	s.Empty(s.received())
+}
+
+func (s *HttpIngestSuite) TestLokiRejectsOversizedSnappyBody() {
+	// A snappy header declaring a 4 GiB body, followed by nothing
+	body := []byte{0xff, 0xff, 0xff, 0xff, 0x0f}
+	_, err := ingest.DecodeLokiPush(body, false, 16<<20)
+	s.Error(err)
+
+	response := s.post("/loki/api/v1/push", s.token("api"), "application/x-protobuf", body)
+	s.Equal(http.StatusBadRequest, response.Code)
+	s.Empty(s.received())
+}
+
+func (s *HttpIngestSuite) TestParseLokiLabels() {
+	labels, err := ingest.ParseLokiLabels(`{app="api", msg="say \"hi\"",empty=""}`)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"app": "api", "msg": `say "hi"`, "empty": ""}, labels)
+
+	labels, err = ingest.ParseLokiLabels(`{}`)
+	s.Require().NoError(err)
+	s.Empty(labels)
+
+	_, err = ingest.ParseLokiLabels(`app="api"`)
+	s.Error(err)
+	_, err = ingest.ParseLokiLabels(`{app=api}`)
+	s.Error(err)
+}
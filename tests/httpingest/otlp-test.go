@@ -0,0 +1,113 @@
+package httpingest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func stringAttribute(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpRequest(resource []*commonpb.KeyValue, records ...*logspb.LogRecord) *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: resource},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "checkout"},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+func (s *HttpIngestSuite) TestOtlpProtobufExport() {
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	request := otlpRequest(
+		[]*commonpb.KeyValue{stringAttribute("service.instance.id", "pod-1"), stringAttribute("deployment.environment", "prod")},
+		&logspb.LogRecord{
+			TimeUnixNano:   uint64(timestamp.UnixNano()),
+			SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_WARN2,
+			Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "payment slow"}},
+			Attributes: []*commonpb.KeyValue{
+				stringAttribute("http.route", "/pay"),
+				{Key: "retries", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 3}}},
+			},
+			TraceId: []byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+			SpanId:  []byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		},
+		&logspb.LogRecord{
+			SeverityText: "ERROR",
+			Body:         &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: []*commonpb.KeyValue{stringAttribute("event", "failed")}}}},
+		},
+	)
+	body, err := proto.Marshal(request)
+	s.Require().NoError(err)
+
+	response := s.post("/v1/logs", s.token("api"), "application/x-protobuf", body)
+	s.Require().Equal(http.StatusOK, response.Code, response.Body.String())
+	s.Equal("application/x-protobuf", response.Header().Get(echo.HeaderContentType))
+
+	lines := s.received()
+	s.Require().Len(lines, 2)
+
+	line := lines[0]
+	s.Equal("project", line.ProjectId)
+	s.Equal("api", line.ClientId)
+	s.Equal("pod-1", line.InstanceId)
+	s.Equal("payment slow", line.Message)
+	s.Equal("warn", line.Level)
+	s.True(timestamp.Equal(line.Timestamp))
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", line.TraceId)
+	s.Equal("00f067aa0ba902b7", line.SpanId)
+	s.Equal("/pay", line.Fields["http_route"])
+	s.Equal(float64(3), line.Fields["retries"])
+	s.Equal("prod", line.Fields["deployment_environment"])
+	s.Equal("checkout", line.Fields["otel_scope"])
+
+	s.Equal("error", lines[1].Level)
+	s.JSONEq(`{"event":"failed"}`, lines[1].Message)
+	s.Empty(lines[1].TraceId)
+}
+
+func (s *HttpIngestSuite) TestOtlpJSONExportPicksClientFromResource() {
+	body := []byte(`{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"worker"}}]},
+		"scopeLogs":[{"logRecords":[{"timeUnixNano":"1700000000000000000","severityNumber":9,"body":{"stringValue":"started"}}]}]}]}`)
+
+	response := s.post("/v1/logs", s.token("*"), echo.MIMEApplicationJSON, body)
+	s.Require().Equal(http.StatusOK, response.Code, response.Body.String())
+	s.JSONEq(`{}`, response.Body.String())
+
+	lines := s.received()
+	s.Require().Len(lines, 1)
+	s.Equal("worker", lines[0].ClientId)
+	s.Equal("worker", lines[0].InstanceId)
+	s.Equal("info", lines[0].Level)
+	s.Equal("started", lines[0].Message)
+	s.Equal(int64(1700000000), lines[0].Timestamp.Unix())
+}
+
+func (s *HttpIngestSuite) TestOtlpRequiresValidToken() {
+	body, err := proto.Marshal(otlpRequest(nil, &logspb.LogRecord{}))
+	s.Require().NoError(err)
+
+	s.Equal(http.StatusUnauthorized, s.post("/v1/logs", "", "application/x-protobuf", body).Code)
+	s.Equal(http.StatusUnauthorized, s.post("/v1/logs", "svarog_unknown", "application/x-protobuf", body).Code)
+
+	token := s.token("api")
+	credentials, err := s.registry.GetCredentials(context.Background(), "project")
+	s.Require().NoError(err)
+	_, err = s.registry.Revoke(context.Background(), credentials[len(credentials)-1].ID.Hex())
+	s.Require().NoError(err)
+
+	s.Equal(http.StatusUnauthorized, s.post("/v1/logs", token, "application/x-protobuf", body).Code)
+	s.Empty(s.received())
+}
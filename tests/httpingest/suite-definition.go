@@ -0,0 +1,78 @@
+package httpingest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/http/handlers"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/stretchr/testify/suite"
+)
+
+type HttpIngestSuite struct {
+	suite.Suite
+
+	echo         *echo.Echo
+	registry     *testutils.MemoryCredentialRegistry
+	tokenService *serverauth.IngestTokenService
+	ingestCh     chan db.LogLineWithHost
+}
+
+// Before each
+func (s *HttpIngestSuite) SetupTest() {
+	s.registry = &testutils.MemoryCredentialRegistry{}
+
+	var err error
+	s.tokenService, err = serverauth.NewIngestTokenService(&testutils.NoopProjectService{}, s.registry)
+	s.Require().NoError(err)
+
+	s.ingestCh = make(chan db.LogLineWithHost, 100)
+	receiver := ingest.NewReceiver(testutils.StartIngestJetStream(s.T(), s.ingestCh), nil)
+
+	s.echo = echo.New()
+	handlers.NewOtlpRouter(s.tokenService, receiver, s.echo)
+	handlers.NewLokiRouter(s.tokenService, receiver, s.echo)
+	handlers.NewElasticRouter(s.tokenService, receiver, s.echo)
+}
+
+func (s *HttpIngestSuite) token(clientId string) string {
+	token, err := s.tokenService.Generate(context.Background(), serverauth.CredentialGenerationRequest{
+		ProjectID: "project",
+		ClientID:  clientId,
+	})
+	s.Require().NoError(err)
+	return token.Token
+}
+
+func (s *HttpIngestSuite) post(path string, token string, contentType string, body []byte) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, contentType)
+	if token != "" {
+		request.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.echo.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// received returns the lines consumed from JetStream, it returns once no
+// line arrived for a while.
+func (s *HttpIngestSuite) received() []db.LogLineWithHost {
+	lines := []db.LogLineWithHost{}
+	for {
+		select {
+		case line := <-s.ingestCh:
+			lines = append(lines, line)
+		case <-time.After(200 * time.Millisecond):
+			return lines
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package logquery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestLogQuerySuite(t *testing.T) {
+	suite.Run(t, new(LogQuerySuite))
+}
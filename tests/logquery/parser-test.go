@@ -0,0 +1,110 @@
+package logquery
+
+import (
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+)
+
+func (s *LogQuerySuite) TestParseExample() {
+	root := s.parse(`level:error AND instance:api-2 AND "connection reset" AND NOT /health/ AND fields.status>=500`)
+
+	and, ok := root.(logquery.And)
+	s.Require().True(ok)
+	s.Require().Len(and.Terms, 5)
+
+	s.Equal(logquery.Compare{Position: 0, Field: logquery.FieldLevel, Op: logquery.OpMatch, Value: "error"}, and.Terms[0])
+	s.Equal(logquery.Compare{Position: 16, Field: logquery.FieldInstance, Op: logquery.OpMatch, Value: "api-2"}, and.Terms[1])
+	s.Equal(logquery.Text{Position: 35, Value: "connection reset"}, and.Terms[2])
+	s.Equal(logquery.Not{Position: 58, Term: logquery.Regex{Position: 62, Field: logquery.FieldMessage, Pattern: "health"}}, and.Terms[3])
+
+	status, ok := and.Terms[4].(logquery.Compare)
+	s.Require().True(ok)
+	s.Equal(logquery.Field("fields.status"), status.Field)
+	s.Equal(logquery.OpGreaterEqual, status.Op)
+	s.Require().NotNil(status.Number)
+	s.Equal(500.0, *status.Number)
+}
+
+func (s *LogQuerySuite) TestParsePrecedence() {
+	root := s.parse(`timeout OR level:warn reset`)
+
+	or, ok := root.(logquery.Or)
+	s.Require().True(ok, "OR binds weaker than the implicit AND")
+	s.Require().Len(or.Terms, 2)
+	s.Equal(logquery.Text{Position: 0, Value: "timeout"}, or.Terms[0])
+
+	and, ok := or.Terms[1].(logquery.And)
+	s.Require().True(ok)
+	s.Len(and.Terms, 2)
+
+	root = s.parse(`(timeout OR reset) NOT level:debug`)
+	and, ok = root.(logquery.And)
+	s.Require().True(ok)
+	s.IsType(logquery.Or{}, and.Terms[0])
+	s.IsType(logquery.Not{}, and.Terms[1])
+}
+
+func (s *LogQuerySuite) TestParseFieldValues() {
+	s.Equal(logquery.Compare{Position: 0, Field: logquery.FieldLevel, Op: logquery.OpMatch, Value: "warn"}, s.parse("level:WARNING"), "levels are normalized")
+	s.Equal(logquery.Compare{Position: 0, Field: logquery.FieldClient, Op: logquery.OpEqual, Value: "billing api"}, s.parse(`client="billing api"`))
+	s.Equal(logquery.Regex{Position: 0, Field: logquery.FieldInstance, Pattern: `api-\d (eu|us)`}, s.parse(`instance:/api-\d (eu|us)/`))
+	s.Equal(logquery.Text{Position: 0, Value: "GET /v2/orders"}, s.parse(`message:"GET /v2/orders"`))
+	s.Equal(logquery.Regex{Position: 0, Field: logquery.FieldMessage, Pattern: "a/b"}, s.parse(`/a\/b/`))
+	s.Equal(logquery.Text{Position: 0, Value: `say "hi"`}, s.parse(`"say \"hi\""`))
+	s.Equal(logquery.Text{Position: 0, Value: "/v2/ord"}, s.parse(`/v2/ord`), "paths aren't regular expressions")
+	s.Equal(logquery.Text{Position: 0, Value: "/health"}, s.parse(`/health`))
+
+	trace, ok := s.parse("trace_id:4bf92f3577b34da6").(logquery.Compare)
+	s.Require().True(ok)
+	s.Equal(logquery.FieldTrace, trace.Field)
+}
+
+func (s *LogQuerySuite) TestParseEmpty() {
+	parsed, err := logquery.Parse("   ")
+	s.Require().NoError(err)
+	s.True(parsed.IsEmpty())
+}
+
+func (s *LogQuerySuite) TestTextTerms() {
+	parsed, err := logquery.Parse(`timeout "connection reset" level:error (a OR b)`)
+	s.Require().NoError(err)
+
+	terms, rest := parsed.TextTerms()
+	s.Equal([]string{"timeout", "connection reset"}, terms)
+	and, ok := rest.(logquery.And)
+	s.Require().True(ok)
+	s.Len(and.Terms, 2)
+
+	parsed, err = logquery.Parse(`NOT timeout`)
+	s.Require().NoError(err)
+	terms, rest = parsed.TextTerms()
+	s.Empty(terms, "negated words can't use the text index")
+	s.IsType(logquery.Not{}, rest)
+}
+
+func (s *LogQuerySuite) TestSyntaxErrors() {
+	cases := []struct {
+		query    string
+		position int
+		message  string
+	}{
+		{`level:error AND`, 15, "unexpected end of query"},
+		{`(timeout OR reset`, 17, "missing )"},
+		{`timeout)`, 7, "unexpected )"},
+		{`"connection reset`, 0, "unterminated quote"},
+		{`NOT message:/health`, 12, "unterminated regular expression"},
+		{`levle:error`, 0, `unknown field "levle", quote the word to search for it`},
+		{`level:`, 6, "missing value of level"},
+		{`fields.status>=high`, 15, ">= needs a number"},
+		{`level>error`, 0, "level can't be compared with >"},
+		{`message:/a(/`, 0, "invalid regular expression: missing closing ): `a(`"},
+		{`a OR OR b`, 5, `unexpected "OR"`},
+		{`/(a+)+$/`, 0, "invalid regular expression: repeated groups can't contain repetitions, like (a+)+"},
+		{`level:/(?:x|\w*){2,}/`, 0, "invalid regular expression: repeated groups can't contain repetitions, like (a+)+"},
+	}
+
+	for _, c := range cases {
+		syntaxErr := s.syntaxError(c.query)
+		s.Equal(c.position, syntaxErr.Pos, c.query)
+		s.Equal(c.message, syntaxErr.Message, c.query)
+	}
+}
@@ -0,0 +1,27 @@
+package logquery
+
+import (
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+	"github.com/stretchr/testify/suite"
+)
+
+type LogQuerySuite struct {
+	suite.Suite
+}
+
+// parse parses a query that must be valid.
+func (s *LogQuerySuite) parse(query string) logquery.Node {
+	parsed, err := logquery.Parse(query)
+	s.Require().NoError(err, query)
+	return parsed.Root
+}
+
+// syntaxError parses a query that must be invalid.
+func (s *LogQuerySuite) syntaxError(query string) *logquery.SyntaxError {
+	_, err := logquery.Parse(query)
+	s.Require().Error(err, query)
+
+	syntaxErr, ok := err.(*logquery.SyntaxError)
+	s.Require().True(ok, "expected a syntax error, got %v", err)
+	return syntaxErr
+}
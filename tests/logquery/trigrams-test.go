@@ -0,0 +1,48 @@
+package logquery
+
+import (
+	"strings"
+
+	"github.com/markojerkic/svarog/internal/lib/logquery"
+)
+
+func (s *LogQuerySuite) TestTrigrams() {
+	s.Equal([]string{"get", "et ", "t /", " /v", "/v2"}, logquery.Trigrams("GET /v2"))
+	s.Equal([]string{"aaa"}, logquery.Trigrams("aaaaa"), "trigrams are distinct")
+	s.Equal([]string{"čša", "šaž"}, logquery.Trigrams("ČŠAŽ"), "trigrams are characters, not bytes")
+	s.Empty(logquery.Trigrams("ab"))
+
+	long := strings.Repeat("a", 10000) + "xyz"
+	s.Equal([]string{"aaa", "aax", "axy", "xyz"}, logquery.Trigrams(long), "long messages are indexed whole")
+}
+
+func (s *LogQuerySuite) TestRequiredTrigrams() {
+	cases := []struct {
+		query    string
+		trigrams []string
+	}{
+		{`/v2/ord`, []string{"/v2", "v2/", "2/o", "/or", "ord"}},
+		{`ab`, nil},
+		{`"/v2/ord"`, []string{"/v2", "v2/", "2/o", "/or", "ord"}},
+		{`a1b2 level:error`, []string{"a1b", "1b2"}},
+		{`/order-(\d+)-done/`, []string{"ord", "rde", "der", "er-", "-do", "don", "one"}},
+		{`/(?i)POST \/api/`, []string{"pos", "ost", "st ", "t /", " /a", "/ap", "api"}},
+		{`/(abc|def)/`, nil},
+		{`/x(abc)+/`, []string{"abc"}},
+		{`abc OR def`, nil},
+		{`NOT abc`, nil},
+		{`instance:/abc/`, nil},
+	}
+
+	for _, c := range cases {
+		parsed, err := logquery.Parse(c.query)
+		s.Require().NoError(err, c.query)
+		if c.trigrams == nil {
+			s.Empty(parsed.RequiredTrigrams(), c.query)
+			_, err := parsed.SubstringTrigrams()
+			s.Error(err, "substring search without trigrams is refused: %s", c.query)
+		} else {
+			s.Equal(c.trigrams, parsed.RequiredTrigrams(), c.query)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/markojerkic/svarog/internal/lib/metrics"
+)
+
+func (s *MetricsSuite) TestLineLabelsLimitClients() {
+	for i := 0; i < 200; i++ {
+		project, client := metrics.LineLabels("limited-project", fmt.Sprintf("client-%d", i))
+		s.Equal("limited-project", project)
+		s.Equal(fmt.Sprintf("client-%d", i), client)
+	}
+
+	_, client := metrics.LineLabels("limited-project", "one-client-too-many")
+	s.Equal("other", client, "clients over the limit are counted together")
+
+	_, client = metrics.LineLabels("limited-project", "client-7")
+	s.Equal("client-7", client, "clients seen before keep their series")
+}
+
+func (s *MetricsSuite) TestLineLabelsTruncateLongIds() {
+	_, client := metrics.LineLabels("truncated-project", strings.Repeat("č", 100))
+	s.Equal(strings.Repeat("č", 32), client)
+}
@@ -0,0 +1,11 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestMetricsSuite(t *testing.T) {
+	suite.Run(t, new(MetricsSuite))
+}
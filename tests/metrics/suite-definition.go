@@ -0,0 +1,9 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsSuite struct {
+	suite.Suite
+}
@@ -0,0 +1,11 @@
+package natstrust
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNatsTrustSuite(t *testing.T) {
+	suite.Run(t, new(NatsTrustSuite))
+}
@@ -0,0 +1,111 @@
+package natstrust
+
+import (
+	"context"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func (s *NatsTrustSuite) startServer() *server.Server {
+	ns, err := natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{ConfigFile: s.configFile})
+	s.Require().NoError(err)
+	return ns
+}
+
+func stopServer(ns *server.Server) {
+	ns.Shutdown()
+	ns.WaitForShutdown()
+}
+
+func (s *NatsTrustSuite) clientCreds() []byte {
+	credsService, err := serverauth.NewNatsCredentialService(
+		s.trust.AccountSigning.Seed,
+		s.trust.Account.Public,
+		"",
+		&testutils.NoopProjectService{},
+		nil,
+	)
+	s.Require().NoError(err)
+
+	creds, err := credsService.GenerateUserCreds(context.Background(), serverauth.CredentialGenerationRequest{
+		ProjectID: "project",
+		ClientID:  "client",
+	})
+	s.Require().NoError(err)
+	return []byte(creds)
+}
+
+func connectWithCreds(ns *server.Server, creds []byte) (*nats.Conn, error) {
+	userJwt, err := jwt.ParseDecoratedJWT(creds)
+	if err != nil {
+		return nil, err
+	}
+	kp, err := jwt.ParseDecoratedNKey(creds)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, err
+	}
+
+	return nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(userJwt, string(seed)), nats.MaxReconnects(0))
+}
+
+func (s *NatsTrustSuite) TestRotateAccountInvalidatesCredentialsAndKeepsStreams() {
+	ns := s.startServer()
+
+	conn, err := natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		JWT:             s.trust.ServerUser.JWT,
+		Seed:            s.trust.ServerUser.Seed,
+		EnableJetStream: true,
+		JetStreamConfig: natsconn.JetStreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}},
+		InProcessServer: ns,
+	})
+	s.Require().NoError(err)
+
+	oldCreds := s.clientCreds()
+	client, err := connectWithCreds(ns, oldCreds)
+	s.Require().NoError(err, "credentials signed with the signing key should be accepted")
+	s.Require().NoError(client.Publish("logs.project.client", []byte(`{}`)))
+	s.Require().NoError(client.Flush())
+	client.Close()
+
+	_, err = conn.JetStream.Publish(context.Background(), "logs.project.client", []byte(`{}`))
+	s.Require().NoError(err)
+	conn.Close()
+	stopServer(ns)
+
+	s.Require().NoError(s.trust.RotateAccount())
+	s.Require().NoError(s.trust.UpdateServerConfig(s.configFile))
+	s.Require().NoError(s.trust.Save(s.secretsDir))
+
+	ns = s.startServer()
+	defer stopServer(ns)
+
+	_, err = connectWithCreds(ns, oldCreds)
+	s.Error(err, "credentials signed with the old signing key should be rejected")
+
+	client, err = connectWithCreds(ns, s.clientCreds())
+	s.Require().NoError(err)
+	client.Close()
+
+	conn, err = natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		JWT:             s.trust.ServerUser.JWT,
+		Seed:            s.trust.ServerUser.Seed,
+		EnableJetStream: true,
+		JetStreamConfig: natsconn.JetStreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}},
+		InProcessServer: ns,
+	})
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	stream, err := conn.JetStream.Stream(context.Background(), "LOGS")
+	s.Require().NoError(err)
+	s.GreaterOrEqual(stream.CachedInfo().State.Msgs, uint64(1), "stream should survive the rotation")
+}
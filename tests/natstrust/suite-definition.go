@@ -0,0 +1,35 @@
+package natstrust
+
+import (
+	"path/filepath"
+
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/stretchr/testify/suite"
+)
+
+type NatsTrustSuite struct {
+	suite.Suite
+
+	dir        string
+	secretsDir string
+	configFile string
+	storeDir   string
+	trust      natstrust.Trust
+}
+
+// Before each
+func (s *NatsTrustSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.secretsDir = filepath.Join(s.dir, "nats-secrets")
+	s.configFile = filepath.Join(s.dir, "nats-server.conf")
+	s.storeDir = filepath.Join(s.dir, "jetstream")
+
+	trust, err := natstrust.Generate()
+	s.Require().NoError(err)
+	s.Require().NoError(trust.Save(s.secretsDir))
+	s.Require().NoError(trust.WriteServerConfig(s.configFile, natstrust.ServerConfigOptions{
+		Port:     -1,
+		StoreDir: s.storeDir,
+	}))
+	s.trust = trust
+}
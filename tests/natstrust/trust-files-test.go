@@ -0,0 +1,49 @@
+package natstrust
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+)
+
+func (s *NatsTrustSuite) TestSaveAndLoad() {
+	loaded, err := natstrust.Load(s.secretsDir)
+	s.Require().NoError(err)
+
+	s.Equal(s.trust, loaded)
+	s.True(natstrust.Exists(s.secretsDir))
+
+	info, err := os.Stat(filepath.Join(s.secretsDir, natstrust.AccountSigningSeedFile))
+	s.Require().NoError(err)
+	s.Equal(os.FileMode(0o600), info.Mode().Perm())
+}
+
+func (s *NatsTrustSuite) TestUpdateServerConfigKeepsEdits() {
+	content, err := os.ReadFile(s.configFile)
+	s.Require().NoError(err)
+	edited := strings.Replace(string(content), "jetstream {", "max_payload: 2MB\n\njetstream {", 1)
+	s.Require().NoError(os.WriteFile(s.configFile, []byte(edited), 0o600))
+
+	oldAccountJwt := s.trust.Account.JWT
+	s.Require().NoError(s.trust.RotateAccount())
+	s.Require().NoError(s.trust.UpdateServerConfig(s.configFile))
+
+	content, err = os.ReadFile(s.configFile)
+	s.Require().NoError(err)
+	s.Contains(string(content), "max_payload: 2MB")
+	s.Contains(string(content), s.trust.Account.JWT)
+	s.NotContains(string(content), oldAccountJwt)
+}
+
+func (s *NatsTrustSuite) TestEnvFileReferencesSecrets() {
+	env := s.trust.EnvFile(s.secretsDir, false)
+	s.Contains(env, "NATS_ACCOUNT_PUBLIC_KEY="+s.trust.Account.Public)
+	s.Contains(env, "NATS_ACCOUNT_SEED_FILE="+filepath.Join(s.secretsDir, natstrust.AccountSigningSeedFile))
+	s.NotContains(env, s.trust.AccountSigning.Seed)
+
+	inline := s.trust.EnvFile(s.secretsDir, true)
+	s.Contains(inline, "NATS_ACCOUNT_SEED="+s.trust.AccountSigning.Seed)
+	s.Contains(inline, "NATS_SERVER_USER_SEED="+s.trust.ServerUser.Seed)
+}
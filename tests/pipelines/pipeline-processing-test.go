@@ -0,0 +1,100 @@
+package pipelines
+
+import (
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *PipelinesSuite) process(stages []pipelines.Stage, message string) pipelines.Result {
+	compiled, err := pipelines.Compile(pipelines.Pipeline{Stages: stages})
+	require.NoError(s.T(), err)
+
+	result := pipelines.Result{Message: message}
+	compiled.Process(&result)
+	return result
+}
+
+func (s *PipelinesSuite) TestGrokNginxAccessLog() {
+	t := s.T()
+
+	result := s.process([]pipelines.Stage{
+		{Type: pipelines.StageGrok, Pattern: "%{NGINXACCESS}"},
+	}, `127.0.0.1 - - [10/Oct/2025:13:55:36 +0000] "GET /api/v2/orders?id=1 HTTP/1.1" 502 612 "-" "curl/8.0"`)
+
+	assert.Equal(t, "127.0.0.1", result.Fields["client"])
+	assert.Equal(t, "GET", result.Fields["method"])
+	assert.Equal(t, "/api/v2/orders?id=1", result.Fields["path"])
+	assert.Equal(t, int64(502), result.Fields["status"])
+	assert.Equal(t, int64(612), result.Fields["bytes"])
+}
+
+func (s *PipelinesSuite) TestRegexExtraction() {
+	t := s.T()
+
+	result := s.process([]pipelines.Stage{
+		{Type: pipelines.StageRegex, Pattern: `^\[(?P<level>\w+)\] (?P<msg>.*)$`},
+		{Type: pipelines.StageLevel},
+		{Type: pipelines.StageMessage, Field: "msg"},
+	}, "[WARNING] disk almost full")
+
+	assert.Equal(t, "warn", result.Level)
+	assert.Equal(t, "disk almost full", result.Message)
+}
+
+func (s *PipelinesSuite) TestKeyValueAndRename() {
+	t := s.T()
+
+	result := s.process([]pipelines.Stage{
+		{Type: pipelines.StageKeyValue},
+		{Type: pipelines.StageRename, Mapping: map[string]string{"lvl": "level"}},
+		{Type: pipelines.StageLevel, Mapping: map[string]string{"e": "error"}},
+	}, `lvl=e msg="connection reset by peer" user=42`)
+
+	assert.Equal(t, "connection reset by peer", result.Fields["msg"])
+	assert.Equal(t, "42", result.Fields["user"])
+	assert.NotContains(t, result.Fields, "lvl")
+	assert.Equal(t, "error", result.Level)
+}
+
+func (s *PipelinesSuite) TestJsonStage() {
+	t := s.T()
+
+	result := s.process([]pipelines.Stage{
+		{Type: pipelines.StageJSON},
+		{Type: pipelines.StageLevel, Field: "severity"},
+	}, `{"severity":"ERROR","status":500}`)
+
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, float64(500), result.Fields["status"])
+}
+
+func (s *PipelinesSuite) TestDropStage() {
+	t := s.T()
+
+	result := s.process([]pipelines.Stage{
+		{Type: pipelines.StageDrop, Pattern: `GET /health`},
+		{Type: pipelines.StageKeyValue},
+	}, `GET /health 200`)
+
+	assert.True(t, result.Dropped)
+	assert.Empty(t, result.Fields)
+}
+
+func (s *PipelinesSuite) TestCompileInvalidStages() {
+	t := s.T()
+
+	testCases := []pipelines.Stage{
+		{Type: "unknown"},
+		{Type: pipelines.StageRegex},
+		{Type: pipelines.StageRegex, Pattern: "("},
+		{Type: pipelines.StageGrok, Pattern: "%{NOPE:field}"},
+		{Type: pipelines.StageRename},
+		{Type: pipelines.StageMessage},
+	}
+
+	for i, stage := range testCases {
+		_, err := pipelines.Compile(pipelines.Pipeline{Stages: []pipelines.Stage{stage}})
+		assert.Error(t, err, "Test case %d failed", i)
+	}
+}
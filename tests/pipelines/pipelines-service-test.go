@@ -0,0 +1,132 @@
+package pipelines
+
+import (
+	"context"
+
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *PipelinesSuite) TestSaveAndGetPipelinesForClient() {
+	t := s.T()
+	ctx := context.Background()
+
+	_, err := s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ProjectId: "project",
+		ClientId:  "api",
+		Name:      "api pipeline",
+		Enabled:   true,
+		Stages:    `[{"type":"kv"}]`,
+	})
+	require.NoError(t, err)
+
+	_, err = s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ProjectId: "project",
+		ClientId:  pipelines.AllClients,
+		Name:      "project pipeline",
+		Enabled:   true,
+		Stages:    `[{"type":"json"}]`,
+	})
+	require.NoError(t, err)
+
+	_, err = s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ProjectId: "project",
+		ClientId:  "api",
+		Name:      "disabled pipeline",
+		Enabled:   false,
+		Stages:    `[]`,
+	})
+	require.NoError(t, err)
+
+	forClient, err := s.pipelinesService.GetPipelinesForClient(ctx, "project", "api")
+	require.NoError(t, err)
+	require.Len(t, forClient, 2)
+	assert.Equal(t, "project pipeline", forClient[0].Name)
+	assert.Equal(t, "api pipeline", forClient[1].Name)
+
+	forOtherClient, err := s.pipelinesService.GetPipelinesForClient(ctx, "project", "worker")
+	require.NoError(t, err)
+	assert.Len(t, forOtherClient, 1)
+
+	all, err := s.pipelinesService.GetPipelines(ctx, "project")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
+
+func (s *PipelinesSuite) TestSaveInvalidPipeline() {
+	t := s.T()
+
+	testCases := []string{
+		`not json`,
+		`[{"type":"regex","pattern":"("}]`,
+	}
+
+	for i, stages := range testCases {
+		_, err := s.pipelinesService.SavePipeline(context.Background(), types.PipelineForm{
+			ProjectId: "project",
+			ClientId:  "api",
+			Name:      "invalid",
+			Stages:    stages,
+		})
+		assert.Error(t, err, "Test case %d failed", i)
+	}
+}
+
+func (s *PipelinesSuite) TestUpdateAndDeletePipeline() {
+	t := s.T()
+	ctx := context.Background()
+
+	pipeline, err := s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ProjectId: "project",
+		ClientId:  "api",
+		Name:      "pipeline",
+		Enabled:   true,
+		Stages:    `[]`,
+	})
+	require.NoError(t, err)
+
+	_, err = s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ID:        pipeline.ID.Hex(),
+		ProjectId: "project",
+		ClientId:  "api",
+		Name:      "renamed",
+		Enabled:   true,
+		Stages:    `[{"type":"kv"}]`,
+	})
+	require.NoError(t, err)
+
+	updated, err := s.pipelinesService.GetPipeline(ctx, pipeline.ID.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", updated.Name)
+	assert.Len(t, updated.Stages, 1)
+
+	require.NoError(t, s.pipelinesService.DeletePipeline(ctx, pipeline.ID.Hex()))
+	_, err = s.pipelinesService.GetPipeline(ctx, pipeline.ID.Hex())
+	assert.EqualError(t, err, pipelines.ErrPipelineNotFound)
+}
+
+func (s *PipelinesSuite) TestProcessorAppliesPipelines() {
+	t := s.T()
+	ctx := context.Background()
+
+	processor := pipelines.NewProcessor(s.pipelinesService)
+
+	result := processor.Process(ctx, "project", "api", "level=error msg=boom")
+	assert.Empty(t, result.Level)
+
+	_, err := s.pipelinesService.SavePipeline(ctx, types.PipelineForm{
+		ProjectId: "project",
+		ClientId:  "api",
+		Name:      "logfmt",
+		Enabled:   true,
+		Stages:    `[{"type":"kv"},{"type":"level"},{"type":"message","field":"msg"}]`,
+	})
+	require.NoError(t, err)
+	processor.Invalidate("project")
+
+	result = processor.Process(ctx, "project", "api", "level=error msg=boom")
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, "boom", result.Message)
+}
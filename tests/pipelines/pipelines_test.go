@@ -0,0 +1,11 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestPipelinesSuite(t *testing.T) {
+	suite.Run(t, new(PipelinesSuite))
+}
@@ -0,0 +1,34 @@
+package pipelines
+
+import (
+	"context"
+
+	"github.com/markojerkic/svarog/internal/lib/pipelines"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PipelinesSuite struct {
+	testutils.BaseSuite
+
+	pipelinesService    pipelines.PipelinesService
+	pipelinesCollection *mongo.Collection
+}
+
+func (s *PipelinesSuite) SetupSuite() {
+	s.BaseSuite.SetupSuite()
+
+	s.pipelinesCollection = s.Collection("pipelines")
+	s.pipelinesService = pipelines.NewPipelinesService(s.pipelinesCollection)
+}
+
+func (s *PipelinesSuite) TearDownTest() {
+	_, err := s.pipelinesCollection.DeleteMany(context.Background(), bson.M{})
+	assert.NoError(s.T(), err)
+}
+
+func (s *PipelinesSuite) TearDownSuite() {
+	s.BaseSuite.TearDownSuite()
+}
@@ -0,0 +1,77 @@
+package projects
+
+import (
+	"context"
+
+	"github.com/markojerkic/svarog/internal/lib/projects"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (p *ProjectsSuite) TestRegisterClient() {
+	t := p.Suite.T()
+	ctx := context.Background()
+
+	project, err := p.ProjectsService.CreateProject(ctx, "registration", []string{"api"})
+	require.NoError(t, err)
+	projectId := project.ID.Hex()
+
+	status, err := p.ProjectsService.RegisterClient(ctx, projectId, "api")
+	require.NoError(t, err)
+	assert.Equal(t, projects.ClientApproved, status)
+
+	status, err = p.ProjectsService.RegisterClient(ctx, projectId, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, projects.ClientApproved, status)
+
+	project, err = p.ProjectsService.GetProject(ctx, projectId)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"api", "worker-1"}, project.Clients)
+	assert.True(t, p.ProjectsService.ProjectExists(ctx, projectId, "worker-1"))
+}
+
+func (p *ProjectsSuite) TestRegisterClientWithApproval() {
+	t := p.Suite.T()
+	ctx := context.Background()
+
+	project, err := p.ProjectsService.CreateProject(ctx, "approval", []string{})
+	require.NoError(t, err)
+	projectId := project.ID.Hex()
+	require.NoError(t, p.ProjectsService.SetRequireClientApproval(ctx, projectId, true))
+
+	status, err := p.ProjectsService.RegisterClient(ctx, projectId, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, projects.ClientPending, status)
+
+	status, err = p.ProjectsService.RegisterClient(ctx, projectId, "worker-2")
+	require.NoError(t, err)
+	assert.Equal(t, projects.ClientPending, status)
+
+	require.NoError(t, p.ProjectsService.ApproveClient(ctx, projectId, "worker-1"))
+	require.NoError(t, p.ProjectsService.RejectClient(ctx, projectId, "worker-2"))
+
+	project, err = p.ProjectsService.GetProject(ctx, projectId)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"worker-1"}, project.Clients)
+	assert.Empty(t, project.PendingClients)
+	assert.Equal(t, []string{"worker-2"}, project.RejectedClients)
+
+	status, err = p.ProjectsService.RegisterClient(ctx, projectId, "worker-2")
+	require.NoError(t, err)
+	assert.Equal(t, projects.ClientRejected, status, "rejected clients should stay rejected")
+}
+
+func (p *ProjectsSuite) TestWildcardClientNeedsExistingProject() {
+	t := p.Suite.T()
+	ctx := context.Background()
+
+	project, err := p.ProjectsService.CreateProject(ctx, "wildcard", []string{"api"})
+	require.NoError(t, err)
+
+	assert.True(t, p.ProjectsService.ProjectExists(ctx, project.ID.Hex(), "*"))
+	require.NoError(t, p.ProjectsService.DeleteProject(ctx, project.ID.Hex()))
+	assert.False(t, p.ProjectsService.ProjectExists(ctx, project.ID.Hex(), "*"))
+
+	_, err = p.ProjectsService.RegisterClient(ctx, project.ID.Hex(), "worker-1")
+	assert.EqualError(t, err, projects.ErrProjectNotFound)
+}
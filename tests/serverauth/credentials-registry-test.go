@@ -0,0 +1,202 @@
+package serverauth
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/server/types"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *NatsAuthSuite) TestGeneratedCredentialsAreRecorded() {
+	t := s.T()
+
+	credsService, err := serverauth.NewNatsCredentialService(s.trustForTest().AccountSigning.Seed, "", "", &testutils.NoopProjectService{}, s.registry)
+	require.NoError(t, err)
+
+	expiry := time.Now().Add(time.Hour)
+	creds, err := credsService.GenerateUserCreds(context.Background(), serverauth.CredentialGenerationRequest{
+		ProjectID: "registry-project",
+		ClientID:  "registry-client",
+		Expiry:    types.NullableDate{Time: expiry, Valid: true},
+		IssuedBy:  "admin",
+	})
+	require.NoError(t, err)
+
+	userJwt, _, err := serverauth.ParseCredsFile(creds)
+	require.NoError(t, err)
+	claims, err := jwt.DecodeUserClaims(userJwt)
+	require.NoError(t, err)
+
+	credentials, err := s.registry.GetCredentials(context.Background(), "registry-project")
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+
+	credential := credentials[0]
+	assert.Equal(t, claims.Subject, credential.PublicKey)
+	assert.Equal(t, "registry-client", credential.ClientId)
+	assert.Equal(t, "admin", credential.IssuedBy)
+	require.NotNil(t, credential.ExpiresAt)
+	assert.WithinDuration(t, expiry, *credential.ExpiresAt, time.Second)
+	assert.False(t, credential.Revoked())
+	assert.Nil(t, credential.LastSeenAt)
+}
+
+func (s *NatsAuthSuite) TestRevokeCredential() {
+	t := s.T()
+	ctx := context.Background()
+
+	active, err := s.registry.Record(ctx, serverauth.IssuedCredential{PublicKey: "UACTIVE", ProjectId: "p", ClientId: "c"})
+	require.NoError(t, err)
+	expiredAt := time.Now().Add(-time.Hour)
+	expired, err := s.registry.Record(ctx, serverauth.IssuedCredential{PublicKey: "UEXPIRED", ProjectId: "p", ClientId: "c", ExpiresAt: &expiredAt})
+	require.NoError(t, err)
+
+	revoked, err := s.registry.Revoke(ctx, active.ID.Hex())
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+
+	again, err := s.registry.Revoke(ctx, active.ID.Hex())
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt), "revoking twice should keep the first revocation time")
+
+	_, err = s.registry.Revoke(ctx, expired.ID.Hex())
+	require.NoError(t, err)
+
+	revokedList, err := s.registry.GetRevoked(ctx)
+	require.NoError(t, err)
+	require.Len(t, revokedList, 1, "expired credentials don't need to stay on the revocation list")
+	assert.Equal(t, "UACTIVE", revokedList[0].PublicKey)
+
+	_, err = s.registry.Revoke(ctx, "000000000000000000000000")
+	assert.EqualError(t, err, serverauth.ErrCredentialNotFound)
+}
+
+func (s *NatsAuthSuite) TestMarkSeen() {
+	t := s.T()
+	ctx := context.Background()
+
+	credential, err := s.registry.Record(ctx, serverauth.IssuedCredential{PublicKey: "USEEN", ProjectId: "p", ClientId: "c"})
+	require.NoError(t, err)
+
+	seenAt := time.Now().Truncate(time.Millisecond)
+	require.NoError(t, s.registry.MarkSeen(ctx, "USEEN", seenAt))
+
+	credential, err = s.registry.GetCredential(ctx, credential.ID.Hex())
+	require.NoError(t, err)
+	require.NotNil(t, credential.LastSeenAt)
+	assert.True(t, seenAt.Equal(*credential.LastSeenAt))
+}
+
+// trustForTest generates a fresh operator and account, unrelated to the NATS
+// container, for tests that run their own embedded server.
+func (s *NatsAuthSuite) trustForTest() natstrust.Trust {
+	trust, err := natstrust.Generate()
+	s.Require().NoError(err)
+	return trust
+}
+
+func (s *NatsAuthSuite) startEmbeddedServer(trust natstrust.Trust) *server.Server {
+	dir := s.T().TempDir()
+	configFile := filepath.Join(dir, "nats-server.conf")
+	s.Require().NoError(trust.WriteServerConfig(configFile, natstrust.ServerConfigOptions{
+		Port:     -1,
+		StoreDir: filepath.Join(dir, "jetstream"),
+	}))
+
+	ns, err := natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{ConfigFile: configFile})
+	s.Require().NoError(err)
+	s.T().Cleanup(func() {
+		ns.Shutdown()
+		ns.WaitForShutdown()
+	})
+	return ns
+}
+
+func (s *NatsAuthSuite) TestRevokedCredentialsAreRejected() {
+	t := s.T()
+	ctx := context.Background()
+
+	trust := s.trustForTest()
+	ns := s.startEmbeddedServer(trust)
+
+	// Keep the account loaded, as svarog's own connection does
+	serverConn, err := nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(trust.ServerUser.JWT, trust.ServerUser.Seed))
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	systemConn, err := nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(trust.SystemUser.JWT, trust.SystemUser.Seed))
+	require.NoError(t, err)
+	defer systemConn.Close()
+
+	revoker, err := serverauth.NewAccountRevoker(trust.Operator.Seed, trust.Account.JWT, systemConn, s.registry)
+	require.NoError(t, err)
+	_, err = revoker.TrackLastSeen()
+	require.NoError(t, err)
+
+	credsService, err := serverauth.NewNatsCredentialService(trust.AccountSigning.Seed, trust.Account.Public, "", &testutils.NoopProjectService{}, s.registry)
+	require.NoError(t, err)
+
+	issue := func(clientId string) (string, string) {
+		creds, err := credsService.GenerateUserCreds(ctx, serverauth.CredentialGenerationRequest{ProjectID: "revoke-project", ClientID: clientId})
+		require.NoError(t, err)
+		userJwt, seed, err := serverauth.ParseCredsFile(creds)
+		require.NoError(t, err)
+		return userJwt, seed
+	}
+	connect := func(userJwt, seed string) (*nats.Conn, error) {
+		return nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(userJwt, seed), nats.MaxReconnects(0))
+	}
+
+	revokedJwt, revokedSeed := issue("revoked-client")
+	keptJwt, keptSeed := issue("kept-client")
+
+	revokedConn, err := connect(revokedJwt, revokedSeed)
+	require.NoError(t, err, "credentials should work before they are revoked")
+	defer revokedConn.Close()
+
+	credentials, err := s.registry.GetCredentials(ctx, "revoke-project")
+	require.NoError(t, err)
+	require.Len(t, credentials, 2)
+
+	var toRevoke serverauth.IssuedCredential
+	for _, credential := range credentials {
+		if credential.ClientId == "revoked-client" {
+			toRevoke = credential
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		credential, err := s.registry.GetCredential(ctx, toRevoke.ID.Hex())
+		return err == nil && credential.LastSeenAt != nil
+	}, 5*time.Second, 50*time.Millisecond, "connecting should update last seen")
+
+	_, err = revoker.Revoke(ctx, toRevoke.ID.Hex())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return revokedConn.IsClosed()
+	}, 5*time.Second, 50*time.Millisecond, "the connection of a revoked credential should be closed")
+
+	_, err = connect(revokedJwt, revokedSeed)
+	assert.Error(t, err, "revoked credentials should be rejected")
+
+	keptConn, err := connect(keptJwt, keptSeed)
+	require.NoError(t, err, "other credentials should keep working")
+	keptConn.Close()
+
+	accountJwt, err := revoker.AccountJWT(ctx)
+	require.NoError(t, err)
+	claims, err := jwt.DecodeAccountClaims(accountJwt)
+	require.NoError(t, err)
+	assert.Contains(t, claims.Revocations, toRevoke.PublicKey)
+}
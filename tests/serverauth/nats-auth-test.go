@@ -2,7 +2,9 @@ package serverauth
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"testing"
 	"time"
 
 	"github.com/markojerkic/svarog/internal/lib/serverauth"
@@ -19,11 +21,32 @@ func (s *NatsAuthSuite) createTestProject(clientId string) (testProject string,
 	return project.ID.Hex(), fmt.Sprintf("logs.%s.%s", project.ID.Hex(), clientId)
 }
 
-func (s *NatsAuthSuite) connectWithCredentials(jwt string, seed string) (*nats.Conn, error) {
-	return nats.Connect(s.NatsAddr,
+func (s *NatsAuthSuite) connectWithCredentials(jwt string, seed string, opts ...nats.Option) (*nats.Conn, error) {
+	opts = append(opts,
 		nats.UserJWTAndSeed(jwt, seed),
 		nats.Timeout(5*time.Second),
 	)
+	return nats.Connect(s.NatsAddr, opts...)
+}
+
+// permissionErrors collects the errors the server reports asynchronously,
+// like publishes the credentials don't allow.
+func permissionErrors() (chan error, nats.Option) {
+	errs := make(chan error, 10)
+	return errs, nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+		errs <- err
+	})
+}
+
+// requirePermissionViolation waits for the server to report a permissions violation.
+func requirePermissionViolation(t *testing.T, errs chan error) {
+	select {
+	case err := <-errs:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Permissions Violation")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a permissions violation")
+	}
 }
 
 func (s *NatsAuthSuite) TestConnectWithValidCredentials() {
@@ -92,10 +115,10 @@ func (s *NatsAuthSuite) TestConnectWithExpiredCredentials() {
 func (s *NatsAuthSuite) TestPublishToWildcardClient() {
 	t := s.T()
 
-	// Create a project with a wildcard client
-	projectId, topic := s.createTestProject("*")
+	// Clients of the project don't have to be known in advance
+	projectId, _ := s.createTestProject("known-client")
 
-	creds, err := s.NatsCredsService.GenerateUserCreds(
+	clientConfig, err := s.NatsCredsService.GenerateConnString(
 		context.Background(),
 		serverauth.CredentialGenerationRequest{
 			ProjectID: projectId,
@@ -103,19 +126,31 @@ func (s *NatsAuthSuite) TestPublishToWildcardClient() {
 		},
 	)
 	require.NoError(t, err)
+	require.True(t, clientConfig.IsWildcard())
 
-	jwt, seed, err := serverauth.ParseCredsFile(creds)
+	creds, err := base64.StdEncoding.DecodeString(clientConfig.Creds)
+	require.NoError(t, err)
+	jwt, seed, err := serverauth.ParseCredsFile(string(creds))
 	assert.NoError(t, err)
-	nc, err := s.connectWithCredentials(jwt, seed)
+	errs, errorHandler := permissionErrors()
+	nc, err := s.connectWithCredentials(jwt, seed, errorHandler)
 	require.NoError(t, err)
 	defer nc.Close()
 
-	// Should be able to publish to the allowed topic
+	topic := clientConfig.PublishTopic("worker.1")
+	assert.Equal(t, fmt.Sprintf("logs.%s.worker-1", projectId), topic)
+
+	// Should be able to publish as any client of the project
 	err = nc.Publish(topic, []byte("test message"))
 	assert.NoError(t, err, "should publish to allowed topic")
 
 	err = nc.Flush()
 	assert.NoError(t, err)
+	assert.Empty(t, errs)
+
+	// But not to other projects
+	require.NoError(t, nc.Publish("logs.other.worker-1", []byte("test message")))
+	requirePermissionViolation(t, errs)
 }
 
 func (s *NatsAuthSuite) TestPublishToAllowedTopic() {
@@ -164,7 +199,8 @@ func (s *NatsAuthSuite) TestPublishToDisallowedTopic() {
 
 	jwt, seed, err := serverauth.ParseCredsFile(creds)
 	assert.NoError(t, err)
-	nc, err := s.connectWithCredentials(jwt, seed)
+	errs, errorHandler := permissionErrors()
+	nc, err := s.connectWithCredentials(jwt, seed, errorHandler)
 	require.NoError(t, err)
 	defer nc.Close()
 
@@ -172,19 +208,8 @@ func (s *NatsAuthSuite) TestPublishToDisallowedTopic() {
 	err = nc.Publish("logs.other.client", []byte("test message"))
 	require.NoError(t, err) // Publish itself doesn't fail
 
-	// Flush will fail or trigger permission error
-	err = nc.Flush()
-	// The connection may be closed due to permission violation
-	if err == nil {
-		// Give NATS time to process and close connection if needed
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	// Check if we received a permission error or connection was closed
-	lastErr := nc.LastError()
-	if lastErr != nil {
-		assert.Contains(t, lastErr.Error(), "Permissions Violation")
-	}
+	// The server reports the violation asynchronously
+	requirePermissionViolation(t, errs)
 }
 
 func (s *NatsAuthSuite) TestMultipleClientsWithDifferentPermissions() {
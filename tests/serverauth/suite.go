@@ -12,15 +12,18 @@ type NatsAuthSuite struct {
 	testutils.BaseSuite
 
 	credentialService *serverauth.NatsCredentialService
+	registry          *serverauth.MongoCredentialRegistry
 }
 
 func (s *NatsAuthSuite) SetupSuite() {
 	s.BaseSuite.SetupSuite()
+	s.registry = serverauth.NewCredentialRegistry(s.Collection("nats_credentials"))
 }
 
 func (s *NatsAuthSuite) TearDownTest() {
 	// Clean up projects between tests
 	_, _ = s.Collection("projects").DeleteMany(context.Background(), bson.M{})
+	_, _ = s.Collection("nats_credentials").DeleteMany(context.Background(), bson.M{})
 }
 
 func (s *NatsAuthSuite) TearDownSuite() {
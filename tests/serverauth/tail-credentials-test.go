@@ -0,0 +1,154 @@
+package serverauth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"github.com/markojerkic/svarog/internal/server/types"
+	websocket "github.com/markojerkic/svarog/internal/server/web-socket"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *NatsAuthSuite) TestTailCredentialsFollowLiveLines() {
+	t := s.T()
+	ctx := context.Background()
+
+	trust := s.trustForTest()
+	ns := s.startEmbeddedServer(trust)
+
+	serverConn, err := nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(trust.ServerUser.JWT, trust.ServerUser.Seed))
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	credsService, err := serverauth.NewNatsCredentialService(trust.AccountSigning.Seed, trust.Account.Public, "localhost:4222", &testutils.NoopProjectService{}, s.registry)
+	require.NoError(t, err)
+
+	tailCreds, err := credsService.GenerateTailCreds(ctx, serverauth.CredentialGenerationRequest{
+		ProjectID: "tail-project",
+		ClientID:  "tail-client",
+		Expiry:    types.NullableDate{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "live.logs.tail-project.tail-client", tailCreds.Subject)
+	assert.Equal(t, "nats://localhost:4222", tailCreds.ServerUrl)
+
+	userJwt, seed, err := serverauth.ParseCredsFile(tailCreds.Creds)
+	require.NoError(t, err)
+
+	permissionErrors := make(chan error, 10)
+	tailConn, err := nats.Connect(ns.ClientURL(),
+		nats.UserJWTAndSeed(userJwt, seed),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			permissionErrors <- err
+		}))
+	require.NoError(t, err)
+	defer tailConn.Close()
+
+	lines, err := tailConn.SubscribeSync(tailCreds.Subject)
+	require.NoError(t, err)
+	require.NoError(t, tailConn.Flush())
+
+	hub := websocket.NewWatchHub(serverConn)
+	renderer := websocket.NewWsLogLineRenderer(hub)
+	renderer.Render(ctx, types.StoredLog{
+		ID:        primitive.NewObjectID(),
+		LogLine:   "live line",
+		Timestamp: time.Now(),
+		Level:     "info",
+		Client:    types.StoredClient{ProjectId: "tail-project", ClientId: "tail-client", InstanceId: "instance"},
+	})
+	require.NoError(t, renderer.Shutdown(ctx))
+
+	msg, err := lines.NextMsg(5 * time.Second)
+	require.NoError(t, err, "tail credentials should receive live lines")
+
+	var line types.LiveLogLine
+	require.NoError(t, json.Unmarshal(msg.Data, &line))
+	assert.Equal(t, "live line", line.Message)
+	assert.Equal(t, "info", line.Level)
+	assert.Equal(t, "instance", line.InstanceId)
+
+	_, err = tailConn.SubscribeSync("live.logs.other-project.tail-client")
+	require.NoError(t, err)
+	require.NoError(t, tailConn.Publish("logs.tail-project.tail-client", []byte("{}")))
+	tailConn.Flush()
+
+	for range 2 {
+		select {
+		case err := <-permissionErrors:
+			assert.Contains(t, err.Error(), "Permissions Violation")
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a permissions violation")
+		}
+	}
+
+	credentials, err := s.registry.GetCredentials(ctx, "tail-project")
+	require.NoError(t, err)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, serverauth.CredentialScopeTail, credentials[0].Scope)
+}
+
+func (s *NatsAuthSuite) TestPublishCredentialsCantSubscribe() {
+	t := s.T()
+	ctx := context.Background()
+
+	trust := s.trustForTest()
+	ns := s.startEmbeddedServer(trust)
+
+	serverConn, err := nats.Connect(ns.ClientURL(), nats.UserJWTAndSeed(trust.ServerUser.JWT, trust.ServerUser.Seed))
+	require.NoError(t, err)
+	defer serverConn.Close()
+	serverJs, err := jetstream.New(serverConn)
+	require.NoError(t, err)
+	_, err = serverJs.CreateStream(ctx, jetstream.StreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}})
+	require.NoError(t, err)
+
+	credsService, err := serverauth.NewNatsCredentialService(trust.AccountSigning.Seed, trust.Account.Public, "localhost:4222", &testutils.NoopProjectService{}, s.registry)
+	require.NoError(t, err)
+	creds, err := credsService.GenerateUserCreds(ctx, serverauth.CredentialGenerationRequest{
+		ProjectID: "publish-project",
+		ClientID:  "*",
+	})
+	require.NoError(t, err)
+
+	userJwt, seed, err := serverauth.ParseCredsFile(creds)
+	require.NoError(t, err)
+	inboxPrefix, err := serverauth.InboxPrefix(userJwt)
+	require.NoError(t, err)
+
+	permissionErrors := make(chan error, 10)
+	clientConn, err := nats.Connect(ns.ClientURL(),
+		nats.UserJWTAndSeed(userJwt, seed),
+		nats.CustomInboxPrefix(inboxPrefix),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			permissionErrors <- err
+		}))
+	require.NoError(t, err)
+	defer clientConn.Close()
+
+	// JetStream acks arrive on the client's own inbox
+	clientJs, err := jetstream.New(clientConn)
+	require.NoError(t, err)
+	_, err = clientJs.Publish(ctx, "logs.publish-project.worker", []byte("{}"))
+	require.NoError(t, err, "publish credentials receive their acks")
+
+	for _, subject := range []string{"live.logs.other-project.worker", "ws.logs.other-project.worker", "_INBOX.>"} {
+		_, err = clientConn.SubscribeSync(subject)
+		require.NoError(t, err)
+		clientConn.Flush()
+
+		select {
+		case err := <-permissionErrors:
+			assert.Contains(t, err.Error(), "Permissions Violation", subject)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected a permissions violation subscribing to %s", subject)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package snippets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/markojerkic/svarog/internal/lib/snippets"
+	"gopkg.in/yaml.v3"
+)
+
+func (s *SnippetsSuite) TestRendersEveryKind() {
+	rendered := s.render("api")
+
+	for _, kind := range []snippets.Kind{
+		snippets.KindDocker,
+		snippets.KindCompose,
+		snippets.KindSystemd,
+		snippets.KindKubernetes,
+		snippets.KindShell,
+	} {
+		s.Contains(rendered, kind)
+	}
+}
+
+func (s *SnippetsSuite) TestKubernetesKeepsConnStringInSecret() {
+	snippet := s.render("API_server.v2")[snippets.KindKubernetes]
+	manifest := s.file(snippet, "api-server-v2-svarog.yaml")
+
+	s.NotContains(manifest, s.clientConfig.GetConnString(), "the connection string should only be in the secret data")
+
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(manifest)))
+	documents := []map[string]any{}
+	for {
+		var document map[string]any
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		s.Require().NoError(err)
+		documents = append(documents, document)
+	}
+	s.Require().Len(documents, 2)
+
+	secret := documents[0]
+	s.Equal("Secret", secret["kind"])
+	s.Equal("api-server-v2-svarog", secret["metadata"].(map[string]any)["name"])
+	encoded := secret["data"].(map[string]any)["SVAROG_CONN_STRING"].(string)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	s.Require().NoError(err)
+	s.Equal(s.clientConfig.GetConnString(), string(decoded))
+
+	deployment := documents[1]
+	s.Equal("Deployment", deployment["kind"])
+	s.Contains(manifest, "image: registry.example.com/svarog-client:1.0")
+	s.Contains(manifest, "secretKeyRef")
+}
+
+func (s *SnippetsSuite) TestComposeReadsEnvFile() {
+	snippet := s.render("api")[snippets.KindCompose]
+
+	var compose struct {
+		Services map[string]struct {
+			Image   string   `yaml:"image"`
+			EnvFile []string `yaml:"env_file"`
+		} `yaml:"services"`
+	}
+	composeFile := s.file(snippet, "docker-compose.yml")
+	s.Require().NoError(yaml.Unmarshal([]byte(composeFile), &compose))
+	s.NotContains(composeFile, s.clientConfig.GetConnString())
+
+	sidecar, ok := compose.Services["api-svarog"]
+	s.Require().True(ok, "compose file should have a sidecar service")
+	s.Equal("registry.example.com/svarog-client:1.0", sidecar.Image)
+	s.Equal([]string{"svarog.env"}, sidecar.EnvFile)
+
+	s.Equal("SVAROG_CONN_STRING="+s.clientConfig.GetConnString()+"\n", s.file(snippet, "svarog.env"))
+}
+
+func (s *SnippetsSuite) TestSystemdUsesEnvironmentFile() {
+	snippet := s.render("api")[snippets.KindSystemd]
+
+	unit := s.file(snippet, "/etc/systemd/system/api-svarog.service")
+	s.Contains(unit, "EnvironmentFile=/etc/svarog/api.env")
+	s.NotContains(unit, s.clientConfig.GetConnString())
+
+	envFile := s.file(snippet, "/etc/svarog/api.env")
+	s.Contains(envFile, "install -m 600")
+	s.Contains(envFile, "SVAROG_CONN_STRING="+s.clientConfig.GetConnString())
+}
+
+func (s *SnippetsSuite) TestShellQuotesConnString() {
+	s.clientConfig.ServerAddr = "it's.example.com:4222"
+	shell := s.file(s.render("api")[snippets.KindShell], "Shell")
+
+	quoted := `'` + strings.ReplaceAll(s.clientConfig.GetConnString(), `'`, `'"'"'`) + `'`
+	s.Contains(shell, "SVAROG_CONN_STRING="+quoted+" svarog-client")
+}
+
+func (s *SnippetsSuite) TestResourceName() {
+	cases := map[string]string{
+		"api":                          "api",
+		"API_server.v2":                "api-server-v2",
+		"--worker--":                   "worker",
+		"*":                            "app",
+		strings.Repeat("a", 50) + "-b": strings.Repeat("a", 40),
+	}
+
+	for clientId, expected := range cases {
+		s.Equal(expected, snippets.ResourceName(clientId), clientId)
+	}
+}
@@ -0,0 +1,11 @@
+package snippets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSnippetsSuite(t *testing.T) {
+	suite.Run(t, new(SnippetsSuite))
+}
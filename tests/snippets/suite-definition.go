@@ -0,0 +1,46 @@
+package snippets
+
+import (
+	"github.com/markojerkic/svarog/cmd/client/config"
+	"github.com/markojerkic/svarog/internal/lib/snippets"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnippetsSuite struct {
+	suite.Suite
+
+	generator    *snippets.Generator
+	clientConfig config.ClientConfig
+}
+
+func (s *SnippetsSuite) SetupTest() {
+	s.generator = snippets.NewGenerator("registry.example.com/svarog-client:1.0")
+	s.clientConfig = config.ClientConfig{
+		Protocol:   "nats",
+		ServerAddr: "logs.example.com:4222",
+		Topic:      "logs.project.api",
+		Creds:      "c2VjcmV0K2NyZWRzPT0=",
+	}
+}
+
+// render returns the snippets by kind.
+func (s *SnippetsSuite) render(clientId string) map[snippets.Kind]snippets.Snippet {
+	rendered, err := s.generator.Render(s.clientConfig, clientId)
+	s.Require().NoError(err)
+
+	byKind := map[snippets.Kind]snippets.Snippet{}
+	for _, snippet := range rendered {
+		byKind[snippet.Kind] = snippet
+	}
+	return byKind
+}
+
+func (s *SnippetsSuite) file(snippet snippets.Snippet, name string) string {
+	for _, file := range snippet.Files {
+		if file.Name == name {
+			return file.Content
+		}
+	}
+	s.FailNow("file not found", "%s has no file %s", snippet.Kind, name)
+	return ""
+}
@@ -0,0 +1,67 @@
+package streams
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *StreamsSuite) TestEnsureProjectStreamUsesConfiguredLimits() {
+	t := s.T()
+	ctx := context.Background()
+
+	require.NoError(t, s.projectStreams.EnsureProjectStream(ctx, "limits-project"))
+
+	stream, err := s.projectStreams.JetStream.Stream(ctx, "LOGS_limits-project")
+	require.NoError(t, err)
+
+	config := stream.CachedInfo().Config
+	assert.Equal(t, []string{"logs.limits-project.>"}, config.Subjects)
+	assert.Equal(t, int64(10*1024*1024), config.MaxBytes)
+	assert.Equal(t, time.Hour, config.MaxAge)
+}
+
+func (s *StreamsSuite) TestEnsureProjectStreamKeepsChangedLimits() {
+	t := s.T()
+	ctx := context.Background()
+
+	require.NoError(t, s.projectStreams.EnsureProjectStream(ctx, "changed-project"))
+	require.NoError(t, s.projectStreams.UpdateStreamLimits(ctx, "LOGS_changed-project", 1024*1024, 2*time.Hour))
+	require.NoError(t, s.projectStreams.EnsureProjectStream(ctx, "changed-project"))
+
+	stream, err := s.projectStreams.JetStream.Stream(ctx, "LOGS_changed-project")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), stream.CachedInfo().Config.MaxBytes)
+	assert.Equal(t, 2*time.Hour, stream.CachedInfo().Config.MaxAge)
+}
+
+func (s *StreamsSuite) TestStreamStatesReportMessages() {
+	t := s.T()
+	ctx := context.Background()
+
+	require.NoError(t, s.projectStreams.EnsureProjectStream(ctx, "state-project"))
+	for range 5 {
+		_, err := s.projectStreams.JetStream.Publish(ctx, "logs.state-project.client", []byte(`{}`))
+		require.NoError(t, err)
+	}
+
+	states, err := s.projectStreams.StreamStates(ctx)
+	require.NoError(t, err)
+
+	var found bool
+	for _, state := range states {
+		if state.Name == "LOGS_state-project" {
+			found = true
+			assert.Equal(t, uint64(5), state.Messages)
+			assert.Equal(t, uint64(5), state.LastSeq)
+		}
+	}
+	assert.True(t, found, "project stream should be listed")
+
+	require.NoError(t, s.projectStreams.DeleteProjectStream(ctx, "state-project"))
+	names, err := s.projectStreams.LogStreamNames(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, names, "LOGS_state-project")
+}
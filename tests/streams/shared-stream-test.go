@@ -0,0 +1,111 @@
+package streams
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *StreamsSuite) TestSharedStreamKeepsChangedLimits() {
+	t := s.T()
+	ctx := context.Background()
+
+	config := natsconn.JetStreamConfig{
+		Name:     "SHARED",
+		Subjects: []string{"shared.>"},
+		MaxBytes: 10 * 1024 * 1024,
+		MaxAge:   time.Hour,
+	}
+	conn, err := s.NewNatsConnection(config)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.UpdateStreamLimits(ctx, "SHARED", 1024*1024, 2*time.Hour))
+
+	// Connecting again, like a restart, doesn't reset the limits
+	restarted, err := s.NewNatsConnection(config)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	stream, err := restarted.JetStream.Stream(ctx, "SHARED")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), stream.CachedInfo().Config.MaxBytes)
+	assert.Equal(t, 2*time.Hour, stream.CachedInfo().Config.MaxAge)
+}
+
+func (s *StreamsSuite) TestSharedStreamGetsConfiguredLimits() {
+	t := s.T()
+	ctx := context.Background()
+
+	config := natsconn.JetStreamConfig{
+		Name:     "CONFIGURED",
+		Subjects: []string{"configured.>"},
+		MaxBytes: 10 * 1024 * 1024,
+		MaxAge:   time.Hour,
+	}
+	conn, err := s.NewNatsConnection(config)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Restarting with changed limits applies them to the existing stream
+	config.MaxBytes = 20 * 1024 * 1024
+	config.MaxAge = 3 * time.Hour
+	restarted, err := s.NewNatsConnection(config)
+	require.NoError(t, err)
+	defer restarted.Close()
+
+	stream, err := restarted.JetStream.Stream(ctx, "CONFIGURED")
+	require.NoError(t, err)
+	assert.Equal(t, int64(20*1024*1024), stream.CachedInfo().Config.MaxBytes)
+	assert.Equal(t, 3*time.Hour, stream.CachedInfo().Config.MaxAge)
+}
+
+func (s *StreamsSuite) TestSharedStreamWithOldDefaultsGetsConfiguredLimits() {
+	t := s.T()
+	ctx := context.Background()
+
+	// A stream created before the source of its limits was recorded
+	_, err := s.NatsConn.JetStream.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "UPGRADED",
+		Subjects: []string{"upgraded.>"},
+		MaxBytes: 1024 * 1024 * 1024,
+		MaxAge:   7 * 24 * time.Hour,
+		Replicas: 1,
+	})
+	require.NoError(t, err)
+
+	conn, err := s.NewNatsConnection(natsconn.JetStreamConfig{
+		Name:     "UPGRADED",
+		Subjects: []string{"upgraded.>"},
+		MaxBytes: 10 * 1024 * 1024,
+		MaxAge:   time.Hour,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stream, err := conn.JetStream.Stream(ctx, "UPGRADED")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024), stream.CachedInfo().Config.MaxBytes)
+	assert.Equal(t, time.Hour, stream.CachedInfo().Config.MaxAge)
+}
+
+func (s *StreamsSuite) TestProjectStreamsFailWhileSharedStreamExists() {
+	t := s.T()
+	ctx := context.Background()
+
+	_, err := s.NatsConn.JetStream.CreateStream(ctx, jetstream.StreamConfig{
+		Name:     "MIGRATING",
+		Subjects: []string{"migrating.>"},
+	})
+	require.NoError(t, err)
+
+	_, err = s.NewNatsConnection(natsconn.JetStreamConfig{
+		Name:       "MIGRATING",
+		Subjects:   []string{"migrating.>"},
+		PerProject: true,
+	})
+	require.ErrorIs(t, err, natsconn.ErrSharedStreamExists)
+}
@@ -0,0 +1,11 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestStreamsSuite(t *testing.T) {
+	suite.Run(t, new(StreamsSuite))
+}
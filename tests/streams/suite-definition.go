@@ -0,0 +1,43 @@
+package streams
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/tests/testutils"
+)
+
+type StreamsSuite struct {
+	testutils.BaseSuite
+
+	projectStreams *natsconn.NatsConnection
+}
+
+func (s *StreamsSuite) SetupSuite() {
+	s.BaseSuite.SetupSuite()
+
+	// Project streams overlap with the shared stream created by the base suite
+	if err := s.NatsConn.JetStream.DeleteStream(context.Background(), "LOGS"); err != nil {
+		s.T().Fatalf("failed to delete shared stream: %v", err)
+	}
+
+	projectStreams, err := s.NewNatsConnection(natsconn.JetStreamConfig{
+		Name:       "LOGS",
+		Subjects:   []string{"logs.>"},
+		MaxBytes:   10 * 1024 * 1024,
+		MaxAge:     time.Hour,
+		PerProject: true,
+	})
+	if err != nil {
+		s.T().Fatalf("failed to connect to NATS: %v", err)
+	}
+	s.projectStreams = projectStreams
+}
+
+func (s *StreamsSuite) TearDownSuite() {
+	if s.projectStreams != nil {
+		s.projectStreams.Close()
+	}
+	s.BaseSuite.TearDownSuite()
+}
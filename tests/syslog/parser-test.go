@@ -0,0 +1,120 @@
+package syslog
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/syslog"
+)
+
+func (s *SyslogSuite) TestParseRFC5424() {
+	message, err := syslog.Parse([]byte(`<165>1 2026-08-24T05:14:15.000003-07:00 web-1.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App \"lication\""][meta sequenceId="1"] `+"\ufeff"+`An application event`), time.Now())
+	s.Require().NoError(err)
+
+	s.Equal(20, message.Facility)
+	s.Equal(5, message.Severity)
+	s.Equal("info", message.Level())
+	s.True(time.Date(2026, 8, 24, 12, 14, 15, 3000, time.UTC).Equal(message.Timestamp))
+	s.Equal("web-1.example.com", message.Hostname)
+	s.Equal("evntslog", message.AppName)
+	s.Equal("1234", message.ProcId)
+	s.Equal("ID47", message.MsgId)
+	s.Equal(map[string]map[string]string{
+		"exampleSDID@32473": {"iut": "3", "eventSource": `App "lication"`},
+		"meta":              {"sequenceId": "1"},
+	}, message.StructuredData)
+	s.Equal("An application event", message.Message)
+}
+
+func (s *SyslogSuite) TestParseRFC5424NilValues() {
+	now := time.Now()
+	message, err := syslog.Parse([]byte(`<11>1 - - - - - - disk failing`), now)
+	s.Require().NoError(err)
+
+	s.Equal("error", message.Level())
+	s.Equal(now, message.Timestamp)
+	s.Empty(message.Hostname)
+	s.Empty(message.AppName)
+	s.Nil(message.StructuredData)
+	s.Equal("disk failing", message.Message)
+}
+
+func (s *SyslogSuite) TestParseRFC3164() {
+	now := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	message, err := syslog.Parse([]byte("<34>Oct 11 22:14:15 mymachine su[812]: 'su root' failed for lonvick on /dev/pts/8\n"), now)
+	s.Require().NoError(err)
+
+	s.Equal(4, message.Facility)
+	s.Equal("fatal", message.Level())
+	s.True(time.Date(2026, 10, 11, 22, 14, 15, 0, time.UTC).Equal(message.Timestamp))
+	s.Equal("mymachine", message.Hostname)
+	s.Equal("su", message.AppName)
+	s.Equal("812", message.ProcId)
+	s.Equal("'su root' failed for lonvick on /dev/pts/8", message.Message)
+
+	message, err = syslog.Parse([]byte("<13>Dec 31 23:59:59 cron: job done"), time.Date(2027, 1, 1, 0, 0, 5, 0, time.UTC))
+	s.Require().NoError(err)
+	s.Equal(2026, message.Timestamp.Year(), "messages from the end of the last year")
+	s.Empty(message.Hostname)
+	s.Equal("cron", message.AppName)
+	s.Equal("job done", message.Message)
+}
+
+func (s *SyslogSuite) TestParseRejectsMessagesWithoutPriority() {
+	_, err := syslog.Parse([]byte("just a line"), time.Now())
+	s.EqualError(err, syslog.ErrMissingPriority)
+
+	_, err = syslog.Parse([]byte("<999>1 - - - - - -"), time.Now())
+	s.EqualError(err, syslog.ErrMissingPriority)
+}
+
+func (s *SyslogSuite) TestSplitFrames() {
+	stream := "<13>1 - host app - - - first\n" +
+		"29 <13>1 - host app - - - second" +
+		"<13>1 - host app - - - third\r\n" +
+		"<13>1 - host app - - - last"
+
+	scanner := syslog.NewFrameScanner(bytes.NewReader([]byte(stream)))
+	frames := []string{}
+	for scanner.Scan() {
+		frames = append(frames, scanner.Text())
+	}
+	s.Require().NoError(scanner.Err())
+	s.Equal([]string{
+		"<13>1 - host app - - - first",
+		"<13>1 - host app - - - second",
+		"<13>1 - host app - - - third",
+		"<13>1 - host app - - - last",
+	}, frames)
+}
+
+func (s *SyslogSuite) TestRoute() {
+	project, client, ok := s.rules.Route(syslog.Message{Hostname: "fw-2", AppName: "kernel"}, net.ParseIP("192.168.1.1"))
+	s.True(ok)
+	s.Equal("network", project)
+	s.Equal("firewall", client)
+
+	project, client, ok = s.rules.Route(syslog.Message{Hostname: "db", AppName: "postgres"}, net.ParseIP("10.1.2.3"))
+	s.True(ok)
+	s.Equal("internal", project)
+	s.Equal("postgres", client)
+
+	_, client, ok = s.rules.Route(syslog.Message{Hostname: "batch.example.com", AppName: "cron"}, net.ParseIP("192.168.1.1"))
+	s.True(ok)
+	s.Equal("batch-example-com", client, "dots would split the NATS subject")
+
+	_, _, ok = s.rules.Route(syslog.Message{Hostname: "db", AppName: "postgres"}, net.ParseIP("192.168.1.1"))
+	s.False(ok)
+}
+
+func (s *SyslogSuite) TestParseRulesValidation() {
+	_, err := syslog.ParseRules([]byte(`rules: []`))
+	s.Error(err)
+
+	_, err = syslog.ParseRules([]byte("rules:\n  - hostname: web\n    project: p\n"))
+	s.ErrorContains(err, "needs a project and a client")
+
+	_, err = syslog.ParseRules([]byte("rules:\n  - source: nope\n    project: p\n    client: c\n"))
+	s.ErrorContains(err, "invalid source")
+}
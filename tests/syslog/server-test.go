@@ -0,0 +1,116 @@
+package syslog
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+func (s *SyslogSuite) TestReceivesOverUDP() {
+	addr, err := s.server.ListenUDP("127.0.0.1:0")
+	s.Require().NoError(err)
+
+	conn, err := net.Dial("udp", addr.String())
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// Unrouted messages are dropped
+	_, err = conn.Write([]byte("<14>1 2026-01-02T03:04:05Z db postgres - - - ignored"))
+	s.Require().NoError(err)
+	_, err = conn.Write([]byte(`<12>1 2026-01-02T03:04:05Z fw-1 kernel 42 - [origin ip="10.0.0.1"] port scan`))
+	s.Require().NoError(err)
+
+	line := s.receive()
+	s.Equal("network", line.ProjectId)
+	s.Equal("firewall", line.ClientId)
+	s.Equal("fw-1", line.InstanceId)
+	s.Equal("port scan", line.Message)
+	s.Equal("warn", line.Level)
+	s.Equal("kernel", line.Fields["syslog_app"])
+	s.Equal("10.0.0.1", line.Fields["origin_ip"])
+	s.Empty(s.ingestCh)
+}
+
+func (s *SyslogSuite) TestReceivesOverTCP() {
+	addr, err := s.server.ListenTCP("127.0.0.1:0", nil)
+	s.Require().NoError(err)
+
+	conn, err := net.Dial("tcp", addr.String())
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	first := "<14>1 - fw-1 sshd - - - accepted"
+	_, err = fmt.Fprintf(conn, "%d %s<14>Oct 11 22:14:15 fw-2 sshd: closed\n", len(first), first)
+	s.Require().NoError(err)
+
+	s.Equal("accepted", s.receive().Message)
+	line := s.receive()
+	s.Equal("closed", line.Message)
+	s.Equal("fw-2", line.InstanceId)
+}
+
+func (s *SyslogSuite) TestShutdownStopsBusyConnections() {
+	addr, err := s.server.ListenTCP("127.0.0.1:0", nil)
+	s.Require().NoError(err)
+
+	conn, err := net.Dial("tcp", addr.String())
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// The client keeps sending while the server shuts down
+	go func() {
+		for {
+			if _, err := fmt.Fprint(conn, "<14>Oct 11 22:14:15 fw-1 sshd: busy\n"); err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		for range s.ingestCh {
+		}
+	}()
+	s.receive()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	s.NoError(s.server.Shutdown(ctx))
+}
+
+func (s *SyslogSuite) TestReceivesOverTLS() {
+	addr, err := s.server.ListenTCP("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{s.selfSignedCertificate()}})
+	s.Require().NoError(err)
+
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{InsecureSkipVerify: true})
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("<14>1 - fw-1 sshd - - - over tls\n"))
+	s.Require().NoError(err)
+
+	s.Equal("over tls", s.receive().Message)
+}
+
+func (s *SyslogSuite) selfSignedCertificate() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	s.Require().NoError(err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
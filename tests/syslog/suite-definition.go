@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"context"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/syslog"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/markojerkic/svarog/tests/testutils"
+	"github.com/stretchr/testify/suite"
+)
+
+const testRules = `
+rules:
+  - hostname: "fw-*"
+    project: network
+    client: firewall
+  - source: 10.0.0.0/8
+    project: internal
+    client: "{app}"
+  - app: cron
+    project: legacy
+    client: "{hostname}"
+`
+
+type SyslogSuite struct {
+	suite.Suite
+
+	rules    *syslog.Rules
+	server   *ingest.SyslogServer
+	ingestCh chan db.LogLineWithHost
+}
+
+// Before each
+func (s *SyslogSuite) SetupTest() {
+	var err error
+	s.rules, err = syslog.ParseRules([]byte(testRules))
+	s.Require().NoError(err)
+
+	s.ingestCh = make(chan db.LogLineWithHost, 100)
+	s.server = ingest.NewSyslogServer(s.rules, ingest.NewReceiver(testutils.StartIngestJetStream(s.T(), s.ingestCh), nil))
+}
+
+// After each
+func (s *SyslogSuite) TearDownTest() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.NoError(s.server.Shutdown(ctx))
+}
+
+// receive waits for a line consumed from JetStream.
+func (s *SyslogSuite) receive() db.LogLineWithHost {
+	select {
+	case line := <-s.ingestCh:
+		return line
+	case <-time.After(5 * time.Second):
+		s.FailNow("no log line received")
+		return db.LogLineWithHost{}
+	}
+}
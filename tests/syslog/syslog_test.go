@@ -0,0 +1,11 @@
+package syslog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSyslogSuite(t *testing.T) {
+	suite.Run(t, new(SyslogSuite))
+}
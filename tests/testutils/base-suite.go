@@ -135,7 +135,7 @@ func (s *BaseSuite) setupNats(ctx context.Context) error {
 	s.NatsAddr = natsAddr
 
 	// Create token service
-	tokenService, err := serverauth.NewNatsCredentialService(s.config.NatsAccountSeed, s.NatsAddr, &NoopProjectService{})
+	tokenService, err := serverauth.NewNatsCredentialService(s.config.NatsAccountSeed, "", s.NatsAddr, &NoopProjectService{}, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create token service: %w", err)
 	}
@@ -164,6 +164,18 @@ func (s *BaseSuite) setupNats(ctx context.Context) error {
 	return nil
 }
 
+// NewNatsConnection opens another connection to the test NATS server with its
+// own stream configuration.
+func (s *BaseSuite) NewNatsConnection(streamConfig natsconn.JetStreamConfig) (*natsconn.NatsConnection, error) {
+	return natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		NatsAddr:        s.NatsAddr,
+		JWT:             s.config.NatsJwt,
+		Seed:            s.config.NatsSeed,
+		EnableJetStream: true,
+		JetStreamConfig: streamConfig,
+	})
+}
+
 // TearDownSuite cleans up all containers
 func (s *BaseSuite) TearDownSuite() {
 	ctx := context.Background()
@@ -0,0 +1,59 @@
+package testutils
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/markojerkic/svarog/internal/lib/natsconn"
+	"github.com/markojerkic/svarog/internal/lib/natstrust"
+	"github.com/markojerkic/svarog/internal/server/db"
+	"github.com/markojerkic/svarog/internal/server/ingest"
+	"github.com/stretchr/testify/require"
+)
+
+// StartIngestJetStream starts an embedded NATS server with the LOGS stream
+// and consumes it into ingestCh, for tests of the transports that publish to
+// JetStream without running the containers. Everything is stopped when the
+// test ends.
+func StartIngestJetStream(t *testing.T, ingestCh chan db.LogLineWithHost) *natsconn.NatsConnection {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "nats-server.conf")
+
+	trust, err := natstrust.Generate()
+	require.NoError(t, err)
+	require.NoError(t, trust.WriteServerConfig(configFile, natstrust.ServerConfigOptions{
+		Port:     -1,
+		StoreDir: filepath.Join(dir, "jetstream"),
+	}))
+
+	natsServer, err := natsconn.StartEmbeddedServer(natsconn.EmbeddedServerConfig{ConfigFile: configFile})
+	require.NoError(t, err)
+
+	natsConn, err := natsconn.NewNatsConnection(natsconn.NatsConnectionConfig{
+		JWT:             trust.ServerUser.JWT,
+		Seed:            trust.ServerUser.Seed,
+		EnableJetStream: true,
+		JetStreamConfig: natsconn.JetStreamConfig{Name: "LOGS", Subjects: []string{"logs.>"}},
+		InProcessServer: natsServer,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ingestService := ingest.NewIngestService(ingestCh, natsConn, nil, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ingestService.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		ingestService.Stop()
+		natsConn.Close()
+		natsServer.Shutdown()
+		natsServer.WaitForShutdown()
+	})
+	return natsConn
+}
@@ -0,0 +1,100 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/markojerkic/svarog/internal/lib/serverauth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryCredentialRegistry keeps issued credentials in memory, for suites
+// that don't start MongoDB.
+type MemoryCredentialRegistry struct {
+	mutex       sync.Mutex
+	credentials []serverauth.IssuedCredential
+}
+
+var _ serverauth.CredentialRegistry = &MemoryCredentialRegistry{}
+
+func (r *MemoryCredentialRegistry) Record(ctx context.Context, credential serverauth.IssuedCredential) (serverauth.IssuedCredential, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential.ID = primitive.NewObjectID()
+	r.credentials = append(r.credentials, credential)
+	return credential, nil
+}
+
+func (r *MemoryCredentialRegistry) GetCredentials(ctx context.Context, projectId string) ([]serverauth.IssuedCredential, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credentials := []serverauth.IssuedCredential{}
+	for _, credential := range r.credentials {
+		if credential.ProjectId == projectId {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *MemoryCredentialRegistry) GetCredential(ctx context.Context, id string) (serverauth.IssuedCredential, error) {
+	return r.find(func(credential serverauth.IssuedCredential) bool { return credential.ID.Hex() == id })
+}
+
+func (r *MemoryCredentialRegistry) GetByPublicKey(ctx context.Context, publicKey string) (serverauth.IssuedCredential, error) {
+	return r.find(func(credential serverauth.IssuedCredential) bool { return credential.PublicKey == publicKey })
+}
+
+func (r *MemoryCredentialRegistry) Revoke(ctx context.Context, id string) (serverauth.IssuedCredential, error) {
+	r.mutex.Lock()
+	for i, credential := range r.credentials {
+		if credential.ID.Hex() == id && credential.RevokedAt == nil {
+			now := time.Now()
+			r.credentials[i].RevokedAt = &now
+		}
+	}
+	r.mutex.Unlock()
+
+	return r.GetCredential(ctx, id)
+}
+
+func (r *MemoryCredentialRegistry) GetRevoked(ctx context.Context) ([]serverauth.IssuedCredential, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credentials := []serverauth.IssuedCredential{}
+	for _, credential := range r.credentials {
+		if credential.Revoked() && !credential.Expired() {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *MemoryCredentialRegistry) MarkSeen(ctx context.Context, publicKey string, at time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, credential := range r.credentials {
+		if credential.PublicKey == publicKey {
+			r.credentials[i].LastSeenAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *MemoryCredentialRegistry) find(matches func(serverauth.IssuedCredential) bool) (serverauth.IssuedCredential, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, credential := range r.credentials {
+		if matches(credential) {
+			return credential, nil
+		}
+	}
+	return serverauth.IssuedCredential{}, errors.New(serverauth.ErrCredentialNotFound)
+}
@@ -44,4 +44,24 @@ func (n *NoopProjectService) UpdateProject(ctx context.Context, id primitive.Obj
 	panic("unimplemented")
 }
 
+// RegisterClient implements [projects.ProjectsService].
+func (n *NoopProjectService) RegisterClient(ctx context.Context, projectId string, clientId string) (projects.ClientStatus, error) {
+	return projects.ClientApproved, nil
+}
+
+// ApproveClient implements [projects.ProjectsService].
+func (n *NoopProjectService) ApproveClient(ctx context.Context, projectId string, clientId string) error {
+	panic("unimplemented")
+}
+
+// RejectClient implements [projects.ProjectsService].
+func (n *NoopProjectService) RejectClient(ctx context.Context, projectId string, clientId string) error {
+	panic("unimplemented")
+}
+
+// SetRequireClientApproval implements [projects.ProjectsService].
+func (n *NoopProjectService) SetRequireClientApproval(ctx context.Context, projectId string, required bool) error {
+	panic("unimplemented")
+}
+
 var _ projects.ProjectsService = &NoopProjectService{}